@@ -2,9 +2,19 @@ package cmd
 
 import (
 	"fmt"
+	"net/http"
+	"os"
 
 	"github.com/continuouspipe/remote-environment-client/config"
+	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/util/asciicast"
+	"github.com/continuouspipe/remote-environment-client/util/slice"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 var bashInteractiveFullExample = fmt.Sprintf(`%s bash --interactive ([-i]) --environment ([-e]) php-example-cpdev-foo --service ([-s]) web --flow-id ([-f]) 1268cc54-b265-11e6-b835-0c360641bb54`, config.AppName)
@@ -19,6 +29,15 @@ Default Mode:
 	# bash into a different environment and service within the same flow
 	%[1]s bash -e techup-dev-user -s web
 
+	# bash into the php container of a given pod of the service
+	%[1]s bash --pod web-3089640113-mxiwy --container php
+
+	# open a zsh session, bash, ash and then sh are used when zsh is not installed in the container
+	%[1]s bash --shell zsh
+
+	# record the session to replay it later with '%[1]s replay incident.cast'
+	%[1]s bash --record incident.cast
+
 Interactive Mode:
 
 	# bash into a different environment (without knowing which one yet)
@@ -31,18 +50,111 @@ Interactive Mode:
 //BashCmdName is the name identifier for the exec command
 const BashCmdName = "bash"
 
+//defaultShells are the shells probed in the container, in order, after the preferred shell
+var defaultShells = []string{"bash", "ash", "sh"}
+
+//starts the first shell of $2... found in the container with a prompt starting with $1, bash applies the prompt after
+//reading its startup files, which often set their own prompt. Only bash and ash know the \w and \$ escapes, the other
+//shells, like the sh of Debian (dash), expand $PWD in the prompt instead
+const remoteShellSession = `label="$1"
+shift
+for shell in "$@"; do
+  if command -v "$shell" >/dev/null 2>&1; then
+    case "${shell##*/}" in
+      bash|ash) PS1="$label"' \w \$ ' ;;
+      *) PS1="$label"' $PWD $ ' ;;
+    esac
+    CP_REMOTE_PS1="$PS1"
+    PROMPT_COMMAND='PS1="$CP_REMOTE_PS1"'
+    export CP_REMOTE_PS1 PS1 PROMPT_COMMAND
+    exec "$shell"
+  fi
+done
+echo "None of the shells $* was found in the container." >&2
+exit 127`
+
 func NewBashCmd() *cobra.Command {
-	bashcmd := NewExecCmd()
+	handler := newExecHandle()
+	bashcmd := newExecCmd(handler, BashCmdName)
 	bashcmd.Use = BashCmdName
 	bashcmd.Aliases = []string{"ba"}
 	bashcmd.Short = "Open a bash session in the remote environment container"
-	bashcmd.Long = `This will remotely connect to a bash session onto the default container specified
-during setup but you can specify another container to connect to. `
+	bashcmd.Long = msgs.BashCommandLongDescription
 	bashcmd.Example = bashExample
+	bashcmd.PersistentFlags().StringVar(&handler.record, "record", "", "Record the session in the file in the asciicast v2 format, it can be played back with the replay command")
+	bashcmd.PersistentFlags().StringVar(&handler.shell, "shell", "", "The shell to start (e.g.: zsh, /bin/ash), by default the shell of the service in the .cp-remote.yml file or the first of bash, ash and sh found in the container")
+
 	execRun := bashcmd.Run
 	bashcmd.Run = func(cmd *cobra.Command, args []string) {
-		execRun(cmd, []string{"/bin/bash"})
+		project, err := loadProject()
+		if err != nil {
+			remoteCommand := remotecplogs.NewRemoteCommand(BashCmdName, os.Args)
+			cs := session.NewCommandSession().Start()
+			remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+			cperrors.ExitWithMessage(fmt.Sprintf(msgs.SuggestionRunFailed, session.CurrentSession.SessionID))
+		}
+		handler.serviceShells = map[string]string{}
+		for service, settings := range project.Services {
+			handler.serviceShells[service] = settings.Shell
+		}
+		handler.shellSession = true
+		execRun(cmd, nil)
 	}
 
 	return bashcmd
 }
+
+//shellSessionArgs returns the command starting the preferred shell, or the first default shell found in the container,
+//with a prompt showing the environment, the service and the current directory
+func (h *execHandle) shellSessionArgs() []string {
+	shells := defaultShells
+	preferred := h.shell
+	if preferred == "" {
+		preferred = h.serviceShells[h.service]
+	}
+	if preferred != "" {
+		shells = slice.RemoveDuplicateString(append([]string{preferred}, defaultShells...))
+	}
+	label := fmt.Sprintf("[%s/%s]", h.environment, h.service)
+	return append([]string{"sh", "-c", remoteShellSession, "sh", label}, shells...)
+}
+
+//sessionRecording is the recording of the session started by the bash command
+type sessionRecording struct {
+	file     *os.File
+	recorder *asciicast.Recorder
+}
+
+//startRecording creates the recording file of the session in the pod, the size of the local terminal is also given to the
+//remote shell so that the recording is played back at the size it was recorded
+func (h *execHandle) startRecording(pod string) (*sessionRecording, error) {
+	width, height, err := terminal.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+	h.env = append(h.env, fmt.Sprintf("COLUMNS=%d", width), fmt.Sprintf("LINES=%d", height))
+
+	file, err := os.Create(h.record)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when creating the recording file %s", h.record)).String())
+	}
+	header := asciicast.Header{
+		Width:  width,
+		Height: height,
+		Title:  fmt.Sprintf("%s/%s %s", h.environment, h.service, pod),
+		Env:    map[string]string{"TERM": os.Getenv("TERM")},
+	}
+	recorder, err := asciicast.NewRecorder(file, header)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when writing the recording file %s", h.record)).String())
+	}
+	fmt.Fprintf(os.Stderr, msgs.RecordingSession+"\n", h.record)
+	return &sessionRecording{file, recorder}, nil
+}
+
+func (r sessionRecording) close() {
+	r.recorder.Close()
+	r.file.Close()
+	fmt.Fprintf(os.Stderr, msgs.RecordingSaved+"\n", r.file.Name(), config.AppName)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/test/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecHandle_ShellSessionArgs(t *testing.T) {
+	tests := []struct {
+		scenario string
+		shell    string
+		expected []string
+	}{
+		{"the default shells are probed", "", []string{"bash", "ash", "sh"}},
+		{"the shell of the flag is probed first", "zsh", []string{"zsh", "bash", "ash", "sh"}},
+		{"a default shell is not probed twice", "ash", []string{"ash", "bash", "sh"}},
+	}
+	for _, test := range tests {
+		h := execHandle{shell: test.shell}
+		h.environment, h.service = "project-dev", "mysql"
+		expected := append([]string{"sh", "-c", remoteShellSession, "sh", "[project-dev/mysql]"}, test.expected...)
+		assert.Equal(t, expected, h.shellSessionArgs(), test.scenario)
+	}
+
+	h := execHandle{serviceShells: map[string]string{"web": "/bin/zsh", "mysql": "bash"}, shellSession: true}
+	h.environment, h.service = "project-dev", "web"
+	h.complete(nil, mocks.NewSpyConfig())
+	assert.Equal(t, []string{"/bin/zsh", "bash", "ash", "sh"}, h.args[5:], "the shell of the service is preferred")
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/sync/diff"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//DiffCmdName is the command name identifier
+const DiffCmdName = "diff"
+
+func NewDiffCmd() *cobra.Command {
+	settings := config.C
+	handler := &DiffHandle{}
+	handler.kubeCtlInit = kubectlapi.NewKubeCtlInit()
+	handler.writer = os.Stdout
+
+	command := &cobra.Command{
+		Use:     DiffCmdName + " [paths...]",
+		Aliases: []string{"di"},
+		Short:   msgs.DiffCommandShortDescription,
+		Example: fmt.Sprintf(msgs.DiffCommandExampleDescription, config.AppName),
+		Long:    msgs.DiffCommandLongDescription,
+		Run: func(cmd *cobra.Command, args []string) {
+			remoteCommand := remotecplogs.NewRemoteCommand(DiffCmdName, os.Args)
+			cs := session.NewCommandSession().Start()
+
+			//validate the configuration file
+			missingSettings, ok := config.C.Validate()
+			if ok == false {
+				reason := fmt.Sprintf(msgs.InvalidConfigSettings, missingSettings)
+				err := remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.Ended(http.StatusBadRequest, reason, "", *cs))
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(reason)
+			}
+
+			handler.Complete(args, settings)
+
+			err := handler.Validate()
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(err.Error())
+			}
+
+			suggestion, err := handler.Handle(pods.NewKubePodsFind(), pods.NewKubePodsFilter())
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(suggestion)
+			}
+
+			err = remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cs))
+			if err != nil {
+				cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				cplogs.Flush()
+			}
+		},
+	}
+
+	environment, err := settings.GetString(config.KubeEnvironmentName)
+	checkErr(err)
+	service, err := settings.GetString(config.Service)
+	checkErr(err)
+	useGitIgnore, err := settings.GetBool(config.UseGitIgnore)
+	checkErr(err)
+
+	command.PersistentFlags().StringVarP(&handler.environment, config.KubeEnvironmentName, "e", environment, "The full remote environment name")
+	command.PersistentFlags().StringVarP(&handler.service, config.Service, "s", service, "The service to use (e.g.: web, mysql)")
+	handler.addPodSelectionFlags(command.PersistentFlags())
+	handler.addContainerFlag(command.PersistentFlags())
+	command.PersistentFlags().StringVarP(&handler.remoteProjectPath, "remote-project-path", "a", "/app/", "Specify the absolute path to your project folder, by default set to /app/")
+	command.PersistentFlags().BoolVar(&handler.useGitIgnore, config.UseGitIgnore, useGitIgnore, "Exclude the files ignored by git using the .gitignore files of the project")
+	command.PersistentFlags().BoolVar(&handler.nameOnly, "name-only", false, "Only list the files that differ without showing the content diffs")
+	return command
+}
+
+type DiffHandle struct {
+	podSelection
+	kubeCtlInit                             kubectlapi.KubeCtlInitializer
+	writer                                  io.Writer
+	environment, service, remoteProjectPath string
+	useGitIgnore, nameOnly                  bool
+	paths                                   []string
+}
+
+// Complete verifies command line arguments and loads data from the command environment
+func (h *DiffHandle) Complete(argsIn []string, settings *config.Config) {
+	if h.environment == "" {
+		h.environment = settings.GetStringQ(config.KubeEnvironmentName)
+	}
+	if h.service == "" {
+		h.service = settings.GetStringQ(config.Service)
+	}
+	if strings.HasSuffix(h.remoteProjectPath, "/") == false {
+		h.remoteProjectPath = h.remoteProjectPath + "/"
+	}
+	h.paths = argsIn
+}
+
+// Validate checks that the provided diff options are specified and that the paths are inside the project.
+func (h *DiffHandle) Validate() error {
+	if len(strings.Trim(h.environment, " ")) == 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.EnvironmentSpecifiedEmpty).String())
+	}
+	if len(strings.Trim(h.service, " ")) == 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ServiceSpecifiedEmpty).String())
+	}
+	if strings.HasPrefix(h.remoteProjectPath, "/") == false {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.RemoteProjectPathEmpty).String())
+	}
+	for key, path := range h.paths {
+		relPath, err := projectRelativePath(path)
+		if err != nil {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.PathOutsideProject, path)).String())
+		}
+		h.paths[key] = relPath
+	}
+	return h.validateSelection()
+}
+
+// Lists the files that differ between the local project and the remote container and shows their content diffs
+func (h *DiffHandle) Handle(podsFinder pods.Finder, podsFilter pods.Filter) (suggestion string, err error) {
+	target := podTarget{h.environment, h.service, h.podSelection}
+	pod, container, _, suggestion, err := target.findPod(h.kubeCtlInit, podsFinder, podsFilter, DiffCmdName)
+	if err != nil {
+		return suggestion, err
+	}
+
+	kscmd := kexec.KSCommand{}
+	kscmd.KubeConfigKey = h.environment
+	kscmd.Environment = h.environment
+	kscmd.Pod = pod.GetName()
+	kscmd.Container = container
+	differ := diff.NewDiffer(kscmd, h.remoteProjectPath, h.useGitIgnore)
+
+	res, err := differ.Compare(h.paths)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionDiffFailed, session.CurrentSession.SessionID), err
+	}
+	if res.Empty() {
+		fmt.Fprintln(h.writer, msgs.DiffNoDifferences)
+		return "", nil
+	}
+	printDiffList(h.writer, "Only local", res.OnlyLocal)
+	printDiffList(h.writer, "Only remote", res.OnlyRemote)
+	printDiffList(h.writer, "Modified", res.Modified)
+
+	if h.nameOnly {
+		return "", nil
+	}
+	for _, file := range res.Modified {
+		unified, binary, err := differ.UnifiedDiff(file)
+		if err != nil {
+			return fmt.Sprintf(msgs.SuggestionDiffFailed, session.CurrentSession.SessionID), err
+		}
+		fmt.Fprintln(h.writer)
+		if binary {
+			fmt.Fprintf(h.writer, "Binary files remote/%s and local/%s differ\n", file, file)
+			continue
+		}
+		fmt.Fprint(h.writer, unified)
+	}
+	return "", nil
+}
+
+func printDiffList(writer io.Writer, title string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Fprintf(writer, "%s:\n", title)
+	for _, file := range files {
+		fmt.Fprintf(writer, "  %s\n", file)
+	}
+}
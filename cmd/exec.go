@@ -2,7 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"os"
 	"runtime"
@@ -17,11 +17,13 @@ import (
 	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
 	msgs "github.com/continuouspipe/remote-environment-client/messages"
 	"github.com/continuouspipe/remote-environment-client/session"
-	"github.com/fatih/color"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+	"k8s.io/kubernetes/pkg/client/unversioned/clientcmd"
 	kubectlcmd "k8s.io/kubernetes/pkg/kubectl/cmd"
 	kubectlcmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	utilexec "k8s.io/kubernetes/pkg/util/exec"
 )
 
 //ExecCmdName is the name identifier for the exec command
@@ -30,8 +32,12 @@ const ExecCmdName = "exec"
 //NewExecCmd return a cobra command struct pointer which on Run, if required it prepares the config so we can reach the pod and
 //then uses a command handler to execute the command specified in the arguments
 func NewExecCmd() *cobra.Command {
-	handler := newExecHandle()
+	return newExecCmd(newExecHandle(), ExecCmdName)
+}
 
+//newExecCmd returns the exec command running the arguments with the handler, the session of the command is reported
+//with the name
+func newExecCmd(handler *execHandle, name string) *cobra.Command {
 	var interactive bool
 	var flowID string
 
@@ -42,10 +48,19 @@ func NewExecCmd() *cobra.Command {
 		Long:    msgs.ExecCommandLongDescription,
 		Example: fmt.Sprintf(msgs.ExecCommandExampleDescription, config.AppName),
 		Run: func(cmd *cobra.Command, args []string) {
-			remoteCommand := remotecplogs.NewRemoteCommand(ExecCmdName, args)
+			remoteCommand := remotecplogs.NewRemoteCommand(name, args)
 			cmdSession := session.NewCommandSession().Start()
 
 			suggestion, err := RunExec(handler, interactive, flowID, args)
+			if status, ok := remoteExitStatus(err); ok {
+				//the command ran and failed in the pod, its exit status is the one of the exec command
+				sendErr := remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cmdSession))
+				if sendErr != nil {
+					cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				}
+				cplogs.Flush()
+				os.Exit(status)
+			}
 			if err != nil {
 				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cmdSession, err)
 				cperrors.ExitWithMessage(suggestion)
@@ -64,6 +79,14 @@ func NewExecCmd() *cobra.Command {
 	bashcmd.PersistentFlags().StringVarP(&handler.environment, config.KubeEnvironmentName, "e", "", "The full remote environment name")
 	bashcmd.PersistentFlags().StringVarP(&handler.service, config.Service, "s", "", "The service to use (e.g.: web, mysql)")
 	bashcmd.PersistentFlags().StringVarP(&flowID, config.FlowId, "f", "", "The flow to use")
+	handler.addPodSelectionFlags(bashcmd.PersistentFlags())
+	handler.addContainerFlag(bashcmd.PersistentFlags())
+	handler.addMultiplePodsFlags(bashcmd.PersistentFlags())
+	bashcmd.PersistentFlags().BoolVar(&handler.tty, "tty", false, "Allocate a terminal even when the standard input or output is not a terminal")
+	bashcmd.PersistentFlags().BoolVar(&handler.noTTY, "no-tty", false, "Don't allocate a terminal, even when the standard input and output are terminals")
+	bashcmd.PersistentFlags().StringArrayVar(&handler.env, "env", nil, "Set an environment variable of the remote command (e.g.: --env APP_ENV=test), can be repeated")
+	bashcmd.PersistentFlags().StringVarP(&handler.workdir, "workdir", "w", "", "The directory where the command runs, by default the remote project path")
+	bashcmd.PersistentFlags().StringVarP(&handler.remoteProjectPath, "remote-project-path", "a", "/app/", "Specify the absolute path to your project folder, by default set to /app/")
 
 	return bashcmd
 }
@@ -74,57 +97,9 @@ func RunExec(handler *execHandle, interactive bool, flowID string, args []string
 	podsFinder := pods.NewKubePodsFind()
 	podsFilter := pods.NewKubePodsFilter()
 
-	defaultEnvironment := settings.GetStringQ(config.KubeEnvironmentName)
-	defaultService := settings.GetStringQ(config.Service)
-
-	if interactive {
-		cplogs.V(5).Infoln("exec in interactive mode")
-		//make sure config has an api key and a cp user set
-		initInteractiveH := NewInitInteractiveHandler(false)
-		initInteractiveH.SetWriter(ioutil.Discard)
-		suggestion, err := initInteractiveH.Complete(args)
-		if err != nil {
-			return suggestion, err
-		}
-		err = initInteractiveH.Validate()
-		if err != nil {
-			return err.Error(), err
-		}
-		suggestion, err = initInteractiveH.Handle()
-		if err != nil {
-			return suggestion, err
-		}
-
-		if flowID == "" && handler.environment == "" && handler.service == "" {
-			//guide the user to choose the right pod they want to target
-			questioner := cpapi.NewMultipleChoiceCpEntityQuestioner()
-			questioner.SetAPIKey(settings.GetStringQ(config.ApiKey))
-			_, flow, environment, pod, suggestion, err := questioner.WhichEntities()
-			if err != nil {
-				return suggestion, err
-			}
-
-			handler.environment = environment.Identifier
-			handler.service = pod.Name
-			flowID = flow.UUID
-
-			suggestedFlags := color.GreenString("-i -e %s -f %s -s %s", environment.Identifier, flow.UUID, pod.Name)
-			fmt.Printf(fmt.Sprintf("\n\n%s\n", msgs.InteractiveModeSuggestingFlags), suggestedFlags)
-		}
-
-		//alter the configuration so that we connect to the flow and environment specified by the user
-		suggestion, err = newInteractiveModeH().findTargetClusterAndApplyToConfig(flowID, handler.environment)
-		if err != nil {
-			return suggestion, err
-		}
-	} else {
-		//apply default values
-		if handler.environment == "" {
-			handler.environment = defaultEnvironment
-		}
-		if handler.service == "" {
-			handler.service = defaultService
-		}
+	suggestion, err = handler.completeTarget(interactive, flowID, args)
+	if err != nil {
+		return suggestion, err
 	}
 
 	handler.complete(args, settings)
@@ -139,18 +114,39 @@ func RunExec(handler *execHandle, interactive bool, flowID string, args []string
 	return "", nil
 }
 
+//runs the command $3... in the directory $1, the command fails when the directory doesn't exist and $2 is "required"
+const remoteRunInDirectory = `if ! cd "$1" 2>/dev/null; then
+  [ "$2" = required ] && echo "$1: no such directory" >&2 && exit 1
+fi
+shift 2
+exec "$@"`
+
 type execHandle struct {
-	args        []string
-	config      config.ConfigProvider
-	environment string
-	service     string
-	kubeCtlInit kubectlapi.KubeCtlInitializer
+	podTarget
+	args              []string
+	config            config.ConfigProvider
+	kubeCtlInit       kubectlapi.KubeCtlInitializer
+	tty, noTTY        bool
+	env               []string
+	workdir           string
+	remoteProjectPath string
+	isTerminal        func(fd int) bool
+	//noStdin is true when the command doesn't read the standard input, like the commands run after a sync
+	noStdin bool
+	//shellSession is true when the command is an interactive shell, the shell flag or the shell of the service in
+	//serviceShells is preferred to the default shells
+	shellSession  bool
+	shell         string
+	serviceShells map[string]string
+	//record is the file where the session is recorded in the asciicast format
+	record string
 }
 
 func newExecHandle() *execHandle {
 	p := &execHandle{}
 	p.config = config.C
 	p.kubeCtlInit = kubectlapi.NewKubeCtlInit()
+	p.isTerminal = terminal.IsTerminal
 	return p
 }
 
@@ -164,68 +160,127 @@ func (h *execHandle) complete(argsIn []string, conf config.ConfigProvider) {
 	if h.service == "" {
 		h.service = conf.GetStringQ(config.Service)
 	}
+	if h.shellSession {
+		h.args = h.shellSessionArgs()
+	}
 }
 
 // validate checks that the provided bash options are specified.
 func (h *execHandle) validate() error {
-	if len(strings.Trim(h.environment, " ")) == 0 {
-		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.EnvironmentSpecifiedEmpty).String())
+	if h.tty && h.noTTY {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ExecTTYConflict).String())
+	}
+	if h.tty && h.multiplePods() {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ExecMultiplePodsTTY).String())
+	}
+	if h.record != "" && h.multiplePods() {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.RecordMultiplePods).String())
+	}
+	for _, variable := range h.env {
+		if strings.Index(variable, "=") < 1 {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.ExecEnvInvalid, variable)).String())
+		}
+	}
+	return h.validateTarget()
+}
+
+//useTTY returns true when a terminal is allocated for the command, by default when the standard input and output are
+//terminals
+func (h *execHandle) useTTY() bool {
+	if h.tty || h.noTTY {
+		return h.tty
+	}
+	return h.isTerminal(int(os.Stdin.Fd())) && h.isTerminal(int(os.Stdout.Fd()))
+}
+
+//remoteCommand returns the command executed in the pod: the command of the arguments run with the environment variables
+//in the working directory
+func (h *execHandle) remoteCommand(tty bool, goos string) []string {
+	var env []string
+	if tty && (goos == "darwin" || goos == "linux") {
+		envTerm := os.Getenv("TERM")
+		if envTerm == "" {
+			envTerm = "xterm"
+		}
+
+		//ensure that the TERM environment variable is set
+		//Work-around to be removed when kubernetes and docker fix the issue.
+		//See docker/docker#26461 and kubernetes/kubernetes/issues/28280
+		env = append(env, "TERM="+envTerm)
 	}
-	if len(strings.Trim(h.service, " ")) == 0 {
-		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ServiceSpecifiedEmpty).String())
+	env = append(env, h.env...)
+
+	workdir, required := h.workdir, "required"
+	if workdir == "" {
+		//the pods that don't have the project directory run the command in their default directory
+		workdir, required = h.remoteProjectPath, "optional"
+	}
+	command := []string{"sh", "-c", remoteRunInDirectory, "sh", workdir, required}
+	command = append(command, h.args...)
+	if len(env) > 0 {
+		command = append(append([]string{"env"}, env...), command...)
+	}
+	return command
+}
+
+//remoteExitStatus returns the exit status of the remote command when the error is caused by the command failing in the pod
+func remoteExitStatus(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+	exitErr, ok := errors.Cause(err).(utilexec.ExitError)
+	if !ok || !exitErr.Exited() {
+		return 0, false
 	}
-	return nil
+	return exitErr.ExitStatus(), true
 }
 
 // handle opens a bash console against a pod.
 func (h *execHandle) handle(podsFinder pods.Finder, podsFilter pods.Filter) (suggestion string, err error) {
-	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
-	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
+	if h.multiplePods() {
+		return h.handleMultiplePods(podsFinder, podsFilter)
 	}
-
-	podsList, err := podsFinder.FindAll(user, apiKey, addr, h.environment)
+	pod, container, clientConfig, suggestion, err := h.findPod(h.kubeCtlInit, podsFinder, podsFilter, "bash")
 	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+		return suggestion, err
 	}
 
-	pod := podsFilter.List(*podsList).ByService(h.service).ByStatus("Running").ByStatusReason("Running").First()
-	if pod == nil {
-		return fmt.Sprintf(msgs.SuggestionRunningPodNotFound, h.service, h.environment, config.AppName, "bash", session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, h.service)).String())
+	tty := h.useTTY()
+	var out, errOut io.Writer = os.Stdout, os.Stderr
+	if h.record != "" {
+		recording, err := h.startRecording(pod.GetName())
+		if err != nil {
+			return fmt.Sprintf(msgs.SuggestionRecordFailed, h.record, session.CurrentSession.SessionID), err
+		}
+		defer recording.close()
+		out, errOut = io.MultiWriter(os.Stdout, recording.recorder), io.MultiWriter(os.Stderr, recording.recorder)
 	}
+	cplogs.V(5).Infof("executing %s in the pod %s, terminal allocated: %t", h.args, pod.GetName(), tty)
+	cplogs.Flush()
+	return h.execInPod(clientConfig, pod.GetName(), container, tty, out, errOut)
+}
 
-	clientConfig := kubectlapi.GetNonInteractiveDeferredLoadingClientConfig(user, apiKey, addr, h.environment)
-	kubeCmdExec := kubectlcmd.NewCmdExec(kubectlcmdutil.NewFactory(clientConfig), os.Stdin, os.Stdout, os.Stderr)
+//execInPod runs the command in the container of the pod, the error is returned as is when the command fails in the pod
+func (h *execHandle) execInPod(clientConfig clientcmd.ClientConfig, pod string, container string, tty bool, out io.Writer, errOut io.Writer) (suggestion string, err error) {
+	kubeCmdExec := kubectlcmd.NewCmdExec(kubectlcmdutil.NewFactory(clientConfig), os.Stdin, out, errOut)
 	kubeCmdExecOptions := &kubectlcmd.ExecOptions{
 		StreamOptions: kubectlcmd.StreamOptions{
 			In:  os.Stdin,
-			Out: os.Stdout,
-			Err: os.Stderr,
+			Out: out,
+			Err: errOut,
 		},
 
 		Executor: &kubectlcmd.DefaultRemoteExecutor{},
 	}
 
-	kubeCmdExecOptions.TTY = true
-	kubeCmdExecOptions.Stdin = true
-	kubeCmdExecOptions.PodName = pod.GetName()
-
-	if runtime.GOOS == "darwin" || runtime.GOOS == "linux" {
-
-		envTerm := os.Getenv("TERM")
-		if envTerm == "" {
-			envTerm = "xterm"
-		}
-
-		//ensure that the TERM environment variable is set
-		//Work-around to be removed when kubernetes and docker fix the issue.
-		//See docker/docker#26461 and kubernetes/kubernetes/issues/28280
-		h.args = append([]string{"env", "TERM=" + envTerm}, h.args...)
-	}
+	kubeCmdExecOptions.TTY = tty
+	kubeCmdExecOptions.Stdin = !h.noStdin
+	kubeCmdExecOptions.PodName = pod
+	kubeCmdExecOptions.ContainerName = container
 
 	kubeCmdUtilFactory := kubectlcmdutil.NewFactory(clientConfig)
 	argsLenAtDash := kubeCmdExec.ArgsLenAtDash()
-	err = kubeCmdExecOptions.Complete(kubeCmdUtilFactory, kubeCmdExec, h.args, argsLenAtDash)
+	err = kubeCmdExecOptions.Complete(kubeCmdUtilFactory, kubeCmdExec, h.remoteCommand(tty, runtime.GOOS), argsLenAtDash)
 	if err != nil {
 		return err.Error(), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, err.Error()).String())
 	}
@@ -235,6 +290,9 @@ func (h *execHandle) handle(podsFinder pods.Finder, podsFilter pods.Filter) (sug
 	}
 
 	err = kubeCmdExecOptions.Run()
+	if _, ok := remoteExitStatus(err); ok {
+		return "", err
+	}
 	if err != nil {
 		cplogs.V(5).Infof("The pod may have been killed or moved to a different node. Error %s", err)
 		cplogs.Flush()
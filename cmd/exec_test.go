@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	utilexec "k8s.io/kubernetes/pkg/util/exec"
+)
+
+func TestExecHandle_RemoteCommand(t *testing.T) {
+	tests := []struct {
+		scenario string
+		handle   execHandle
+		tty      bool
+		goos     string
+		expected []string
+	}{
+		{
+			"the command runs in the remote project path when it exists",
+			execHandle{args: []string{"ls", "-all"}, remoteProjectPath: "/app/"},
+			false,
+			"linux",
+			[]string{"sh", "-c", remoteRunInDirectory, "sh", "/app/", "optional", "ls", "-all"},
+		},
+		{
+			"the working directory is required and the environment variables are set",
+			execHandle{args: []string{"phpunit"}, remoteProjectPath: "/app/", workdir: "/app/api", env: []string{"APP_ENV=test"}},
+			false,
+			"linux",
+			[]string{"env", "APP_ENV=test", "sh", "-c", remoteRunInDirectory, "sh", "/app/api", "required", "phpunit"},
+		},
+		{
+			"the terminal type is set with a terminal",
+			execHandle{args: []string{"/bin/bash"}, remoteProjectPath: "/app/"},
+			true,
+			"windows",
+			[]string{"sh", "-c", remoteRunInDirectory, "sh", "/app/", "optional", "/bin/bash"},
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.handle.remoteCommand(test.tty, test.goos), test.scenario)
+	}
+
+	command := (&execHandle{args: []string{"/bin/bash"}}).remoteCommand(true, "darwin")
+	assert.Equal(t, "env", command[0])
+	assert.Contains(t, command[1], "TERM=", "the terminal type is set with a terminal")
+}
+
+func TestExecHandle_Validate(t *testing.T) {
+	h := execHandle{tty: true, noTTY: true}
+	h.environment, h.service = "dev", "web"
+	assert.NotNil(t, h.validate(), "--tty and --no-tty conflict")
+
+	h = execHandle{env: []string{"=test"}}
+	h.environment, h.service = "dev", "web"
+	assert.NotNil(t, h.validate(), "the variable has no name")
+
+	h = execHandle{env: []string{"APP_ENV=test", "EMPTY="}}
+	h.environment, h.service = "dev", "web"
+	h.replicaIndex = -1
+	assert.Nil(t, h.validate())
+}
+
+func TestRemoteExitStatus(t *testing.T) {
+	status, ok := remoteExitStatus(errors.Wrap(utilexec.CodeExitError{Err: errors.New("exit status 3"), Code: 3}, "error when executing the command"))
+	assert.True(t, ok)
+	assert.Equal(t, 3, status)
+
+	_, ok = remoteExitStatus(errors.New("unable to upgrade connection"))
+	assert.False(t, ok, "the command didn't run in the pod")
+
+	_, ok = remoteExitStatus(nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	gosync "sync"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/pkg/errors"
+	utilexec "k8s.io/kubernetes/pkg/util/exec"
+)
+
+//podExecResult is the outcome of the command in one of the pods
+type podExecResult struct {
+	pod        string
+	exitStatus int
+	//err is set when the command could not run in the pod
+	err error
+}
+
+//handleMultiplePods runs the command concurrently in the pods of the selection, the output lines are prefixed with the
+//name of their pod and the exit status of each pod is printed at the end. The exit status returned is the highest one
+func (h *execHandle) handleMultiplePods(podsFinder pods.Finder, podsFilter pods.Filter) (suggestion string, err error) {
+	podList, clientConfig, suggestion, err := h.findPods(h.kubeCtlInit, podsFinder, podsFilter, ExecCmdName)
+	if err != nil {
+		return suggestion, err
+	}
+
+	names := podNames(podList)
+	containers := make([]string, len(podList))
+	for i := range podList {
+		//the container of the flag has to exist in every pod, the user is not asked to choose one for each pod
+		selection := h.podSelection
+		selection.isTerminal = func() bool { return false }
+		containers[i], err = selection.selectContainer(&podList[i])
+		if err != nil {
+			return err.Error(), err
+		}
+	}
+	cplogs.V(5).Infof("executing %s in the pods %s", h.args, names)
+	cplogs.Flush()
+
+	//the pods can't share the standard input
+	h.noStdin = true
+	results := execInPods(names, prefixWidth(names), os.Stdout, os.Stderr, func(i int, out io.Writer, errOut io.Writer) (string, error) {
+		return h.execInPod(clientConfig, names[i], containers[i], false, out, errOut)
+	})
+	printPodExecResults(os.Stdout, results)
+
+	highest := 0
+	for _, result := range results {
+		if result.err != nil {
+			return fmt.Sprintf(msgs.SuggestionExecRunFailed, session.CurrentSession.SessionID), result.err
+		}
+		if result.exitStatus > highest {
+			highest = result.exitStatus
+		}
+	}
+	if highest > 0 {
+		return "", utilexec.CodeExitError{Err: errors.Errorf("the command failed in %d pods", failedPods(results)), Code: highest}
+	}
+	return "", nil
+}
+
+//execInPods calls exec for each pod concurrently with writers prefixing the lines with the name of the pod
+func execInPods(names []string, width int, out io.Writer, errOut io.Writer, exec func(i int, out io.Writer, errOut io.Writer) (string, error)) []podExecResult {
+	results := make([]podExecResult, len(names))
+	mutex := &gosync.Mutex{}
+	wg := gosync.WaitGroup{}
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			prefix := fmt.Sprintf("[%-*s] ", width, name)
+			podOut := newPrefixWriter(out, prefix, mutex)
+			podErrOut := newPrefixWriter(errOut, prefix, mutex)
+			_, err := exec(i, podOut, podErrOut)
+			podOut.Flush()
+			podErrOut.Flush()
+
+			results[i].pod = name
+			if status, ok := remoteExitStatus(err); ok {
+				results[i].exitStatus = status
+			} else {
+				results[i].err = err
+			}
+		}(i, name)
+	}
+	wg.Wait()
+	return results
+}
+
+//printPodExecResults prints the exit status of the command in each pod
+func printPodExecResults(writer io.Writer, results []podExecResult) {
+	var names []string
+	for _, result := range results {
+		names = append(names, result.pod)
+	}
+	width := prefixWidth(names)
+
+	fmt.Fprintln(writer, msgs.ExecPodsSummary)
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Fprintf(writer, "  %-*s  "+msgs.ExecPodFailed+"\n", width, result.pod, errors.Cause(result.err).Error())
+			continue
+		}
+		fmt.Fprintf(writer, "  %-*s  %d\n", width, result.pod, result.exitStatus)
+	}
+}
+
+func failedPods(results []podExecResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.err != nil || result.exitStatus != 0 {
+			failed++
+		}
+	}
+	return failed
+}
+
+func prefixWidth(names []string) int {
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	return width
+}
+
+//prefixWriter writes the complete lines it receives prefixed with the name of their pod, the lines of the writers
+//sharing the same mutex are not mixed
+type prefixWriter struct {
+	writer io.Writer
+	prefix string
+	mutex  *gosync.Mutex
+	buffer bytes.Buffer
+}
+
+func newPrefixWriter(writer io.Writer, prefix string, mutex *gosync.Mutex) *prefixWriter {
+	return &prefixWriter{writer: writer, prefix: prefix, mutex: mutex}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+	for {
+		i := bytes.IndexByte(w.buffer.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		err := w.writeLine(w.buffer.Next(i + 1))
+		if err != nil {
+			return len(p), err
+		}
+	}
+}
+
+//Flush writes the last line when it doesn't end with a new line
+func (w *prefixWriter) Flush() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	return w.writeLine(append(w.buffer.Next(w.buffer.Len()), '\n'))
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, err := io.WriteString(w.writer, w.prefix+string(line))
+	return err
+}
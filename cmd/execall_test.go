@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	gosync "sync"
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	utilexec "k8s.io/kubernetes/pkg/util/exec"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := newPrefixWriter(out, "[web-1  ] ", &gosync.Mutex{})
+	io.WriteString(w, "first line\nsecond ")
+	io.WriteString(w, "line\nno new line")
+	assert.Equal(t, "[web-1  ] first line\n[web-1  ] second line\n", out.String(), "only the complete lines are written")
+
+	w.Flush()
+	assert.Equal(t, "[web-1  ] first line\n[web-1  ] second line\n[web-1  ] no new line\n", out.String())
+}
+
+func TestExecInPods(t *testing.T) {
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	names := []string{"web-1", "web-2", "web-3"}
+	results := execInPods(names, prefixWidth(names), out, errOut, func(i int, out io.Writer, errOut io.Writer) (string, error) {
+		switch i {
+		case 0:
+			io.WriteString(out, "cleared\n")
+			return "", nil
+		case 1:
+			io.WriteString(errOut, "permission denied\n")
+			return "", utilexec.CodeExitError{Err: errors.New("exit status 2"), Code: 2}
+		default:
+			return "", errors.New("unable to upgrade connection")
+		}
+	})
+
+	assert.Equal(t, "[web-1] cleared\n", out.String())
+	assert.Equal(t, "[web-2] permission denied\n", errOut.String())
+	assert.Equal(t, 0, results[0].exitStatus)
+	assert.Equal(t, 2, results[1].exitStatus)
+	assert.NotNil(t, results[2].err, "the command could not run in the pod")
+	assert.Equal(t, 2, failedPods(results))
+
+	summary := &bytes.Buffer{}
+	printPodExecResults(summary, results)
+	assert.Equal(t, "Exit codes:\n  web-1  0\n  web-2  2\n  web-3  failed: unable to upgrade connection\n", summary.String())
+}
+
+func TestPodTarget_MatchingPodsBySelector(t *testing.T) {
+	newPod := func(name string, labels map[string]string) api.Pod {
+		pod := newTargetPod(name)
+		pod.Labels = labels
+		pod.Status.Phase = api.PodRunning
+		return pod
+	}
+	podList := api.PodList{Items: []api.Pod{
+		newPod("nginx-1", map[string]string{"app": "nginx", "tier": "frontend"}),
+		newPod("nginx-2", map[string]string{"app": "nginx", "tier": "backend"}),
+		newPod("web-1", map[string]string{"app": "web"}),
+	}}
+
+	target := podTarget{environment: "dev", service: "web"}
+	target.selector = "app=nginx,tier=frontend"
+	assert.Equal(t, []string{"nginx-1"}, podNames(target.matchingPods(pods.NewKubePodsFilter(), podList, api.ServiceList{})))
+
+	target.selector = ""
+	target.allPods = true
+	assert.Equal(t, []string{"web-1"}, podNames(target.matchingPods(pods.NewKubePodsFilter(), podList, api.ServiceList{})))
+}
+
+func TestPodSelection_ValidateMultiplePods(t *testing.T) {
+	assert.Nil(t, podSelection{allPods: true, replicaIndex: -1}.validateSelection())
+	assert.NotNil(t, podSelection{allPods: true, replicaIndex: 1}.validateSelection(), "a single pod is chosen with the replica index")
+	assert.NotNil(t, podSelection{selector: "app=web", pod: "web-1", replicaIndex: -1}.validateSelection(), "a single pod is chosen by name")
+	assert.NotNil(t, podSelection{allPods: true, selector: "app=web", replicaIndex: -1}.validateSelection())
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/continuouspipe/remote-environment-client/config"
 	"github.com/continuouspipe/remote-environment-client/cplogs"
@@ -17,6 +18,9 @@ import (
 	"github.com/continuouspipe/remote-environment-client/session"
 	"github.com/continuouspipe/remote-environment-client/sync"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/continuouspipe/remote-environment-client/sync/rsync"
+	"github.com/continuouspipe/remote-environment-client/util"
+	"github.com/fatih/color"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -29,9 +33,10 @@ func NewFetchCmd() *cobra.Command {
 	handler := &FetchHandle{}
 	handler.kubeCtlInit = kubectlapi.NewKubeCtlInit()
 	handler.writer = os.Stdout
+	handler.qp = util.NewQuestionPrompt()
 
 	command := &cobra.Command{
-		Use:     FetchCmdName,
+		Use:     FetchCmdName + " [paths...]",
 		Aliases: []string{"fe"},
 		Short:   msgs.FetchCommandShortDescription,
 		Example: fmt.Sprintf(msgs.FetchCommandExampleDescription, config.AppName),
@@ -84,17 +89,27 @@ func NewFetchCmd() *cobra.Command {
 	checkErr(err)
 	service, err := settings.GetString(config.Service)
 	checkErr(err)
+	useGitIgnore, err := settings.GetBool(config.UseGitIgnore)
+	checkErr(err)
 
 	command.PersistentFlags().StringVarP(&handler.Environment, config.KubeEnvironmentName, "e", environment, "The full remote environment name")
 	command.PersistentFlags().StringVarP(&handler.Service, config.Service, "s", service, "The service to use (e.g.: web, mysql)")
-	command.PersistentFlags().StringVarP(&handler.File, "file", "f", "", "Allows to specify a file that needs to be fetch from the pod")
+	handler.addPodSelectionFlags(command.PersistentFlags())
+	handler.addContainerFlag(command.PersistentFlags())
+	command.PersistentFlags().StringVarP(&handler.File, "file", "f", "", "Allows to specify a file that needs to be fetch from the pod, the paths can also be given as arguments")
 	command.PersistentFlags().StringVarP(&handler.RemoteProjectPath, "remote-project-path", "a", "/app/", "Specify the absolute path to your project folder, by default set to /app/")
 	command.PersistentFlags().BoolVar(&handler.rsyncVerbose, "rsync-verbose", false, "Allows to use rsync in verbose mode and debug issues with exclusions")
 	command.PersistentFlags().BoolVar(&handler.dryRun, "dry-run", false, "Show what would have been transferred")
+	command.PersistentFlags().BoolVar(&handler.delete, "delete", false, "Delete the local files that don't exist in the remote directories")
+	command.PersistentFlags().BoolVarP(&handler.yall, "yes", "y", false, "Skip warning")
+	command.PersistentFlags().StringVar(&handler.since, "since", "", "Only fetch the files modified remotely after this time, a duration (e.g.: 30m, 2h) or a date (e.g.: 2017-06-12 15:30)")
+	command.PersistentFlags().BoolVar(&handler.useGitIgnore, config.UseGitIgnore, useGitIgnore, "Exclude the files ignored by git using the .gitignore files of the project")
+	addTransferFlags(command, &handler.transfer, settings)
 	return command
 }
 
 type FetchHandle struct {
+	podSelection
 	Command           *cobra.Command
 	Environment       string
 	Service           string
@@ -103,7 +118,15 @@ type FetchHandle struct {
 	kubeCtlInit       kubectlapi.KubeCtlInitializer
 	rsyncVerbose      bool
 	dryRun            bool
+	delete            bool
+	yall              bool
+	useGitIgnore      bool
+	since             string
+	modifiedSince     time.Time
+	paths             []string
+	transfer          options.TransferOptions
 	writer            io.Writer
+	qp                util.QuestionPrompter
 }
 
 // Complete verifies command line arguments and loads data from the command environment
@@ -118,6 +141,10 @@ func (h *FetchHandle) Complete(cmd *cobra.Command, argsIn []string, settings *co
 	if strings.HasSuffix(h.RemoteProjectPath, "/") == false {
 		h.RemoteProjectPath = h.RemoteProjectPath + "/"
 	}
+	h.paths = argsIn
+	if h.File != "" {
+		h.paths = append([]string{h.File}, h.paths...)
+	}
 }
 
 // Validate checks that the provided fetch options are specified.
@@ -131,24 +158,45 @@ func (h *FetchHandle) Validate() error {
 	if strings.HasPrefix(h.RemoteProjectPath, "/") == false {
 		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.RemoteProjectPathEmpty).String())
 	}
-	return nil
+	for key, path := range h.paths {
+		relPath, err := projectRelativePath(path)
+		if err != nil {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.PathOutsideProject, path)).String())
+		}
+		h.paths[key] = relPath
+	}
+	if h.since != "" {
+		modifiedSince, err := options.ParseSince(h.since, time.Now())
+		if err != nil {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.FetchSinceInvalid, h.since)).String())
+		}
+		if h.delete {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.FetchSinceWithDelete).String())
+		}
+		h.modifiedSince = modifiedSince
+	}
+	if err := h.validateSelection(); err != nil {
+		return err
+	}
+	return validateTransferOptions(h.transfer)
 }
 
 // Copies all the files and folders from the remote development environment into the current directory
 func (h *FetchHandle) Handle(args []string, podsFinder pods.Finder, podsFilter pods.Filter, fetcher sync.Fetcher) (suggestion string, err error) {
-	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
+	target := podTarget{h.Environment, h.Service, h.podSelection}
+	pod, container, _, suggestion, err := target.findPod(h.kubeCtlInit, podsFinder, podsFilter, FetchCmdName)
 	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
+		return suggestion, err
 	}
 
-	allPods, err := podsFinder.FindAll(user, apiKey, addr, h.Environment)
-	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
-	}
-
-	pod := podsFilter.List(*allPods).ByService(h.Service).ByStatus("Running").ByStatusReason("Running").First()
-	if pod == nil {
-		return fmt.Sprintf(msgs.SuggestionRunningPodNotFound, h.Service, h.Environment, config.AppName, "bash", session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, h.Service)).String())
+	if h.delete {
+		if h.yall == false {
+			answer := fetchDeleteFlagWarning(h.qp)
+			if answer == "no" {
+				return "", nil
+			}
+		}
+		fmt.Fprintln(h.writer, "Delete mode enabled.")
 	}
 
 	if h.dryRun {
@@ -160,12 +208,38 @@ func (h *FetchHandle) Handle(args []string, podsFinder pods.Finder, podsFilter p
 	syncOptions.Environment = h.Environment
 	syncOptions.KubeConfigKey = h.Environment
 	syncOptions.Pod = pod.GetName()
+	syncOptions.Container = container
 	syncOptions.RemoteProjectPath = h.RemoteProjectPath
 	syncOptions.DryRun = h.dryRun
+	syncOptions.Delete = h.delete
+	syncOptions.UseGitIgnore = h.useGitIgnore
+	syncOptions.Transfer = h.transfer
+	syncOptions.ModifiedSince = h.modifiedSince
 	fetcher.SetOptions(syncOptions)
-	err = fetcher.Fetch(h.File)
+	err = fetcher.Fetch(h.paths)
+	if errors.Cause(err) == rsync.ErrNothingModified {
+		fmt.Fprintf(h.writer, msgs.FetchNothingModified+"\n", h.modifiedSince.Format(time.RFC1123))
+		return "", nil
+	}
 	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionFetchFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error while running rsync").String())
+		return rsyncSuggestion(err, fmt.Sprintf(msgs.SuggestionFetchFailed, session.CurrentSession.SessionID)), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error while running rsync").String())
 	}
 	return "", nil
 }
+
+func fetchDeleteFlagWarning(qp util.QuestionPrompter) string {
+	suggestedCmd := color.GreenString(`%s fetch --delete -y --dry-run | grep "deleting"`, config.AppName)
+	return qp.RepeatUntilValid(
+		"Using the --delete flag will delete any local files or folders that are not found in the remote pod.\n"+
+			"If you wish to preserve any local files or folders that are not found remotely you can include them in the .cp-remote-ignore file.\n"+
+			fmt.Sprintf("If you are unsure about what files will potentially be deleted you can run %s to find out.\n", suggestedCmd)+
+			"\nDo you want to proceed (yes/no): ",
+		func(answer string) (bool, error) {
+			switch answer {
+			case "yes", "no":
+				return true, nil
+			default:
+				return false, fmt.Errorf(msgs.InvalidAnswerForYesNo, answer)
+			}
+		})
+}
@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/util"
+	"github.com/continuouspipe/remote-environment-client/util/shell"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//FilesCmdName is the command name identifier
+const FilesCmdName = "files"
+
+//the prefix of the remote paths given to files cp
+const remotePathPrefix = ":"
+
+//lists the files matched by the patterns, each line contains the matched path and a file separated by a tab,
+//the directories are listed recursively when $1 is 1
+const remoteListMatches = `for p in %s; do
+	if [ -d "$p" ]; then
+		if [ "$1" != 1 ]; then
+			echo "$p is a directory, use --recursive to copy it" >&2
+			exit 1
+		fi
+		find "$p" -type f | while IFS= read -r f; do printf '%%s\t%%s\n' "$p" "$f"; done
+	elif [ -e "$p" ]; then
+		printf '%%s\t%%s\n' "$p" "$p"
+	else
+		echo "$p: no such file or directory" >&2
+		exit 1
+	fi
+done`
+
+//writes the standard input in the file $1, creating its directory, and sets its permissions to $2
+const remoteWriteFile = `mkdir -p "$(dirname "$1")" && cat > "$1" && chmod "$2" "$1"`
+
+func NewFilesCmd() *cobra.Command {
+	handler := newFilesHandle()
+
+	command := &cobra.Command{
+		Use:     FilesCmdName,
+		Aliases: []string{"fi"},
+		Short:   msgs.FilesCommandShortDescription,
+		Long:    msgs.FilesCommandLongDescription,
+		Example: fmt.Sprintf(msgs.FilesCommandExampleDescription, config.AppName),
+	}
+	command.PersistentFlags().BoolVarP(&handler.interactive, "interactive", "i", false, "Interactive mode allows to target a different environment")
+	command.PersistentFlags().StringVarP(&handler.environment, config.KubeEnvironmentName, "e", "", "The full remote environment name")
+	command.PersistentFlags().StringVarP(&handler.service, config.Service, "s", "", "The service to use (e.g.: web, mysql)")
+	command.PersistentFlags().StringVarP(&handler.flowID, config.FlowId, "f", "", "The flow to use")
+	command.PersistentFlags().StringVarP(&handler.remoteProjectPath, "remote-project-path", "a", "/app/", "The directory of the relative remote paths, by default set to /app/")
+	handler.addPodSelectionFlags(command.PersistentFlags())
+	handler.addContainerFlag(command.PersistentFlags())
+
+	lsCommand := newFilesSubCmd("ls", "ls [paths...]", msgs.FilesLsCommandShortDescription, handler, handler.ls)
+	catCommand := newFilesSubCmd("cat", "cat <paths...>", msgs.FilesCatCommandShortDescription, handler, handler.cat)
+	cpCommand := newFilesSubCmd("cp", "cp <sources...> <destination>", msgs.FilesCpCommandShortDescription, handler, handler.cp)
+	cpCommand.Flags().BoolVarP(&handler.recursive, "recursive", "r", false, "Copy the directories and their content")
+	rmCommand := newFilesSubCmd("rm", "rm <paths...>", msgs.FilesRmCommandShortDescription, handler, handler.rm)
+	rmCommand.Flags().BoolVarP(&handler.recursive, "recursive", "r", false, "Remove the directories and their content")
+	rmCommand.Flags().BoolVarP(&handler.yes, "yes", "y", false, "Skip the confirmation")
+
+	command.AddCommand(lsCommand)
+	command.AddCommand(catCommand)
+	command.AddCommand(cpCommand)
+	command.AddCommand(rmCommand)
+	return command
+}
+
+func newFilesSubCmd(name string, use string, short string, handler *filesHandle, operation func(args []string) (string, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Run: func(cmd *cobra.Command, args []string) {
+			remoteCommand := remotecplogs.NewRemoteCommand(FilesCmdName+" "+name, os.Args)
+			cs := session.NewCommandSession().Start()
+
+			suggestion, err := handler.connect(args)
+			if err == nil {
+				suggestion, err = operation(args)
+			}
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(suggestion)
+			}
+
+			err = remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cs))
+			if err != nil {
+				cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				cplogs.Flush()
+			}
+		},
+	}
+}
+
+type filesHandle struct {
+	podTarget
+	kubeCtlInit       kubectlapi.KubeCtlInitializer
+	writer            io.Writer
+	qp                util.QuestionPrompter
+	interactive       bool
+	flowID            string
+	remoteProjectPath string
+	recursive, yes    bool
+	//exec runs the command in the pod, sending in to its standard input when not nil
+	exec func(in io.Reader, out io.Writer, command ...string) error
+}
+
+func newFilesHandle() *filesHandle {
+	h := &filesHandle{}
+	h.kubeCtlInit = kubectlapi.NewKubeCtlInit()
+	h.writer = os.Stdout
+	h.qp = util.NewQuestionPrompt()
+	return h
+}
+
+//connect finds the pod of the service in the same way as exec
+func (h *filesHandle) connect(args []string) (suggestion string, err error) {
+	suggestion, err = h.completeTarget(h.interactive, h.flowID, args)
+	if err != nil {
+		return suggestion, err
+	}
+	err = h.validateTarget()
+	if err != nil {
+		return err.Error(), err
+	}
+	if strings.HasPrefix(h.remoteProjectPath, "/") == false {
+		err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.RemoteProjectPathEmpty).String())
+		return err.Error(), err
+	}
+
+	pod, container, clientConfig, suggestion, err := h.findPod(h.kubeCtlInit, pods.NewKubePodsFind(), pods.NewKubePodsFilter(), FilesCmdName)
+	if err != nil {
+		return suggestion, err
+	}
+	h.exec = func(in io.Reader, out io.Writer, command ...string) error {
+		return streamInPod(clientConfig, pod.GetName(), container, in, out, os.Stderr, command...)
+	}
+	return "", nil
+}
+
+//ls lists the remote files, the remote project directory when no path is given
+func (h *filesHandle) ls(args []string) (suggestion string, err error) {
+	if len(args) == 0 {
+		args = []string{h.remoteProjectPath}
+	}
+	return h.run(h.writer, "ls -la -- "+h.remoteGlobs(args))
+}
+
+//cat prints the content of the remote files
+func (h *filesHandle) cat(args []string) (suggestion string, err error) {
+	if len(args) == 0 {
+		err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.FilesPathsEmpty).String())
+		return err.Error(), err
+	}
+	return h.run(h.writer, "cat -- "+h.remoteGlobs(args))
+}
+
+//rm removes the remote files after showing them and asking for a confirmation
+func (h *filesHandle) rm(args []string) (suggestion string, err error) {
+	if len(args) == 0 {
+		err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.FilesPathsEmpty).String())
+		return err.Error(), err
+	}
+	globs := h.remoteGlobs(args)
+	if !h.yes {
+		fmt.Fprintln(h.writer, "The following remote paths will be deleted:")
+		suggestion, err = h.run(h.writer, "ls -1d -- "+globs)
+		if err != nil {
+			return suggestion, err
+		}
+		answer := h.qp.RepeatUntilValid("\nDo you want to proceed (yes/no): ", func(answer string) (bool, error) {
+			switch answer {
+			case "yes", "no":
+				return true, nil
+			default:
+				return false, fmt.Errorf(msgs.InvalidAnswerForYesNo, answer)
+			}
+		})
+		if answer == "no" {
+			return "", nil
+		}
+	}
+	rmCmd := "rm -f -- "
+	if h.recursive {
+		rmCmd = "rm -rf -- "
+	}
+	return h.run(h.writer, rmCmd+globs)
+}
+
+//cp copies the files from the pod when the sources are remote, to the pod when the destination is remote
+func (h *filesHandle) cp(args []string) (suggestion string, err error) {
+	if len(args) < 2 {
+		err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.FilesCopyArgumentsInvalid).String())
+		return err.Error(), err
+	}
+	sources, destination := args[:len(args)-1], args[len(args)-1]
+	remoteSources := 0
+	for _, source := range sources {
+		if strings.HasPrefix(source, remotePathPrefix) {
+			remoteSources++
+		}
+	}
+	remoteDestination := strings.HasPrefix(destination, remotePathPrefix)
+
+	switch {
+	case remoteSources == len(sources) && !remoteDestination:
+		err = h.download(sources, destination)
+	case remoteSources == 0 && remoteDestination:
+		err = h.upload(sources, destination)
+	default:
+		err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.FilesCopyArgumentsInvalid).String())
+		return err.Error(), err
+	}
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionFilesFailed, session.CurrentSession.SessionID), err
+	}
+	return "", nil
+}
+
+//download copies the remote files matched by the sources in the local destination
+func (h *filesHandle) download(sources []string, destination string) error {
+	recursive := "0"
+	if h.recursive {
+		recursive = "1"
+	}
+	out := &bytes.Buffer{}
+	err := h.exec(nil, out, "sh", "-c", fmt.Sprintf(remoteListMatches, h.remoteGlobs(sources)), "sh", recursive)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("error when listing the remote files %s", strings.Join(sources, " "))).String())
+	}
+
+	var matches []fileMatch
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		parts := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 2)
+		if len(parts) == 2 {
+			matches = append(matches, fileMatch{root: parts[0], file: parts[1]})
+		}
+	}
+	info, err := os.Stat(destination)
+	intoDir := err == nil && info.IsDir()
+
+	for _, match := range copyDestinations(matches, filepath.ToSlash(destination), intoDir) {
+		target := filepath.FromSlash(match.target)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		err = h.exec(nil, f, "cat", "--", match.file)
+		f.Close()
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when copying the remote file %s", match.file)).String())
+		}
+		fmt.Fprintf(h.writer, "%s%s -> %s\n", remotePathPrefix, match.file, target)
+	}
+	return nil
+}
+
+//upload copies the local files matched by the sources in the remote destination
+func (h *filesHandle) upload(sources []string, destination string) error {
+	var matches []fileMatch
+	for _, source := range sources {
+		paths, err := filepath.Glob(source)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.FilesNoMatch, source)).String())
+		}
+		for _, p := range paths {
+			info, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				matches = append(matches, fileMatch{root: filepath.ToSlash(p), file: filepath.ToSlash(p)})
+				continue
+			}
+			if !h.recursive {
+				return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("%s is a directory, use --recursive to copy it", p)).String())
+			}
+			err = filepath.Walk(p, func(file string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode().IsRegular() {
+					matches = append(matches, fileMatch{root: filepath.ToSlash(p), file: filepath.ToSlash(file)})
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	remoteDestination := h.remotePath(destination)
+	out := &bytes.Buffer{}
+	err := h.exec(nil, out, "sh", "-c", `[ -d "$1" ] && echo dir; exit 0`, "sh", remoteDestination)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when checking the remote destination %s", remoteDestination)).String())
+	}
+	intoDir := strings.TrimSpace(out.String()) == "dir"
+
+	for _, match := range copyDestinations(matches, remoteDestination, intoDir) {
+		info, err := os.Stat(match.file)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(match.file)
+		if err != nil {
+			return err
+		}
+		mode := strconv.FormatUint(uint64(info.Mode().Perm()), 8)
+		err = h.exec(f, ioutil.Discard, "sh", "-c", remoteWriteFile, "sh", match.target, mode)
+		f.Close()
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when copying the local file %s", match.file)).String())
+		}
+		fmt.Fprintf(h.writer, "%s -> %s%s\n", match.file, remotePathPrefix, match.target)
+	}
+	return nil
+}
+
+func (h *filesHandle) run(out io.Writer, script string) (suggestion string, err error) {
+	err = h.exec(nil, out, "sh", "-c", script)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionFilesFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when executing %s", script)).String())
+	}
+	return "", nil
+}
+
+//remotePath returns the absolute remote path, the relative paths are in the remote project directory
+func (h *filesHandle) remotePath(p string) string {
+	p = strings.TrimPrefix(p, remotePathPrefix)
+	if path.IsAbs(p) {
+		return p
+	}
+	trailingSlash := strings.HasSuffix(p, "/")
+	p = path.Join(h.remoteProjectPath, p)
+	if trailingSlash {
+		p += "/"
+	}
+	return p
+}
+
+//remoteGlobs returns the remote paths as shell words where only the * and ? wildcards are expanded by the shell
+func (h *filesHandle) remoteGlobs(paths []string) string {
+	words := make([]string, len(paths))
+	for i, p := range paths {
+		words[i] = shell.QuoteGlob(h.remotePath(p))
+	}
+	return strings.Join(words, " ")
+}
+
+//fileMatch is a file matched by a copy source, root is the path matched, the file itself or a parent directory
+type fileMatch struct {
+	root, file, target string
+}
+
+//copyDestinations sets the target of the copied files, when copying in a directory or copying several files the
+//files are copied in the destination directory keeping their path relative to the parent of the matched path
+func copyDestinations(matches []fileMatch, destination string, intoDir bool) []fileMatch {
+	intoDir = intoDir || strings.HasSuffix(destination, "/") || len(matches) > 1
+	for _, match := range matches {
+		//the content of a directory is copied in a directory
+		if match.root != match.file {
+			intoDir = true
+		}
+	}
+	for i, match := range matches {
+		if !intoDir {
+			matches[i].target = destination
+			continue
+		}
+		rel := path.Base(match.root) + strings.TrimPrefix(match.file, match.root)
+		matches[i].target = path.Join(destination, rel)
+	}
+	return matches
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesHandle_RemoteGlobs(t *testing.T) {
+	handler := newFilesHandle()
+	handler.remoteProjectPath = "/app/"
+
+	tests := []struct {
+		paths    []string
+		expected string
+	}{
+		{[]string{"var/log/*.log"}, `'/app/var/log/'*'.log'`},
+		{[]string{"/tmp/report ?.csv"}, `'/tmp/report '?'.csv'`},
+		{[]string{":it's.txt", "/etc/hosts"}, `'/app/it'\''s.txt' '/etc/hosts'`},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, handler.remoteGlobs(test.paths), strings.Join(test.paths, " "))
+	}
+}
+
+func TestCopyDestinations(t *testing.T) {
+	tests := []struct {
+		scenario    string
+		matches     []fileMatch
+		destination string
+		intoDir     bool
+		expected    []string
+	}{
+		{"a file copied to a file", []fileMatch{{root: "/tmp/a.csv", file: "/tmp/a.csv"}}, "b.csv", false, []string{"b.csv"}},
+		{"a file copied in a directory", []fileMatch{{root: "/tmp/a.csv", file: "/tmp/a.csv"}}, "out", true, []string{"out/a.csv"}},
+		{"a file copied in a directory with a trailing slash", []fileMatch{{root: "a.csv", file: "a.csv"}}, "/app/out/", false, []string{"/app/out/a.csv"}},
+		{"files matched by a glob", []fileMatch{{root: "/log/a.log", file: "/log/a.log"}, {root: "/log/b.log", file: "/log/b.log"}}, "logs", false, []string{"logs/a.log", "logs/b.log"}},
+		{"a directory copied recursively", []fileMatch{{root: "tests/fixtures", file: "tests/fixtures/users/1.json"}}, "/app/tests", false, []string{"/app/tests/fixtures/users/1.json"}},
+	}
+	for _, test := range tests {
+		var targets []string
+		for _, match := range copyDestinations(test.matches, test.destination, test.intoDir) {
+			targets = append(targets, match.target)
+		}
+		assert.Equal(t, test.expected, targets, test.scenario)
+	}
+}
+
+func TestFilesHandle_Cp(t *testing.T) {
+	var commands [][]string
+	handler := newFilesHandle()
+	handler.writer = &bytes.Buffer{}
+	handler.remoteProjectPath = "/app/"
+	handler.exec = func(in io.Reader, out io.Writer, command ...string) error {
+		commands = append(commands, command)
+		return nil
+	}
+
+	_, err := handler.cp([]string{"a.txt"})
+	assert.NotNil(t, err, "a destination is required")
+	_, err = handler.cp([]string{":a.txt", "b.txt", "out"})
+	assert.NotNil(t, err, "the sources are either all local or all remote")
+	_, err = handler.cp([]string{":a.txt", ":b.txt"})
+	assert.NotNil(t, err, "the sources and the destination can't be all remote")
+	assert.Empty(t, commands)
+}
@@ -85,10 +85,12 @@ func NewForwardCmd() *cobra.Command {
 
 	command.PersistentFlags().StringVarP(&handler.Environment, config.KubeEnvironmentName, "e", environment, "The full remote environment name")
 	command.PersistentFlags().StringVarP(&handler.Service, config.Service, "s", service, "The service to use (e.g.: web, mysql)")
+	handler.addPodSelectionFlags(command.PersistentFlags())
 	return command
 }
 
 type ForwardHandle struct {
+	podSelection
 	Command     *cobra.Command
 	ports       []string
 	podsFinder  pods.Finder
@@ -125,29 +127,19 @@ func (h *ForwardHandle) Validate() error {
 	if len(strings.Trim(h.Service, " ")) == 0 {
 		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ServiceSpecifiedEmpty).String())
 	}
-	return nil
+	return h.validateSelection()
 }
 
 func (h *ForwardHandle) Handle() (suggestion string, err error) {
-	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
+	target := podTarget{h.Environment, h.Service, h.podSelection}
+	pod, _, clientConfig, suggestion, err := target.findPod(h.kubeCtlInit, h.podsFinder, h.podsFilter, ForwardCmdName)
 	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
-	}
-
-	allPods, err := h.podsFinder.FindAll(user, apiKey, addr, h.Environment)
-	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
-	}
-
-	pod := h.podsFilter.List(*allPods).ByService(h.Service).ByStatus("Running").ByStatusReason("Running").First()
-	if pod == nil {
-		return fmt.Sprintf(msgs.SuggestionRunningPodNotFound, h.Service, h.Environment, config.AppName, "bash", session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, h.Service)).String())
+		return suggestion, err
 	}
 
 	cplogs.V(5).Infof("setting up forwarding for target pod %s and ports %s", pod.GetName(), h.ports)
 	cplogs.Flush()
 
-	clientConfig := kubectlapi.GetNonInteractiveDeferredLoadingClientConfig(user, apiKey, addr, h.Environment)
 	kubeCmdPortForward := kubectlcmd.NewCmdPortForward(kubectlcmdutil.NewFactory(clientConfig), os.Stdout, os.Stderr)
 
 	opts := &kubectlcmd.PortForwardOptions{
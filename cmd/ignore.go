@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
+	"github.com/continuouspipe/remote-environment-client/sync/rsync"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//IgnoreCmdName is the command name identifier
+const IgnoreCmdName = "ignore"
+
+//IgnoreCheckCmdName is the sub-command name identifier
+const IgnoreCheckCmdName = "check"
+
+//IgnoreLsCmdName is the sub-command name identifier
+const IgnoreLsCmdName = "ls"
+
+//IgnorePresetCmdName is the sub-command name identifier
+const IgnorePresetCmdName = "preset"
+
+//NewIgnoreCmd returns a cobra command that groups the sub-commands explaining the exclusions
+func NewIgnoreCmd() *cobra.Command {
+	settings := config.C
+	command := &cobra.Command{
+		Use:     IgnoreCmdName,
+		Aliases: []string{"ig"},
+		Short:   msgs.IgnoreCommandShortDescription,
+		Long:    msgs.IgnoreCommandLongDescription,
+		Example: fmt.Sprintf(msgs.IgnoreCommandExampleDescription, config.AppName),
+	}
+
+	useGitIgnore, err := settings.GetBool(config.UseGitIgnore)
+	checkErr(err)
+
+	check := &IgnoreHandle{writer: os.Stdout, list: false, exclusion: monitor.NewExclusion()}
+	checkCommand := newIgnoreSubCmd(IgnoreCheckCmdName, "check <paths...>", msgs.IgnoreCheckCommandShortDescription, check)
+	checkCommand.Flags().BoolVar(&check.options.useGitIgnore, config.UseGitIgnore, useGitIgnore, "Exclude the files ignored by git using the .gitignore files of the project")
+
+	ls := &IgnoreHandle{writer: os.Stdout, list: true, exclusion: monitor.NewExclusion()}
+	lsCommand := newIgnoreSubCmd(IgnoreLsCmdName, "ls [directories...]", msgs.IgnoreLsCommandShortDescription, ls)
+	lsCommand.Flags().BoolVar(&ls.options.useGitIgnore, config.UseGitIgnore, useGitIgnore, "Exclude the files ignored by git using the .gitignore files of the project")
+	lsCommand.Flags().BoolVarP(&ls.options.all, "all", "a", false, "List the content of the directories that are excluded from push, fetch and watch")
+
+	command.AddCommand(checkCommand)
+	command.AddCommand(lsCommand)
+	command.AddCommand(NewIgnorePresetCmd())
+	return command
+}
+
+//NewIgnorePresetCmd returns a cobra command that manages the framework exclusion presets
+func NewIgnorePresetCmd() *cobra.Command {
+	settings := config.C
+	handler := &IgnorePresetHandle{}
+	handler.writer = os.Stdout
+	handler.exclusion = monitor.NewExclusion()
+
+	useGitIgnore, err := settings.GetBool(config.UseGitIgnore)
+	checkErr(err)
+	handler.exclusion.UseGitIgnore = useGitIgnore
+
+	command := &cobra.Command{
+		Use:   IgnorePresetCmdName,
+		Short: msgs.IgnorePresetCommandShortDescription,
+	}
+	addCommand := &cobra.Command{
+		Use:   "add <names...>",
+		Short: fmt.Sprintf(msgs.IgnorePresetAddCommandShortDescription, strings.Join(monitor.ExclusionPresetNames(), ", ")),
+		Run: func(cmd *cobra.Command, args []string) {
+			remoteCommand := remotecplogs.NewRemoteCommand(IgnoreCmdName+" "+IgnorePresetCmdName+" add", os.Args)
+			cs := session.NewCommandSession().Start()
+
+			err := handler.Validate(args)
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(err.Error())
+			}
+
+			suggestion, err := handler.Handle(args)
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(suggestion)
+			}
+
+			err = remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cs))
+			if err != nil {
+				cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				cplogs.Flush()
+			}
+		},
+	}
+	command.AddCommand(addCommand)
+	return command
+}
+
+func newIgnoreSubCmd(name string, use string, short string, handler *IgnoreHandle) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Run: func(cmd *cobra.Command, args []string) {
+			remoteCommand := remotecplogs.NewRemoteCommand(IgnoreCmdName+" "+name, os.Args)
+			cs := session.NewCommandSession().Start()
+
+			err := handler.Validate(args)
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(err.Error())
+			}
+
+			suggestion, err := handler.Handle(args)
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(suggestion)
+			}
+
+			err = remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cs))
+			if err != nil {
+				cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				cplogs.Flush()
+			}
+		},
+	}
+}
+
+//IgnoreHandle explains which rule of the ignore files decides if a path is pushed, fetched and watched
+type IgnoreHandle struct {
+	writer io.Writer
+	//list is true when the handler lists the content of the directories rather than checking the paths
+	list    bool
+	options ignoreCmdOptions
+	//exclusion filters the events of the watcher
+	exclusion *monitor.Exclusion
+}
+
+type ignoreCmdOptions struct {
+	useGitIgnore, all bool
+}
+
+//ignoreStatus contains the decisions taken for a path in each sync direction
+type ignoreStatus struct {
+	path               string
+	push, fetch, watch pattern.Decision
+}
+
+//Validate checks that the paths to check are specified
+func (h *IgnoreHandle) Validate(args []string) error {
+	if !h.list && len(args) == 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.IgnoreCheckPathsEmpty).String())
+	}
+	return nil
+}
+
+//Handle prints the status of the given paths, or of the content of the given directories
+func (h *IgnoreHandle) Handle(args []string) (suggestion string, err error) {
+	pushMatcher, err := rsync.NewExclusionMatcher(false, h.options.useGitIgnore)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionIgnoreRulesFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the push exclusions").String())
+	}
+	fetchMatcher, err := rsync.NewExclusionMatcher(true, h.options.useGitIgnore)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionIgnoreRulesFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the fetch exclusions").String())
+	}
+
+	//the watcher filters the events with its exclusions and then pushes the changed files with the push exclusions
+	h.exclusion.UseGitIgnore = h.options.useGitIgnore
+	statusOf := func(relPath string) (status ignoreStatus, err error) {
+		status.path = relPath
+		if status.push, err = pushMatcher.Explain(relPath); err != nil {
+			return status, err
+		}
+		if status.fetch, err = fetchMatcher.Explain(relPath); err != nil {
+			return status, err
+		}
+		if status.watch, err = h.exclusion.Explain(relPath); err != nil {
+			return status, err
+		}
+		if status.watch.Included {
+			status.watch = status.push
+		}
+		return status, nil
+	}
+
+	if !h.list {
+		return h.check(args, statusOf)
+	}
+	return h.ls(args, statusOf)
+}
+
+func (h *IgnoreHandle) check(args []string, statusOf func(string) (ignoreStatus, error)) (suggestion string, err error) {
+	for _, arg := range args {
+		relPath, err := projectRelativePath(arg)
+		if err != nil {
+			return err.Error(), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("error when checking the path %s", arg)).String())
+		}
+		status, err := statusOf(relPath)
+		if err != nil {
+			return err.Error(), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("error when checking the path %s", arg)).String())
+		}
+		fmt.Fprintln(h.writer, status.path)
+		fmt.Fprintf(h.writer, "  push:  %s\n", describeDecision(status.path, status.push))
+		fmt.Fprintf(h.writer, "  fetch: %s\n", describeDecision(status.path, status.fetch))
+		fmt.Fprintf(h.writer, "  watch: %s\n", describeDecision(status.path, status.watch))
+	}
+	return "", nil
+}
+
+func (h *IgnoreHandle) ls(args []string, statusOf func(string) (ignoreStatus, error)) (suggestion string, err error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	w := tabwriter.NewWriter(h.writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tPUSH\tFETCH\tWATCH\tRULE")
+	for _, arg := range args {
+		dir, err := projectRelativePath(arg)
+		if err != nil {
+			return err.Error(), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("error when listing the directory %s", arg)).String())
+		}
+		err = filepath.Walk(filepath.FromSlash(dir), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath := filepath.ToSlash(path)
+			if relPath == "." {
+				return nil
+			}
+			status, err := statusOf(relPath)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", relPath, yesNo(status.push.Included), yesNo(status.fetch.Included), yesNo(status.watch.Included), describeRules(status))
+
+			//the content of a directory excluded in every direction is never synchronized
+			excluded := !status.push.Included && !status.fetch.Included && !status.watch.Included
+			if info.IsDir() && excluded && !h.options.all {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Sprintf(msgs.SuggestionIgnoreRulesFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when listing the directory %s", arg)).String())
+		}
+	}
+	return "", w.Flush()
+}
+
+//IgnorePresetHandle adds the exclusions of the framework presets to the exclusion file
+type IgnorePresetHandle struct {
+	writer    io.Writer
+	exclusion *monitor.Exclusion
+}
+
+//Validate checks that the presets exist
+func (h *IgnorePresetHandle) Validate(args []string) error {
+	names := strings.Join(monitor.ExclusionPresetNames(), ", ")
+	if len(args) == 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.IgnorePresetNameEmpty, names)).String())
+	}
+	for _, name := range args {
+		if _, ok := monitor.FindExclusionPreset(name); !ok {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.IgnorePresetNotFound, name, names)).String())
+		}
+	}
+	return nil
+}
+
+//Handle writes the default exclusions, if the exclusion file doesn't exist yet, and the exclusions of the presets
+func (h *IgnorePresetHandle) Handle(args []string) (suggestion string, err error) {
+	_, err = h.exclusion.WriteDefaultExclusionsToFile()
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionWriteDefaultExclusionFileFailed, monitor.CustomExclusionsFile, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, "write to the default exclusion file before adding the presets has failed").String())
+	}
+	for _, name := range args {
+		_, err = h.exclusion.AddPreset(name)
+		if err != nil {
+			return fmt.Sprintf(msgs.SuggestionWriteDefaultExclusionFileFailed, monitor.CustomExclusionsFile, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("adding the exclusions of the preset %s has failed", name)).String())
+		}
+		fmt.Fprintf(h.writer, "The exclusions of the %s preset have been added to %s\n", strings.ToLower(name), monitor.CustomExclusionsFile)
+	}
+	return "", nil
+}
+
+//projectRelativePath returns the slash separated path relative to the project directory
+func projectRelativePath(path string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := filepath.Rel(cwd, absPath)
+	if err != nil {
+		return "", err
+	}
+	relPath = filepath.ToSlash(relPath)
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", fmt.Errorf("the path %s is outside of the project directory %s", path, cwd)
+	}
+	return relPath, nil
+}
+
+//describeDecision explains the decision taken for the path
+func describeDecision(path string, decision pattern.Decision) string {
+	if decision.Rule == "" {
+		return "included, no rule matches"
+	}
+	description := fmt.Sprintf("excluded by %s", describeRule(decision))
+	if decision.Included {
+		description = fmt.Sprintf("included by %s", describeRule(decision))
+	}
+	if decision.Path != path {
+		description += fmt.Sprintf(" matching the parent directory %s", decision.Path)
+	}
+	return description
+}
+
+//describeRules returns the rules that decided the status of the path, the fetch rule is shown only if it is different
+func describeRules(status ignoreStatus) string {
+	push, fetch := "", ""
+	if status.push.Rule != "" {
+		push = describeRule(status.push)
+	}
+	if status.fetch.Rule != "" {
+		fetch = describeRule(status.fetch)
+	}
+	if fetch == "" || fetch == push {
+		return push
+	}
+	if push == "" {
+		return "fetch: " + fetch
+	}
+	return push + ", fetch: " + fetch
+}
+
+func describeRule(decision pattern.Decision) string {
+	if decision.Source == "" {
+		return fmt.Sprintf("%q", decision.Rule)
+	}
+	return fmt.Sprintf("%q in %s", decision.Rule, decision.Source)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -2,9 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
-	"strconv"
+	"regexp"
 	"strings"
 	"time"
 
@@ -13,13 +14,14 @@ import (
 	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
 	cperrors "github.com/continuouspipe/remote-environment-client/errors"
 	"github.com/continuouspipe/remote-environment-client/kubectlapi"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/logs"
 	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
 	msgs "github.com/continuouspipe/remote-environment-client/messages"
 	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/util/rotatefile"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	kubectlcmd "k8s.io/kubernetes/pkg/kubectl/cmd"
-	kubectlcmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/api"
 )
 
 //LogsCmdName is the command name identifier
@@ -29,8 +31,11 @@ func NewLogsCmd() *cobra.Command {
 	settings := config.C
 	handler := &LogsCmdHandle{}
 	handler.kubeCtlInit = kubectlapi.NewKubeCtlInit()
+	handler.streamer = logs.NewKubeLogsStreamer()
+	handler.writer = os.Stdout
+	handler.errWriter = os.Stderr
 	command := &cobra.Command{
-		Use:     LogsCmdName,
+		Use:     LogsCmdName + " [services...]",
 		Aliases: []string{"lo"},
 		Short:   msgs.LogsCommandShortDescription,
 		Long:    msgs.LogsCommandLongDescription,
@@ -80,26 +85,58 @@ func NewLogsCmd() *cobra.Command {
 
 	//used to find the targed pod
 	command.PersistentFlags().StringVarP(&handler.environment, config.KubeEnvironmentName, "e", environment, "The full remote environment name")
-	command.PersistentFlags().StringVarP(&handler.service, config.Service, "s", service, "The service to use (e.g.: web, mysql)")
+	command.PersistentFlags().StringSliceVarP(&handler.services, config.Service, "s", []string{service}, "The service to use (e.g.: web, mysql), the logs of several services are merged when they are separated by commas (e.g.: -s web,worker)")
+	command.PersistentFlags().BoolVar(&handler.all, "all", false, "Merge the logs of all the running pods of the environment")
+	handler.addPodSelectionFlags(command.PersistentFlags())
+	handler.addMultiplePodsFlags(command.PersistentFlags())
+	handler.addContainerFlag(command.PersistentFlags())
 
 	command.PersistentFlags().DurationVar(&handler.since, "since", 0, "Only return logs newer than a relative duration like 5s, 2m, or 3h. Defaults to all logs. Only one of since-time / since may be used.")
 	command.PersistentFlags().Int64Var(&handler.tail, "tail", -1, "Lines of recent log file to display. Defaults to -1, showing all log lines.")
 	command.PersistentFlags().BoolVarP(&handler.follow, "follow", "f", false, "Specify if the logs should be streamed.")
 	command.PersistentFlags().BoolVarP(&handler.previous, "previous", "p", false, "If true, print the logs for the previous instance of the container in a pod if it exists.")
+	command.PersistentFlags().StringVar(&handler.sinceTime, "since-time", "", "Only return logs after a specific date (RFC3339, e.g.: 2017-06-01T10:00:00Z). Defaults to all logs. Only one of since-time / since may be used.")
+
+	//filter and format the lines
+	command.PersistentFlags().StringVar(&handler.grep, "grep", "", "Only print the lines matching the regular expression, as they are printed (e.g.: --grep 'Login (failed|succeeded)')")
+	command.PersistentFlags().StringVar(&handler.level, "level", "", "Only print the lines of this level or of a more severe one, the lines without level are left out (e.g.: --level warning), the levels are "+strings.Join(logLevels, ", "))
+	command.PersistentFlags().StringSliceVar(&handler.fields, "fields", nil, "The fields of the JSON lines to print, the fields of objects are selected with a dot (e.g.: --fields level_name,message,context.user)")
+	command.PersistentFlags().BoolVar(&handler.raw, "raw", false, "Print the JSON lines as they are written instead of formatting them")
+
+	//save the lines
+	command.PersistentFlags().StringVar(&handler.save, "save", "", "Save a copy of the printed lines in the file, which is rotated when it gets too big")
+	command.PersistentFlags().Int64Var(&handler.saveMaxSize, "save-max-size", 100, "The size in megabytes of the file of --save that makes it rotate")
+	command.PersistentFlags().IntVar(&handler.saveMaxFiles, "save-max-files", 5, "The number of rotated files of --save that are kept, as file.1, file.2...")
 	return command
 }
 
 type LogsCmdHandle struct {
+	podSelection
 	environment string
-	service     string
+	services    []string
+	//all merges the logs of every running pod of the environment
+	all         bool
 	username    string
 	apiKey      string
 	conf        config.ConfigProvider
 	kubeCtlInit kubectlapi.KubeCtlInitializer
+	streamer    logs.Streamer
+	writer      io.Writer
+	errWriter   io.Writer
 	since       time.Duration
+	sinceTime   string
 	tail        int64
 	follow      bool
 	previous    bool
+	grep        string
+	level       string
+	fields      []string
+	raw         bool
+	filter      logFilter
+	//save is the file where the printed lines are saved, rotated when saveMaxSize megabytes are written
+	save         string
+	saveMaxSize  int64
+	saveMaxFiles int
 }
 
 // Complete verifies command line arguments and loads data from the command environment
@@ -107,8 +144,26 @@ func (h *LogsCmdHandle) Complete(cmd *cobra.Command, argsIn []string, settings *
 	if h.environment == "" {
 		h.environment = settings.GetStringQ(config.KubeEnvironmentName)
 	}
-	if h.service == "" {
-		h.service = settings.GetStringQ(config.Service)
+	var services []string
+	for _, service := range h.services {
+		if service = strings.TrimSpace(service); service != "" {
+			services = append(services, service)
+		}
+	}
+	//the services given as arguments replace the default service or are added to the ones of the flag
+	if len(argsIn) > 0 && (cmd == nil || !cmd.Flags().Changed(config.Service)) {
+		services = nil
+	}
+	for _, service := range argsIn {
+		if service = strings.TrimSpace(service); service != "" {
+			services = append(services, service)
+		}
+	}
+	h.services = services
+	if len(h.services) == 0 {
+		if service := settings.GetStringQ(config.Service); service != "" {
+			h.services = []string{service}
+		}
 	}
 	if h.username == "" {
 		h.username = settings.GetStringQ(config.Username)
@@ -123,52 +178,115 @@ func (h *LogsCmdHandle) Validate() error {
 	if len(strings.Trim(h.environment, " ")) == 0 {
 		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.EnvironmentSpecifiedEmpty).String())
 	}
-	if len(strings.Trim(h.service, " ")) == 0 {
+	if len(h.services) == 0 && !h.all {
 		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ServiceSpecifiedEmpty).String())
 	}
-	return nil
+	if (h.all || len(h.services) > 1) && (h.pod != "" || h.replicaIndex >= 0 || h.selector != "") {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.LogsAggregationConflict).String())
+	}
+	if h.since > 0 && h.sinceTime != "" {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.LogsSinceConflict).String())
+	}
+	if _, err := time.Parse(time.RFC3339, h.sinceTime); h.sinceTime != "" && err != nil {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.LogsSinceTimeInvalid, h.sinceTime)).String())
+	}
+	if _, err := regexp.Compile(h.grep); err != nil {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.LogsGrepInvalid, h.grep, err.Error())).String())
+	}
+	if h.level != "" && logLevelIndex(h.level) < 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.LogsLevelInvalid, h.level, strings.Join(logLevels, ", "))).String())
+	}
+	if h.save != "" && (h.saveMaxSize <= 0 || h.saveMaxFiles < 0) {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.LogsSaveRotationInvalid).String())
+	}
+	return h.validateSelection()
+}
+
+//newFilter returns the filter of the flags, they are checked by Validate
+func (h *LogsCmdHandle) newFilter() logFilter {
+	filter := newLogFilter()
+	if h.grep != "" {
+		filter.grep = regexp.MustCompile(h.grep)
+	}
+	if h.level != "" {
+		filter.level = logLevelIndex(h.level)
+	}
+	filter.fields = h.fields
+	filter.raw = h.raw
+	return filter
+}
+
+//uncoloredWriter removes the colors of the lines it writes
+type uncoloredWriter struct {
+	writer io.Writer
 }
 
-// Handle set the flags in the kubeclt logs handle command and executes it
+var colorCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func (w uncoloredWriter) Write(p []byte) (int, error) {
+	_, err := w.writer.Write(colorCodes.ReplaceAll(p, nil))
+	return len(p), err
+}
+
+// Handle prints the logs of the pod, the logs of several pods are merged. The lines are filtered and the JSON lines
+// are printed as text, a copy of the lines is saved in the file of --save
 func (h *LogsCmdHandle) Handle(args []string, podsFinder pods.Finder, podsFilter pods.Filter) (suggestion string, err error) {
-	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
-	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
+	h.filter = h.newFilter()
+	if h.save != "" {
+		file, err := rotatefile.NewWriter(h.save, h.saveMaxSize*1024*1024, h.saveMaxFiles)
+		if err != nil {
+			return fmt.Sprintf(msgs.LogsSaveFailed, h.save, err.Error()), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.LogsSaveFailed, h.save, err.Error())).String())
+		}
+		defer file.Close()
+		h.writer = io.MultiWriter(h.writer, uncoloredWriter{file})
 	}
 
-	allPods, err := podsFinder.FindAll(user, apiKey, addr, h.environment)
-	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+	if h.aggregated() {
+		return h.handleAggregated(podsFinder, podsFilter)
 	}
 
-	pod := podsFilter.List(*allPods).ByService(h.service).ByStatus("Running").ByStatusReason("Running").First()
-	if pod == nil {
-		return fmt.Sprintf(msgs.SuggestionRunningPodNotFound, h.service, h.environment, config.AppName, "bash", session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, h.service)).String())
+	target := podTarget{h.environment, h.services[0], h.podSelection}
+	pod, container, _, suggestion, err := target.findPod(h.kubeCtlInit, podsFinder, podsFilter, LogsCmdName)
+	if err != nil {
+		return suggestion, err
 	}
 
 	cplogs.V(5).Infof("getting container logs for environment %s, pod %s", h.environment, pod.GetName())
 	cplogs.Flush()
 
-	clientConfig := kubectlapi.GetNonInteractiveDeferredLoadingClientConfig(user, apiKey, addr, h.environment)
-	f := kubectlcmdutil.NewFactory(clientConfig)
-	kubeCmdLogs := kubectlcmd.NewCmdLogs(f, os.Stdout)
-
-	kubeCmdLogs.Flags().Set("since", h.since.String())
-	kubeCmdLogs.Flags().Set("tail", strconv.FormatInt(h.tail, 10))
-	kubeCmdLogs.Flags().Set("follow", strconv.FormatBool(h.follow))
-	kubeCmdLogs.Flags().Set("previous", strconv.FormatBool(h.previous))
-
-	args = append([]string{pod.GetName()}, args...)
+	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
+	}
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+	source := logSource{service: h.services[0], pod: pod.GetName(), container: container}
+	printer := newLogAggregator(h.writer, h.errWriter, []logSource{source}, func(source logSource, options api.PodLogOptions) (io.ReadCloser, error) {
+		options.Container = source.container
+		return h.streamer.Stream(user, apiKey, addr, h.environment, source.pod, options)
+	})
+	printer.filter = h.filter
+	printer.prefixed = false
 
-	o := &kubectlcmd.LogsOptions{}
-	o.Complete(f, os.Stdout, kubeCmdLogs, args)
-	if err := o.Validate(); err != nil {
-		return "", kubectlcmdutil.UsageError(kubeCmdLogs, err.Error())
+	//the followed logs of the pod are read again when their stream ends, until the pod is deleted
+	if h.follow {
+		printer.followed = true
+		printer.follow(source, h.logOptions())
+		printer.wait()
+		if printer.failed > 0 {
+			err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf(msgs.LogsStreamsFailed, printer.failed, 1)).String())
+			return err.Error(), err
+		}
+		return "", nil
 	}
-	_, err = o.RunLogs()
+
+	err = printer.scan(source, h.logOptions(), func(line timestampedLine) {
+		fmt.Fprintln(h.writer, line.line)
+	})
 	if err != nil {
-		return "", err
+		message := fmt.Sprintf(msgs.LogsStreamFailed, source.name(), err.Error())
+		return message, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, message).String())
 	}
-
 	return "", nil
 }
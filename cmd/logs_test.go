@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+func TestLogsCmdHandle_LogSources(t *testing.T) {
+	newPod := func(name string, labels map[string]string, containers ...string) api.Pod {
+		pod := newTargetPod(name, containers...)
+		pod.Labels = labels
+		pod.Status.Phase = api.PodRunning
+		return pod
+	}
+	newService := func(name string) api.Service {
+		service := api.Service{}
+		service.Name = name
+		service.Spec.Selector = map[string]string{"component-identifier": name}
+		return service
+	}
+	pending := newPod("web-3", map[string]string{"component-identifier": "web"}, "web")
+	pending.Status.Phase = api.PodPending
+	podList := []api.Pod{
+		newPod("web-2", map[string]string{"component-identifier": "web"}, "web", "nginx"),
+		newPod("web-1", map[string]string{"component-identifier": "web"}, "web", "nginx"),
+		newPod("worker-1", map[string]string{"component-identifier": "worker"}, "worker"),
+		newPod("mysql-1", map[string]string{"component-identifier": "mysql", "tier": "db"}, "mysql"),
+		newPod("migration-1", map[string]string{"tier": "db"}, "migration"),
+		pending,
+	}
+	serviceList := api.ServiceList{Items: []api.Service{newService("web"), newService("worker"), newService("mysql")}}
+
+	tests := []struct {
+		scenario string
+		handle   LogsCmdHandle
+		expected []string
+	}{
+		{
+			"the containers of the running pods of the services",
+			LogsCmdHandle{services: []string{"worker", "web"}},
+			[]string{"web/web-1/web", "web/web-1/nginx", "web/web-2/web", "web/web-2/nginx", "worker/worker-1"},
+		},
+		{
+			"only the container of the flag",
+			LogsCmdHandle{services: []string{"worker", "web"}, podSelection: podSelection{container: "nginx"}},
+			[]string{"web/web-1/nginx", "web/web-2/nginx"},
+		},
+		{
+			"the pods matching the selector, with or without service",
+			LogsCmdHandle{services: []string{"web"}, podSelection: podSelection{selector: "tier=db"}},
+			[]string{"migration-1", "mysql/mysql-1"},
+		},
+		{
+			"all the running pods of the environment",
+			LogsCmdHandle{all: true},
+			[]string{"migration-1", "mysql/mysql-1", "web/web-1/web", "web/web-1/nginx", "web/web-2/web", "web/web-2/nginx", "worker/worker-1"},
+		},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, source := range test.handle.logSources(pods.NewKubePodsFilter(), podList, serviceList) {
+			names = append(names, source.name())
+		}
+		assert.Equal(t, test.expected, names, test.scenario)
+	}
+}
+
+func TestLogsCmdHandle_Validate(t *testing.T) {
+	assert.Nil(t, (&LogsCmdHandle{environment: "dev", services: []string{"web", "worker"}, podSelection: podSelection{replicaIndex: -1, allPods: true}}).Validate())
+	assert.Nil(t, (&LogsCmdHandle{environment: "dev", all: true, podSelection: podSelection{replicaIndex: -1}}).Validate())
+	assert.NotNil(t, (&LogsCmdHandle{environment: "dev", services: []string{"web", "worker"}, podSelection: podSelection{replicaIndex: 0}}).Validate(), "a single pod is chosen with the replica index")
+	assert.NotNil(t, (&LogsCmdHandle{environment: "dev", all: true, podSelection: podSelection{replicaIndex: -1, selector: "app=web"}}).Validate())
+}
+
+func TestLogsCmdHandle_Complete(t *testing.T) {
+	tests := []struct {
+		scenario string
+		flags    []string
+		expected []string
+	}{
+		{"the arguments replace the default service", []string{"mysql", "worker"}, []string{"mysql", "worker"}},
+		{"the arguments are added to the services of the flag", []string{"--service", "web", "mysql"}, []string{"web", "mysql"}},
+		{"the default service without argument", nil, []string{"web"}},
+	}
+	for _, test := range tests {
+		handler := &LogsCmdHandle{environment: "dev"}
+		command := &cobra.Command{}
+		command.Flags().StringSliceVarP(&handler.services, config.Service, "s", []string{"web"}, "")
+		assert.Nil(t, command.ParseFlags(test.flags))
+		handler.Complete(command, command.Flags().Args(), config.NewConfig())
+		assert.Equal(t, test.expected, handler.services, test.scenario)
+	}
+}
+
+func TestLogsCmdHandle_Aggregated(t *testing.T) {
+	tests := []struct {
+		scenario string
+		handle   LogsCmdHandle
+		expected bool
+	}{
+		{"the logs of a pod of the service", LogsCmdHandle{services: []string{"web"}, podSelection: podSelection{replicaIndex: -1}}, false},
+		{"the followed logs of all the pods of the service", LogsCmdHandle{services: []string{"web"}, follow: true, podSelection: podSelection{replicaIndex: -1}}, true},
+		{"the followed logs of the pod of the flag", LogsCmdHandle{services: []string{"web"}, follow: true, podSelection: podSelection{replicaIndex: -1, pod: "web-1"}}, false},
+		{"the followed logs of the pod of the replica index", LogsCmdHandle{services: []string{"web"}, follow: true, podSelection: podSelection{replicaIndex: 1}}, false},
+		{"the logs of several services", LogsCmdHandle{services: []string{"web", "worker"}, podSelection: podSelection{replicaIndex: -1}}, true},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.handle.aggregated(), test.scenario)
+	}
+}
+
+func TestLogAggregator_PrintMerged(t *testing.T) {
+	color.NoColor = true
+	logs := map[string]string{
+		"web-1": "2017-06-01T10:00:00.000000001Z GET /\n2017-06-01T10:00:02Z GET /login\n",
+		"web-2": "2017-06-01T10:00:01Z GET /about\n2017-06-01T10:00:02Z GET /contact\n",
+	}
+	sources := []logSource{{service: "web", pod: "web-1"}, {service: "web", pod: "web-2"}, {service: "worker", pod: "worker-1"}}
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	aggregator := newLogAggregator(out, errOut, sources, func(source logSource, options api.PodLogOptions) (io.ReadCloser, error) {
+		assert.True(t, options.Timestamps, "the lines are timestamped to be merged")
+		content, ok := logs[source.pod]
+		if !ok {
+			return nil, errors.New("container not found")
+		}
+		return ioutil.NopCloser(strings.NewReader(content)), nil
+	})
+	failed := aggregator.printMerged(sources, api.PodLogOptions{Timestamps: true})
+
+	assert.Equal(t, `[web/web-1      ] 2017-06-01T10:00:00.000000001Z GET /
+[web/web-2      ] 2017-06-01T10:00:01Z GET /about
+[web/web-1      ] 2017-06-01T10:00:02Z GET /login
+[web/web-2      ] 2017-06-01T10:00:02Z GET /contact
+`, out.String())
+	assert.Equal(t, "The logs of worker/worker-1 could not be read: container not found\n", errOut.String())
+	assert.Equal(t, 1, failed, "the sources whose logs could not be read are counted")
+}
+
+func TestLogAggregator_Follow(t *testing.T) {
+	color.NoColor = true
+	minLogReconnectBackoff = time.Millisecond
+	defer func() { minLogReconnectBackoff = time.Second }()
+	logs := []string{
+		"2017-06-01T10:00:00Z starting\n2017-06-01T10:00:01.5Z GET /\n",
+		"2017-06-01T10:00:01.5Z GET /\n2017-06-01T10:00:02Z GET /about\n",
+	}
+	source := logSource{service: "web", pod: "web-1"}
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	var sinceTimes []*unversioned.Time
+	aggregator := newLogAggregator(out, errOut, []logSource{source}, func(source logSource, options api.PodLogOptions) (io.ReadCloser, error) {
+		sinceTimes = append(sinceTimes, options.SinceTime)
+		if len(sinceTimes) > len(logs) {
+			return nil, errors.New("pod not found")
+		}
+		return ioutil.NopCloser(strings.NewReader(logs[len(sinceTimes)-1])), nil
+	})
+	aggregator.follow(source, api.PodLogOptions{Follow: true, Timestamps: true})
+	for streamed := true; streamed; {
+		time.Sleep(time.Millisecond)
+		aggregator.mutex.Lock()
+		streamed = aggregator.streamed[source.name()]
+		aggregator.mutex.Unlock()
+	}
+
+	assert.Equal(t, `[web/web-1] 2017-06-01T10:00:00Z starting
+[web/web-1] 2017-06-01T10:00:01.5Z GET /
+[web/web-1] 2017-06-01T10:00:02Z GET /about
+`, out.String(), "the lines sent again once reconnected are not printed twice")
+	assert.Nil(t, sinceTimes[0])
+	assert.Equal(t, time.Date(2017, 6, 1, 10, 0, 1, 500000000, time.UTC), sinceTimes[1].Time, "the stream is opened again since the last line")
+	assert.Equal(t, "The logs of web/web-1 could not be read: pod not found\n", errOut.String())
+}
+
+func TestLogAggregator_FollowSingleSource(t *testing.T) {
+	color.NoColor = true
+	source := logSource{service: "web", pod: "web-1"}
+	out := &bytes.Buffer{}
+	aggregator := newLogAggregator(out, ioutil.Discard, []logSource{source}, func(source logSource, options api.PodLogOptions) (io.ReadCloser, error) {
+		if options.SinceTime != nil {
+			return nil, errors.New("pod not found")
+		}
+		return ioutil.NopCloser(strings.NewReader("2017-06-01T10:00:00Z starting\n")), nil
+	})
+	aggregator.followed = true
+	minLogReconnectBackoff = time.Millisecond
+	defer func() { minLogReconnectBackoff = time.Second }()
+	aggregator.follow(source, api.PodLogOptions{Follow: true, Timestamps: true})
+	aggregator.wait()
+
+	assert.Equal(t, "starting\n", out.String(), "the lines of the only source left are printed as they are")
+	assert.Equal(t, 0, aggregator.failed, "the stream was opened before the pod was deleted")
+}
+
+func TestLogFilter_Apply(t *testing.T) {
+	monolog := `{"message":"Login failed","context":{"user":"bob"},"level":400,"level_name":"ERROR","channel":"security","datetime":{"date":"2017-06-01 10:00:00.000000","timezone_type":3,"timezone":"UTC"},"extra":[]}`
+	info := `{"message":"Matched route","context":[],"level":200,"level_name":"INFO","channel":"request","datetime":"2017-06-01T10:00:01+00:00","extra":{"route":"home"}}`
+
+	tests := []struct {
+		scenario string
+		filter   func(filter *logFilter)
+		line     string
+		expected string
+		printed  bool
+	}{
+		{"the JSON lines are printed as text", func(filter *logFilter) {}, monolog, "2017-06-01 10:00:00.000000 ERROR security: Login failed context={\"user\":\"bob\"}", true},
+		{"the other fields are printed when not empty", func(filter *logFilter) {}, info, "2017-06-01T10:00:01+00:00 INFO request: Matched route extra={\"route\":\"home\"}", true},
+		{"the selected fields", func(filter *logFilter) { filter.fields = []string{"level_name", "context.user", "missing"} }, monolog, "level_name=ERROR context.user=bob", true},
+		{"the raw JSON lines", func(filter *logFilter) { filter.raw = true }, monolog, monolog, true},
+		{"the JSON lines of a more severe level", func(filter *logFilter) { filter.level = logLevelIndex("warn") }, monolog, "2017-06-01 10:00:00.000000 ERROR security: Login failed context={\"user\":\"bob\"}", true},
+		{"the JSON lines of a less severe level", func(filter *logFilter) { filter.level = logLevelIndex("warning") }, info, "", false},
+		{"the level of the text lines", func(filter *logFilter) { filter.level = logLevelIndex("error") }, "[2017-06-01 10:00:00] app.CRITICAL: Uncaught exception", "[2017-06-01 10:00:00] app.CRITICAL: Uncaught exception", true},
+		{"the level of the key value lines", func(filter *logFilter) { filter.level = logLevelIndex("error") }, `time="2017-06-01T10:00:00Z" level=info msg="GET /error"`, "", false},
+		{"the text lines without level", func(filter *logFilter) { filter.level = logLevelIndex("debug") }, "GET / 200", "", false},
+		{"the lines matching the expression as printed", func(filter *logFilter) { filter.grep = regexp.MustCompile("security: Login") }, monolog, "2017-06-01 10:00:00.000000 ERROR security: Login failed context={\"user\":\"bob\"}", true},
+		{"the lines not matching the expression", func(filter *logFilter) { filter.grep = regexp.MustCompile("^GET") }, "POST /login 302", "", false},
+		{"the lines matching the expression without their colors", func(filter *logFilter) { filter.grep = regexp.MustCompile("ERROR security") }, "\x1b[31mERROR\x1b[0m security: Login failed", "\x1b[31mERROR\x1b[0m security: Login failed", true},
+	}
+	for _, test := range tests {
+		filter := newLogFilter()
+		test.filter(&filter)
+		line, printed := filter.apply(test.line)
+		assert.Equal(t, test.printed, printed, test.scenario)
+		assert.Equal(t, test.expected, line, test.scenario)
+	}
+}
+
+func TestUncoloredWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	uncoloredWriter{out}.Write([]byte("\x1b[36m[web/web-1]\x1b[0m \x1b[31mERROR\x1b[0m Login failed\n"))
+	assert.Equal(t, "[web/web-1] ERROR Login failed\n", out.String())
+}
@@ -0,0 +1,498 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+//logColors are the colors of the prefixes, each container streamed takes the next one
+var logColors = []color.Attribute{color.FgCyan, color.FgGreen, color.FgYellow, color.FgMagenta, color.FgBlue, color.FgHiCyan, color.FgHiGreen, color.FgHiYellow, color.FgHiMagenta, color.FgHiBlue}
+
+//logSource is a container of a pod whose logs are aggregated
+type logSource struct {
+	service   string
+	pod       string
+	container string
+	//namedContainer is true when the container is part of the name of the source, the pod having several containers
+	namedContainer bool
+}
+
+//name returns service/pod/container, the service is left out for the pods without service and the container for
+//the pods with a single container
+func (s logSource) name() string {
+	name := s.pod
+	if s.service != "" {
+		name = s.service + "/" + name
+	}
+	if s.namedContainer {
+		name = name + "/" + s.container
+	}
+	return name
+}
+
+//aggregated returns true when the logs of several pods are printed, the followed logs of a service are those of all
+//its pods, including the ones replacing the pods that stop
+func (h *LogsCmdHandle) aggregated() bool {
+	return h.all || len(h.services) > 1 || h.multiplePods() || (h.follow && h.pod == "" && h.replicaIndex < 0)
+}
+
+//handleAggregated prints the logs of every container of the pods of the services, of the environment with --all or
+//matching the selector. The lines are prefixed with the name of their container and with their timestamp, they are
+//merged in the order of their timestamps or, when the logs are followed, printed as they are written and prefixed only
+//while several containers are streamed
+func (h *LogsCmdHandle) handleAggregated(podsFinder pods.Finder, podsFilter pods.Filter) (suggestion string, err error) {
+	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
+	}
+	podList, err := podsFinder.FindAll(user, apiKey, addr, h.environment)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+	}
+	serviceList, err := podsFinder.FindServices(user, apiKey, addr, h.environment)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+	}
+
+	//with --wait the followed logs start with the first pod created
+	sources := h.logSources(podsFilter, podList.Items, *serviceList)
+	if len(sources) == 0 && !(h.follow && h.wait > 0) {
+		err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, h.noLogSourceMessage()).String())
+		return err.Error(), err
+	}
+	cplogs.V(5).Infof("getting the logs of the containers %v of the environment %s", sources, h.environment)
+	cplogs.Flush()
+
+	aggregator := newLogAggregator(h.writer, h.errWriter, sources, func(source logSource, options api.PodLogOptions) (io.ReadCloser, error) {
+		options.Container = source.container
+		return h.streamer.Stream(user, apiKey, addr, h.environment, source.pod, options)
+	})
+	aggregator.filter = h.filter
+	aggregator.followed = h.follow
+	options := h.logOptions()
+	if !h.follow {
+		if failed := aggregator.printMerged(sources, options); failed > 0 {
+			err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf(msgs.LogsStreamsFailed, failed, len(sources))).String())
+			return err.Error(), err
+		}
+		return "", nil
+	}
+
+	for _, source := range sources {
+		aggregator.follow(source, options)
+	}
+	//the pods created once the logs are followed are streamed from their start
+	options.SinceSeconds = nil
+	options.SinceTime = nil
+	options.TailLines = nil
+
+	followPods := func(pods []api.Pod) bool {
+		for _, source := range h.logSources(podsFilter, pods, *serviceList) {
+			aggregator.follow(source, options)
+		}
+		return true
+	}
+	watcher := podWatch{podsFinder, user, apiKey, addr, h.environment}
+	err = watcher.watch(podList.ResourceVersion, nil, func(event watch.Event, pod api.Pod) bool {
+		switch event.Type {
+		case watch.Deleted:
+			aggregator.stopPod(pod.GetName())
+			return true
+		case watch.Added:
+			//the service may have been created with the pod
+			if services, err := podsFinder.FindServices(user, apiKey, addr, h.environment); err == nil {
+				serviceList = services
+			}
+		}
+		return followPods([]api.Pod{pod})
+	}, func(podList api.PodList) bool {
+		return followPods(podList.Items)
+	})
+	return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+}
+
+//logOptions returns the options of the logs of each container, the lines of several pods are timestamped to be merged
+//and the followed lines to read the logs again from the last line when their stream ends
+func (h *LogsCmdHandle) logOptions() api.PodLogOptions {
+	options := api.PodLogOptions{
+		Follow:     h.follow,
+		Previous:   h.previous,
+		Timestamps: h.aggregated() || h.follow,
+	}
+	if sinceTime, err := time.Parse(time.RFC3339, h.sinceTime); h.sinceTime != "" && err == nil {
+		since := unversioned.NewTime(sinceTime)
+		options.SinceTime = &since
+	}
+	if h.since > 0 {
+		seconds := int64(h.since.Seconds())
+		options.SinceSeconds = &seconds
+	}
+	if h.tail >= 0 {
+		tail := h.tail
+		options.TailLines = &tail
+	}
+	return options
+}
+
+//logSources returns the containers of the running pods of the aggregation sorted by name: the container of the flag,
+//the pods without it are left out, or every container of the pods
+func (h *LogsCmdHandle) logSources(podsFilter pods.Filter, podList []api.Pod, serviceList api.ServiceList) []logSource {
+	list := api.PodList{Items: append([]api.Pod{}, podList...)}
+	running := podsFilter.List(list).ByStatus("Running").ByStatusReason("Running").Items()
+	sort.Sort(podsByName(running))
+
+	var sources []logSource
+	for _, pod := range running {
+		service, ok := h.aggregatedService(podsFilter, pod, serviceList)
+		if !ok {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			if h.container != "" && container.Name != h.container {
+				continue
+			}
+			sources = append(sources, logSource{
+				service:        service,
+				pod:            pod.GetName(),
+				container:      container.Name,
+				namedContainer: len(pod.Spec.Containers) > 1,
+			})
+		}
+	}
+	return sources
+}
+
+//aggregatedService returns the service of the pod when the pod is part of the aggregation
+func (h *LogsCmdHandle) aggregatedService(podsFilter pods.Filter, pod api.Pod, serviceList api.ServiceList) (string, bool) {
+	belongsTo := func(service string) bool {
+		return podsFilter.List(api.PodList{Items: []api.Pod{pod}}).Services(serviceList).ByService(service).First() != nil
+	}
+	serviceOf := func() string {
+		for _, service := range serviceList.Items {
+			if belongsTo(service.GetName()) {
+				return service.GetName()
+			}
+		}
+		return ""
+	}
+
+	if h.selector != "" {
+		if !h.labelSelector().Matches(labels.Set(pod.GetLabels())) {
+			return "", false
+		}
+		return serviceOf(), true
+	}
+	if h.all {
+		return serviceOf(), true
+	}
+	for _, service := range h.services {
+		if belongsTo(service) {
+			return service, true
+		}
+	}
+	return "", false
+}
+
+func (h *LogsCmdHandle) noLogSourceMessage() string {
+	switch {
+	case h.selector != "":
+		return fmt.Sprintf(msgs.NoActivePodsFoundForSelector, h.selector)
+	case h.all:
+		return fmt.Sprintf(msgs.LogsNoRunningPods, h.environment)
+	case h.container != "":
+		return fmt.Sprintf(msgs.LogsContainerNotFound, h.container, strings.Join(h.services, ", "))
+	}
+	return fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, strings.Join(h.services, ", "))
+}
+
+//logAggregator prints the lines of the logs of several containers prefixed with the colored name of their container
+type logAggregator struct {
+	writer    io.Writer
+	errWriter io.Writer
+	open      func(source logSource, options api.PodLogOptions) (io.ReadCloser, error)
+	filter    logFilter
+	//prefixed is false when the logs of a single container are printed, their lines are then printed as they are
+	prefixed bool
+	//followed prefixes the lines only while several sources are streamed
+	followed bool
+	width    int
+	mutex    *gosync.Mutex
+	//running are the streams being followed
+	running *gosync.WaitGroup
+	//failed is the number of followed sources whose logs could not be opened
+	failed int
+	//streamed are the names of the sources being followed
+	streamed map[string]bool
+	//deleted are the names of the pods deleted while their logs were followed, their streams are not reconnected
+	deleted map[string]bool
+	colors  map[string]color.Attribute
+}
+
+func newLogAggregator(writer io.Writer, errWriter io.Writer, sources []logSource, open func(source logSource, options api.PodLogOptions) (io.ReadCloser, error)) *logAggregator {
+	var names []string
+	for _, source := range sources {
+		names = append(names, source.name())
+	}
+	return &logAggregator{
+		writer:    writer,
+		errWriter: errWriter,
+		open:      open,
+		filter:    newLogFilter(),
+		prefixed:  true,
+		width:     prefixWidth(names),
+		mutex:     &gosync.Mutex{},
+		running:   &gosync.WaitGroup{},
+		streamed:  map[string]bool{},
+		deleted:   map[string]bool{},
+		colors:    map[string]color.Attribute{},
+	}
+}
+
+//prefix returns the colored name of the source, a source keeps its color
+func (a *logAggregator) prefix(source logSource) string {
+	if !a.prefixed {
+		return ""
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	attribute, ok := a.colors[source.name()]
+	if !ok {
+		attribute = logColors[len(a.colors)%len(logColors)]
+		a.colors[source.name()] = attribute
+	}
+	return color.New(attribute).Sprint(fmt.Sprintf("[%-*s]", a.width, source.name())) + " "
+}
+
+//timestampedLine is a line of the logs of a source as printed with the time it was written
+type timestampedLine struct {
+	time time.Time
+	line string
+}
+
+type linesByTime []timestampedLine
+
+func (s linesByTime) Len() int           { return len(s) }
+func (s linesByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s linesByTime) Less(i, j int) bool { return s[i].time.Before(s[j].time) }
+
+//printMerged reads the logs of the sources and prints their lines in the order of their timestamps, the lines of a
+//source stay in their order when they have the same timestamp. The sources whose logs could not be read are printed
+//and their number is returned
+func (a *logAggregator) printMerged(sources []logSource, options api.PodLogOptions) (failed int) {
+	logs := make([][]timestampedLine, len(sources))
+	errs := make([]error, len(sources))
+	wg := gosync.WaitGroup{}
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source logSource) {
+			defer wg.Done()
+			errs[i] = a.scan(source, options, func(line timestampedLine) {
+				logs[i] = append(logs[i], line)
+			})
+		}(i, source)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			a.printStreamError(sources[i], err)
+			failed++
+		}
+	}
+
+	var lines []timestampedLine
+	for _, sourceLines := range logs {
+		lines = append(lines, sourceLines...)
+	}
+	sort.Stable(linesByTime(lines))
+	for _, line := range lines {
+		fmt.Fprintln(a.writer, line.line)
+	}
+	return failed
+}
+
+//minLogReconnectBackoff and maxLogReconnectBackoff bound the delay before a followed stream that ended is opened
+//again, the delay doubles at each reconnection until a line is received
+var (
+	minLogReconnectBackoff = time.Second
+	maxLogReconnectBackoff = 30 * time.Second
+)
+
+//follow streams the logs of the source unless they are already streamed. The api server ends the streams after a
+//while and when the container restarts, the stream is then opened again from the time of the last line received
+//until the pod is deleted or its logs can no longer be opened
+func (a *logAggregator) follow(source logSource, options api.PodLogOptions) {
+	a.mutex.Lock()
+	if a.streamed[source.name()] {
+		a.mutex.Unlock()
+		return
+	}
+	a.streamed[source.name()] = true
+	delete(a.deleted, source.pod)
+	a.mutex.Unlock()
+
+	a.running.Add(1)
+	go func() {
+		defer func() {
+			a.mutex.Lock()
+			delete(a.streamed, source.name())
+			a.mutex.Unlock()
+			a.running.Done()
+		}()
+		var last time.Time
+		backoff := minLogReconnectBackoff
+		for reconnected := false; ; reconnected = true {
+			opened := time.Now()
+			stream, err := a.open(source, options)
+			if err != nil {
+				if !a.podDeleted(source) {
+					a.printStreamError(source, err)
+				}
+				if !reconnected {
+					a.mutex.Lock()
+					a.failed++
+					a.mutex.Unlock()
+				}
+				return
+			}
+			err = a.read(source, stream, options, func(line timestampedLine) {
+				//the lines of the second of the last line are sent again once reconnected
+				if !line.time.IsZero() {
+					if !last.IsZero() && !line.time.After(last) {
+						return
+					}
+					last = line.time
+				}
+				backoff = minLogReconnectBackoff
+				a.mutex.Lock()
+				defer a.mutex.Unlock()
+				fmt.Fprintln(a.writer, line.line)
+			})
+			if err != nil && !a.podDeleted(source) {
+				a.printStreamError(source, err)
+			}
+
+			time.Sleep(backoff)
+			if a.podDeleted(source) {
+				return
+			}
+			backoff *= 2
+			if backoff > maxLogReconnectBackoff {
+				backoff = maxLogReconnectBackoff
+			}
+			since := last
+			if since.IsZero() {
+				since = opened
+			}
+			sinceTime := unversioned.NewTime(since)
+			options.SinceTime = &sinceTime
+			options.SinceSeconds = nil
+			options.TailLines = nil
+			cplogs.V(5).Infof("the logs of %s ended, reading them again since %s", source.name(), since)
+		}
+	}()
+}
+
+//wait returns once the followed streams have ended
+func (a *logAggregator) wait() {
+	a.running.Wait()
+}
+
+//stopPod stops reconnecting the streams of the containers of the deleted pod
+func (a *logAggregator) stopPod(pod string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.deleted[pod] = true
+}
+
+func (a *logAggregator) podDeleted(source logSource) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.deleted[source.pod]
+}
+
+//scan reads the logs of the source and calls print with each line kept by the filter, prefixed with the name of the
+//source and with its timestamp, it returns the error of the opening or of the reading of the logs
+func (a *logAggregator) scan(source logSource, options api.PodLogOptions, print func(line timestampedLine)) error {
+	stream, err := a.open(source, options)
+	if err != nil {
+		return err
+	}
+	return a.read(source, stream, options, print)
+}
+
+//read calls print with each line of the stream kept by the filter and closes the stream once it ends
+func (a *logAggregator) read(source logSource, stream io.ReadCloser, options api.PodLogOptions, print func(line timestampedLine)) error {
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var timestamp time.Time
+		stamp := ""
+		line := scanner.Text()
+		if options.Timestamps {
+			timestamp, stamp, line = splitLogLineTime(line)
+		}
+		line, ok := a.filter.apply(line)
+		if !ok {
+			continue
+		}
+		print(timestampedLine{timestamp, a.decorate(source, stamp) + line})
+	}
+	return scanner.Err()
+}
+
+//decorate returns the prefix and the timestamp printed before a line of the source, nothing when the lines are not
+//prefixed or when the followed source is the only one left
+func (a *logAggregator) decorate(source logSource, stamp string) string {
+	if !a.prefixed {
+		return ""
+	}
+	if a.followed {
+		a.mutex.Lock()
+		several := len(a.streamed) > 1
+		a.mutex.Unlock()
+		if !several {
+			return ""
+		}
+	}
+	return a.prefix(source) + stamp
+}
+
+func (a *logAggregator) printStreamError(source logSource, err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	fmt.Fprintln(a.errWriter, color.New(color.FgRed).Sprint(fmt.Sprintf(msgs.LogsStreamFailed, source.name(), err.Error())))
+}
+
+//splitLogLineTime returns the timestamp the api server adds at the start of the lines, the zero time when it is
+//missing, the timestamp as written followed by its space and the rest of the line
+func splitLogLineTime(line string) (timestamp time.Time, stamp string, rest string) {
+	end := strings.IndexByte(line, ' ')
+	if end < 0 {
+		return time.Time{}, "", line
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, line[:end])
+	if err != nil {
+		return time.Time{}, "", line
+	}
+	return timestamp, line[:end+1], line[end+1:]
+}
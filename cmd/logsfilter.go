@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+//logLevels are the levels of the log lines from the least to the most severe, the ones of Monolog and of the syslog
+var logLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+//logLevelAliases are the other names of the levels used by the loggers
+var logLevelAliases = map[string]string{
+	"trace": "debug",
+	"warn":  "warning",
+	"err":   "error",
+	"crit":  "critical",
+	"fatal": "critical",
+	"panic": "emergency",
+	"emerg": "emergency",
+}
+
+//the level of the text lines is found in level=error, [error] or a word in capitals like in app.ERROR
+var keyedLogLevel = regexp.MustCompile(`(?i)(?:\b(?:level|severity|lvl)=["']?|\[)(trace|debug|info|notice|warn|warning|err|error|crit|critical|fatal|alert|emerg|emergency|panic)\b`)
+var capitalLogLevel = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|CRITICAL|FATAL|ALERT|EMERGENCY)\b`)
+
+//the fields of the JSON lines printed first, in this order, when all the fields are printed
+var jsonTimeFields = []string{"datetime", "time", "timestamp", "@timestamp"}
+var jsonLevelFields = []string{"level_name", "level", "severity", "lvl"}
+var jsonMessageFields = []string{"message", "msg"}
+
+//logLevelIndex returns the index of the level in logLevels, -1 when it is not a level
+func logLevelIndex(level string) int {
+	level = strings.ToLower(level)
+	if alias, ok := logLevelAliases[level]; ok {
+		level = alias
+	}
+	for i, name := range logLevels {
+		if name == level {
+			return i
+		}
+	}
+	return -1
+}
+
+//monologLevelIndex returns the index of the numeric level of Monolog (100 for debug up to 600 for emergency)
+func monologLevelIndex(level float64) int {
+	thresholds := []float64{100, 200, 250, 300, 400, 500, 550, 600}
+	index := 0
+	for i, threshold := range thresholds {
+		if level >= threshold {
+			index = i
+		}
+	}
+	return index
+}
+
+//logFilter selects the lines of the logs and prints the JSON lines as text
+type logFilter struct {
+	//grep selects the lines matching it, as they are printed without their colors
+	grep *regexp.Regexp
+	//level is the index of the least severe level printed, -1 to print the lines whatever their level
+	level int
+	//raw prints the JSON lines as they are written
+	raw bool
+	//fields are the fields of the JSON lines that are printed, all of them when empty
+	fields []string
+}
+
+//newLogFilter returns the filter keeping every line
+func newLogFilter() logFilter {
+	return logFilter{level: -1}
+}
+
+//apply returns the line as printed and false when the line is filtered out
+func (f logFilter) apply(line string) (string, bool) {
+	entry, isJSON := parseJSONLogLine(line)
+
+	if f.level >= 0 {
+		level := -1
+		if isJSON {
+			level = jsonLogLevel(entry)
+		} else {
+			level = textLogLevel(line)
+		}
+		if level < f.level {
+			return "", false
+		}
+	}
+	if isJSON && !f.raw {
+		line = f.formatJSON(entry)
+	}
+	if f.grep != nil && !f.grep.MatchString(colorCodes.ReplaceAllString(line, "")) {
+		return "", false
+	}
+	return line, true
+}
+
+//parseJSONLogLine returns the fields of the line when it is a JSON object
+func parseJSONLogLine(line string) (map[string]interface{}, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(trimmed), &entry); err != nil {
+		return nil, false
+	}
+	return entry, true
+}
+
+func jsonLogLevel(entry map[string]interface{}) int {
+	for _, field := range jsonLevelFields {
+		switch level := entry[field].(type) {
+		case string:
+			if index := logLevelIndex(level); index >= 0 {
+				return index
+			}
+		case float64:
+			return monologLevelIndex(level)
+		}
+	}
+	return -1
+}
+
+func textLogLevel(line string) int {
+	if match := keyedLogLevel.FindStringSubmatch(line); match != nil {
+		return logLevelIndex(match[1])
+	}
+	if match := capitalLogLevel.FindStringSubmatch(line); match != nil {
+		return logLevelIndex(match[1])
+	}
+	return -1
+}
+
+//formatJSON prints the selected fields as field=value or, when no field is selected, the time, the level, the channel
+//and the message of the line followed by the other fields that are not empty
+func (f logFilter) formatJSON(entry map[string]interface{}) string {
+	if len(f.fields) > 0 {
+		var values []string
+		for _, field := range f.fields {
+			if value, ok := jsonField(entry, field); ok {
+				values = append(values, field+"="+jsonText(value))
+			}
+		}
+		return strings.Join(values, " ")
+	}
+
+	var parts []string
+	printed := map[string]bool{}
+	first := func(fields []string) (string, bool) {
+		for _, field := range fields {
+			if value, ok := entry[field]; ok {
+				printed[field] = true
+				return jsonText(value), true
+			}
+		}
+		return "", false
+	}
+	if datetime, ok := first(jsonTimeFields); ok {
+		//the DateTime objects of PHP are serialized with their date and their timezone
+		if object, isObject := entry["datetime"].(map[string]interface{}); isObject && object["date"] != nil {
+			datetime = jsonText(object["date"])
+		}
+		parts = append(parts, datetime)
+	}
+	if level := jsonLogLevel(entry); level >= 0 {
+		first(jsonLevelFields)
+		parts = append(parts, coloredLogLevel(level))
+	}
+	if channel, ok := first([]string{"channel"}); ok {
+		parts = append(parts, channel+":")
+	}
+	if message, ok := first(jsonMessageFields); ok {
+		parts = append(parts, message)
+	}
+	//Monolog prints the level as a number and as a name
+	printed["level"] = printed["level"] || printed["level_name"]
+
+	var others []string
+	for field, value := range entry {
+		if !printed[field] && !isEmptyJSON(value) {
+			others = append(others, field+"="+jsonText(value))
+		}
+	}
+	sort.Strings(others)
+	return strings.Join(append(parts, others...), " ")
+}
+
+func coloredLogLevel(level int) string {
+	name := strings.ToUpper(logLevels[level])
+	switch {
+	case level >= logLevelIndex("error"):
+		return color.New(color.FgRed).Sprint(name)
+	case level == logLevelIndex("warning"):
+		return color.New(color.FgYellow).Sprint(name)
+	}
+	return name
+}
+
+//jsonField returns the value of the field, the fields of the objects are selected with a dot (e.g.: context.user)
+func jsonField(entry map[string]interface{}, field string) (interface{}, bool) {
+	var value interface{} = entry
+	for _, key := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+//jsonText returns the strings as they are and the other values as JSON
+func jsonText(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+//isEmptyJSON returns true for null and for the empty strings, arrays and objects, like the context of Monolog
+func isEmptyJSON(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
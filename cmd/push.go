@@ -6,12 +6,15 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cpapi"
 	"github.com/continuouspipe/remote-environment-client/cplogs"
 	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
 	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/git"
 	"github.com/continuouspipe/remote-environment-client/kubectlapi"
 	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
 	msgs "github.com/continuouspipe/remote-environment-client/messages"
@@ -19,7 +22,9 @@ import (
 	"github.com/continuouspipe/remote-environment-client/sync"
 	"github.com/continuouspipe/remote-environment-client/sync/monitor"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/continuouspipe/remote-environment-client/sync/rsync"
 	"github.com/continuouspipe/remote-environment-client/util"
+	"github.com/continuouspipe/remote-environment-client/util/slice"
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -46,6 +51,11 @@ func NewPushCmd() *cobra.Command {
 	handler := &PushHandle{}
 	handler.qp = util.NewQuestionPrompt()
 	handler.kubeCtlInit = kubectlapi.NewKubeCtlInit()
+	handler.api = cpapi.NewCpAPI()
+	handler.config = settings
+	handler.lsFiles = git.NewLsFiles()
+	handler.gitDiff = git.NewDiff()
+	handler.revParse = git.NewRevParse()
 	handler.writer = os.Stdout
 
 	command := &cobra.Command{
@@ -85,21 +95,38 @@ func NewPushCmd() *cobra.Command {
 	checkErr(err)
 	service, err := settings.GetString(config.Service)
 	checkErr(err)
+	useGitIgnore, err := settings.GetBool(config.UseGitIgnore)
+	checkErr(err)
+	changeDetection, err := settings.GetString(config.ChangeDetection)
+	checkErr(err)
+	backup, err := settings.GetString(config.Backup)
+	checkErr(err)
 
 	command.PersistentFlags().StringVarP(&handler.options.environment, config.KubeEnvironmentName, "e", environment, "The full remote environment name")
 	command.PersistentFlags().StringVarP(&handler.options.service, config.Service, "s", service, "The service to use (e.g.: web, mysql)")
+	handler.options.addPodSelectionFlags(command.PersistentFlags())
+	handler.options.addContainerFlag(command.PersistentFlags())
 	command.PersistentFlags().StringVarP(&handler.options.file, "file", "f", "", "Allows to specify a file that needs to be pushed to the pod")
 	command.PersistentFlags().StringVarP(&handler.options.remoteProjectPath, "remote-project-path", "a", "/app/", "Specify the absolute path to your project folder, by default set to /app/")
 	command.PersistentFlags().BoolVar(&handler.options.rsyncVerbose, "rsync-verbose", false, "Allows to use rsync in verbose mode and debug issues with exclusions")
 	command.PersistentFlags().BoolVar(&handler.options.dryRun, "dry-run", false, "Show what would have been transferred")
 	command.PersistentFlags().BoolVar(&handler.options.delete, "delete", false, "Delete extraneous files from destination directories")
 	command.PersistentFlags().BoolVarP(&handler.options.yall, "yes", "y", false, "Skip warning")
+	command.PersistentFlags().BoolVar(&handler.options.useGitIgnore, config.UseGitIgnore, useGitIgnore, "Exclude the files ignored by git using the .gitignore files of the project")
+	command.PersistentFlags().IntVar(&handler.options.parallel, "parallel", 1, "Number of concurrent transfers used to push the whole project, the project directories are split between them")
+	command.PersistentFlags().BoolVar(&handler.options.gitTracked, "git-tracked", false, "Only push the files tracked by git")
+	command.PersistentFlags().BoolVar(&handler.options.gitChanged, "git-changed", false, "Only push the files changed since the commit deployed in the remote environment and the files not tracked by git")
+	command.PersistentFlags().StringVar(&handler.options.changeDetection, config.ChangeDetection, changeDetection, fmt.Sprintf("Strategy used to find the changed files (%s)", strings.Join(options.ChangeDetectionStrategies(), ", ")))
+	command.PersistentFlags().StringVar(&handler.options.backup, config.Backup, backup, fmt.Sprintf("Where the remote files deleted or overwritten when using --delete are backed up (%s)", strings.Join(options.BackupModes(), ", ")))
+	command.PersistentFlags().BoolVar(&handler.options.noHooks, "no-hooks", false, "Don't run the post-sync commands of the project configuration after the push")
+	addTransferFlags(command, &handler.options.transfer, settings)
 
 	return command
 }
 
 func RunPush(handler *PushHandle, args []string, settings *config.Config) (suggestion string, err error) {
 	exclusion := monitor.NewExclusion()
+	exclusion.UseGitIgnore = handler.options.useGitIgnore
 	_, err = exclusion.WriteDefaultExclusionsToFile()
 	if err != nil {
 		return fmt.Sprintf(msgs.SuggestionWriteDefaultExclusionFileFailed, monitor.CustomExclusionsFile, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, "write to the default exclusion file before push has failed").String())
@@ -127,14 +154,24 @@ func RunPush(handler *PushHandle, args []string, settings *config.Config) (sugge
 
 type PushHandle struct {
 	kubeCtlInit kubectlapi.KubeCtlInitializer
+	api         cpapi.DataProvider
+	config      config.ConfigProvider
+	lsFiles     git.LsFilesExecutor
+	gitDiff     git.DiffExecutor
+	revParse    git.RevParseExecutor
 	writer      io.Writer
 	qp          util.QuestionPrompter
 	options     pushCmdOptions
 }
 
 type pushCmdOptions struct {
+	podSelection
 	environment, service, remoteProjectPath, file string
 	rsyncVerbose, dryRun, delete, yall            bool
+	useGitIgnore, gitTracked, gitChanged, noHooks bool
+	changeDetection, backup                       string
+	parallel                                      int
+	transfer                                      options.TransferOptions
 }
 
 // Complete verifies command line arguments and loads data from the command environment
@@ -145,6 +182,12 @@ func (h *PushHandle) Complete(argsIn []string, settings *config.Config) {
 	if h.options.service == "" {
 		h.options.service = settings.GetStringQ(config.Service)
 	}
+	if h.options.changeDetection == "" {
+		h.options.changeDetection = options.ChangeDetectionChecksum
+	}
+	if h.options.backup == "" {
+		h.options.backup = options.BackupPod
+	}
 	if strings.HasSuffix(h.options.remoteProjectPath, "/") == false {
 		h.options.remoteProjectPath = h.options.remoteProjectPath + "/"
 	}
@@ -161,7 +204,29 @@ func (h *PushHandle) Validate() error {
 	if strings.HasPrefix(h.options.remoteProjectPath, "/") == false {
 		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.RemoteProjectPathEmpty).String())
 	}
-	return nil
+	if h.options.parallel < 1 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ParallelValueTooSmall).String())
+	}
+	if !slice.ContainString(h.options.changeDetection, options.ChangeDetectionStrategies()) {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.ChangeDetectionInvalid, h.options.changeDetection, strings.Join(options.ChangeDetectionStrategies(), ", "))).String())
+	}
+	if h.options.gitTracked || h.options.gitChanged {
+		if (h.options.gitTracked && h.options.gitChanged) || h.options.file != "" || h.options.delete {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.PushGitModeConflict).String())
+		}
+	}
+	//the concurrent transfers split the directories of a full push, the listed files are sent by a single transfer
+	listed := h.options.gitTracked || h.options.gitChanged || h.options.file != "" || (h.options.changeDetection == options.ChangeDetectionHashCache && !h.options.delete)
+	if h.options.parallel > 1 && listed {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.PushParallelConflict).String())
+	}
+	if !slice.ContainString(h.options.backup, options.BackupModes()) {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.BackupModeInvalid, h.options.backup, strings.Join(options.BackupModes(), ", "))).String())
+	}
+	if err := h.options.validateSelection(); err != nil {
+		return err
+	}
+	return validateTransferOptions(h.options.transfer)
 }
 
 // Copies all the files and folders from the current directory into the remote container
@@ -182,19 +247,29 @@ func (h *PushHandle) Handle(args []string, podsFinder pods.Finder, podsFilter po
 		fmt.Fprintln(h.writer, "Dry run mode enabled")
 	}
 
-	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
+	_, _, apiKey, err := h.kubeCtlInit.GetSettings()
 	if err != nil {
 		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
 	}
 
-	allPods, err := podsFinder.FindAll(user, apiKey, addr, h.options.environment)
+	target := podTarget{h.options.environment, h.options.service, h.options.podSelection}
+	pod, container, _, suggestion, err := target.findPod(h.kubeCtlInit, podsFinder, podsFilter, PushCmdName)
 	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+		return suggestion, err
 	}
 
-	pod := podsFilter.List(*allPods).ByService(h.options.service).ByStatus("Running").ByStatusReason("Running").First()
-	if pod == nil {
-		return fmt.Sprintf(msgs.SuggestionRunningPodNotFound, h.options.service, h.options.environment, config.AppName, PushCmdName, session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, h.options.service)).String())
+	var files []string
+	if h.options.gitTracked || h.options.gitChanged {
+		files, err = h.gitFiles(apiKey)
+		if err != nil {
+			return fmt.Sprintf(msgs.SuggestionPushGitFilesFailed, session.CurrentSession.SessionID), err
+		}
+		if len(files) == 0 {
+			fmt.Fprintln(h.writer, msgs.PushGitNoFiles)
+			return "", nil
+		}
+		cplogs.V(5).Infof("pushing the %d files selected with git", len(files))
+		cplogs.Flush()
 	}
 
 	syncOptions := options.SyncOptions{}
@@ -204,9 +279,17 @@ func (h *PushHandle) Handle(args []string, podsFinder pods.Finder, podsFilter po
 	syncOptions.Environment = h.options.environment
 	syncOptions.KubeConfigKey = h.options.environment
 	syncOptions.Pod = pod.GetName()
+	syncOptions.Container = container
+	syncOptions.Service = h.options.service
 	syncOptions.RemoteProjectPath = h.options.remoteProjectPath
 	syncOptions.DryRun = h.options.dryRun
 	syncOptions.Delete = h.options.delete
+	syncOptions.UseGitIgnore = h.options.useGitIgnore
+	syncOptions.ChangeDetection = h.options.changeDetection
+	syncOptions.Backup = h.options.backup
+	syncOptions.Parallel = h.options.parallel
+	syncOptions.Transfer = h.options.transfer
+	syncOptions.Files = files
 	syncer.SetOptions(syncOptions)
 
 	var paths []string
@@ -220,10 +303,74 @@ func (h *PushHandle) Handle(args []string, podsFinder pods.Finder, podsFilter po
 
 	err = syncer.Sync(paths)
 	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionPushFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error while running rsync").String())
+		return rsyncSuggestion(err, fmt.Sprintf(msgs.SuggestionPushFailed, session.CurrentSession.SessionID)), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error while running rsync").String())
 	}
 	fmt.Fprintf(h.writer, "Push complete, the files and folders that has been sent can be found in the logs %s\n", cplogs.GetLogInfoFile())
-	return "", nil
+
+	if h.options.noHooks || h.options.dryRun {
+		return "", nil
+	}
+	hooks, err := newPostSyncHooks(h.options.environment, h.options.service, h.options.remoteProjectPath, podSelection{pod: pod.GetName(), replicaIndex: -1}, h.writer)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionRunFailed, session.CurrentSession.SessionID), err
+	}
+	return hooks.run()
+}
+
+//gitFiles returns the files tracked by git or, in git changed mode, the files changed since the commit deployed in the
+//remote environment and the files not tracked by git, the files removed locally are left out
+func (h *PushHandle) gitFiles(apiKey string) ([]string, error) {
+	var files []string
+	if h.options.gitTracked {
+		tracked, err := h.lsFiles.GetTrackedFiles()
+		if err != nil {
+			return nil, err
+		}
+		files = tracked
+	} else {
+		ref, err := h.gitChangedReference(apiKey)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h.writer, "Pushing the files changed since %s\n", ref)
+		changed, err := h.gitDiff.GetChangedFiles(ref)
+		if err != nil {
+			return nil, err
+		}
+		untracked, err := h.lsFiles.GetUntrackedFiles()
+		if err != nil {
+			return nil, err
+		}
+		files = slice.RemoveDuplicateString(append(changed, untracked...))
+	}
+
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Lstat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	return existing, nil
+}
+
+//gitChangedReference returns the commit built by the last tide of the remote environment, or the remote branch of the
+//environment when that commit is not in the local repository
+func (h *PushHandle) gitChangedReference(apiKey string) (string, error) {
+	h.api.SetAPIKey(apiKey)
+	remoteEnv, err := h.api.GetRemoteEnvironmentStatus(h.config.GetStringQ(config.FlowId), h.config.GetStringQ(config.RemoteEnvironmentId))
+	if err != nil {
+		cplogs.V(4).Infof("the last tide of the remote environment could not be found: %s", err.Error())
+		cplogs.Flush()
+	} else if sha1 := remoteEnv.LastTide.CodeReference.Sha1; sha1 != "" && h.revParse.CommitExists(sha1) {
+		return sha1, nil
+	}
+
+	remoteBranch := h.config.GetStringQ(config.RemoteBranch)
+	remoteRef := h.config.GetStringQ(config.RemoteName) + "/" + remoteBranch
+	if remoteBranch != "" && h.revParse.CommitExists(remoteRef) {
+		return remoteRef, nil
+	}
+	return "", errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.PushGitReferenceNotFound).String())
 }
 
 func deleteFlagWarning(qp util.QuestionPrompter) string {
@@ -242,3 +389,56 @@ func deleteFlagWarning(qp util.QuestionPrompter) string {
 			}
 		})
 }
+
+//addTransferFlags adds the flags that control how rsync transfers the files, the defaults are read from the settings
+func addTransferFlags(command *cobra.Command, transfer *options.TransferOptions, settings *config.Config) {
+	bandwidthLimit, err := settings.GetString(config.BandwidthLimit)
+	checkErr(err)
+	//GetBool doesn't fall back to the default value of the setting
+	compressSetting, err := settings.GetString(config.Compress)
+	checkErr(err)
+	compress, err := strconv.ParseBool(compressSetting)
+	checkErr(err)
+	maxFileSize, err := settings.GetString(config.MaxFileSize)
+	checkErr(err)
+	symlinks, err := settings.GetString(config.Symlinks)
+	checkErr(err)
+	transport, err := settings.GetString(config.Transport)
+	checkErr(err)
+	if transport == "" {
+		transport = options.DefaultTransport()
+	}
+
+	command.PersistentFlags().StringVar(&transfer.BandwidthLimit, config.BandwidthLimit, bandwidthLimit, "Maximum transfer rate in KiB per second, a suffix sets the unit (e.g.: 500, 1.5m)")
+	command.PersistentFlags().BoolVar(&transfer.Compress, config.Compress, compress, "Compress the files during the transfer, use --compress=false to disable it")
+	command.PersistentFlags().StringVar(&transfer.MaxFileSize, config.MaxFileSize, maxFileSize, "Skip the files larger than this size (e.g.: 500k, 10M, 1G)")
+	command.PersistentFlags().StringVar(&transfer.Symlinks, config.Symlinks, symlinks, fmt.Sprintf("Policy applied to the symbolic links (%s)", strings.Join(options.SymlinkPolicies(), ", ")))
+	command.PersistentFlags().StringVar(&transfer.Transport, config.Transport, transport, fmt.Sprintf("Transport used by rsync to reach the pod (%s)", strings.Join(options.Transports(), ", ")))
+}
+
+//validateTransferOptions checks the values of the transfer flags
+func validateTransferOptions(transfer options.TransferOptions) error {
+	if !options.ValidBandwidthLimit(transfer.BandwidthLimit) {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.BandwidthLimitInvalid, transfer.BandwidthLimit)).String())
+	}
+	if transfer.MaxFileSize != "" {
+		if _, err := options.ParseSize(transfer.MaxFileSize); err != nil {
+			return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.MaxFileSizeInvalid, transfer.MaxFileSize)).String())
+		}
+	}
+	if !slice.ContainString(transfer.Symlinks, options.SymlinkPolicies()) {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.SymlinkPolicyInvalid, transfer.Symlinks, strings.Join(options.SymlinkPolicies(), ", "))).String())
+	}
+	if !slice.ContainString(transfer.Transport, options.Transports()) {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.TransportInvalid, transfer.Transport, strings.Join(options.Transports(), ", "))).String())
+	}
+	return nil
+}
+
+//rsyncSuggestion returns the explanation of the error when rsync is missing or too old, the suggestion otherwise
+func rsyncSuggestion(err error, suggestion string) string {
+	if capabilityErr, ok := errors.Cause(err).(rsync.CapabilityError); ok {
+		return capabilityErr.Suggestion()
+	}
+	return suggestion
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cpapi"
+	syncoptions "github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/continuouspipe/remote-environment-client/test/mocks"
+	"github.com/continuouspipe/remote-environment-client/util/slice"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeRevParse knows the commits of a local repository
+type fakeRevParse struct {
+	commits []string
+}
+
+func (f fakeRevParse) GetLocalBranchName() (string, error) {
+	return "feature", nil
+}
+
+func (f fakeRevParse) CommitExists(ref string) bool {
+	return slice.ContainString(ref, f.commits)
+}
+
+func TestPushHandle_GitChangedReference(t *testing.T) {
+	scenarios := []struct {
+		description   string
+		statusErr     error
+		localCommits  []string
+		expectedRef   string
+		expectedError bool
+	}{
+		{"the commit of the last tide is used when it is known locally", nil, []string{"a1b2c3", "origin/feature"}, "a1b2c3", false},
+		{"the remote branch is used when the commit of the last tide is unknown", nil, []string{"origin/feature"}, "origin/feature", false},
+		{"the remote branch is used when the environment status is not available", errors.New("unavailable"), []string{"a1b2c3", "origin/feature"}, "origin/feature", false},
+		{"an error is returned when no reference is known locally", nil, nil, "", true},
+	}
+
+	for _, scenario := range scenarios {
+		apiProvider := mocks.NewMockCpAPIProvider()
+		status := &cpapi.APIRemoteEnvironmentStatus{}
+		status.LastTide.CodeReference.Sha1 = "a1b2c3"
+		apiProvider.On("SetAPIKey", "some-api-key")
+		apiProvider.On("GetRemoteEnvironmentStatus", "837d92hd-19su1d91", "987654321").Return(status, scenario.statusErr)
+
+		spyConfig := mocks.NewSpyConfig()
+		spyConfig.
+			On("GetStringQ", config.FlowId).Return("837d92hd-19su1d91", nil).
+			On("GetStringQ", config.RemoteEnvironmentId).Return("987654321", nil).
+			On("GetStringQ", config.RemoteName).Return("origin", nil).
+			On("GetStringQ", config.RemoteBranch).Return("feature", nil)
+
+		handler := &PushHandle{}
+		handler.api = apiProvider
+		handler.config = spyConfig
+		handler.revParse = fakeRevParse{commits: scenario.localCommits}
+		handler.writer = ioutil.Discard
+
+		ref, err := handler.gitChangedReference("some-api-key")
+		assert.Equal(t, scenario.expectedRef, ref, scenario.description)
+		assert.Equal(t, scenario.expectedError, err != nil, scenario.description)
+	}
+}
+
+func TestPushHandle_ValidateParallel(t *testing.T) {
+	scenarios := []struct {
+		description   string
+		options       func(options *pushCmdOptions)
+		expectedError bool
+	}{
+		{"a full push is split between the transfers", func(options *pushCmdOptions) {}, false},
+		{"the git tracked files are sent by a single transfer", func(options *pushCmdOptions) { options.gitTracked = true }, true},
+		{"a single file is sent by a single transfer", func(options *pushCmdOptions) { options.file = "src" }, true},
+		{"the files changed since the last push are sent by a single transfer", func(options *pushCmdOptions) { options.changeDetection = syncoptions.ChangeDetectionHashCache }, true},
+		{"a push deleting the remote files doesn't use the hash cache", func(options *pushCmdOptions) {
+			options.changeDetection = syncoptions.ChangeDetectionHashCache
+			options.delete = true
+		}, false},
+	}
+
+	for _, scenario := range scenarios {
+		handler := &PushHandle{}
+		handler.options = pushCmdOptions{environment: "dev", service: "web", remoteProjectPath: "/app/", parallel: 4, changeDetection: syncoptions.ChangeDetectionChecksum, backup: syncoptions.BackupPod}
+		handler.options.replicaIndex = -1
+		handler.options.transfer = syncoptions.TransferOptions{Symlinks: syncoptions.SymlinksPreserve, Transport: syncoptions.TransportRsh}
+		scenario.options(&handler.options)
+		err := handler.Validate()
+		assert.Equal(t, scenario.expectedError, err != nil, scenario.description)
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/util/asciicast"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//ReplayCmdName is the command name identifier
+const ReplayCmdName = "replay"
+
+func NewReplayCmd() *cobra.Command {
+	handler := &ReplayHandle{}
+	handler.writer = os.Stdout
+	handler.player = asciicast.NewPlayer()
+
+	command := &cobra.Command{
+		Use:     ReplayCmdName + " [recording]",
+		Short:   msgs.ReplayCommandShortDescription,
+		Long:    msgs.ReplayCommandLongDescription,
+		Example: fmt.Sprintf(msgs.ReplayCommandExampleDescription, config.AppName),
+		Run: func(cmd *cobra.Command, args []string) {
+			remoteCommand := remotecplogs.NewRemoteCommand(ReplayCmdName, os.Args)
+			cs := session.NewCommandSession().Start()
+
+			handler.Complete(args)
+			err := handler.Validate()
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(err.Error())
+			}
+
+			suggestion, err := handler.Handle()
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(suggestion)
+			}
+
+			err = remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cs))
+			if err != nil {
+				cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				cplogs.Flush()
+			}
+		},
+	}
+	command.PersistentFlags().Float64Var(&handler.player.Speed, "speed", 1, "Play the recording faster, or slower when lower than 1 (e.g.: --speed 2)")
+	command.PersistentFlags().DurationVar(&handler.player.IdleTimeLimit, "idle-time-limit", 0, "Shorten the pauses of the recording longer than this duration (e.g.: --idle-time-limit 2s)")
+	return command
+}
+
+type ReplayHandle struct {
+	writer    io.Writer
+	player    *asciicast.Player
+	recording string
+}
+
+// Complete verifies command line arguments and loads data from the command environment
+func (h *ReplayHandle) Complete(argsIn []string) {
+	if len(argsIn) > 0 {
+		h.recording = argsIn[0]
+	}
+}
+
+// Validate checks that the recording is given and that the playback options are valid.
+func (h *ReplayHandle) Validate() error {
+	if h.recording == "" {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ReplayRecordingMissing).String())
+	}
+	if h.player.Speed <= 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ReplaySpeedInvalid).String())
+	}
+	return nil
+}
+
+// Plays the recorded session back in the terminal
+func (h *ReplayHandle) Handle() (suggestion string, err error) {
+	file, err := os.Open(h.recording)
+	if err != nil {
+		err = errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.ReplayRecordingNotFound, h.recording)).String())
+		return fmt.Sprintf(msgs.ReplayRecordingNotFound, h.recording), err
+	}
+	defer file.Close()
+
+	_, err = h.player.Play(file, h.writer)
+	if err != nil {
+		return fmt.Sprintf(msgs.ReplayRecordingInvalid, h.recording, err.Error()), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("error when playing the recording %s", h.recording)).String())
+	}
+	fmt.Fprint(h.writer, "\r\n"+msgs.ReplayCompleted+"\r\n")
+	return "", nil
+}
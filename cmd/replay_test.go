@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/util/asciicast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayHandle(t *testing.T) {
+	file, err := ioutil.TempFile("", "session.cast")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	recorder, err := asciicast.NewRecorder(file, asciicast.Header{Width: 80, Height: 24})
+	assert.Nil(t, err)
+	recorder.Write([]byte("root@web-1:/app# exit\r\n"))
+	file.Close()
+
+	out := &bytes.Buffer{}
+	handler := &ReplayHandle{writer: out, player: asciicast.NewPlayer()}
+	handler.Complete([]string{file.Name()})
+	assert.Nil(t, handler.Validate())
+	_, err = handler.Handle()
+	assert.Nil(t, err)
+	assert.Equal(t, "root@web-1:/app# exit\r\n\r\nEnd of the recording.\r\n", out.String())
+
+	handler.Complete([]string{file.Name() + ".missing"})
+	_, err = handler.Handle()
+	assert.NotNil(t, err, "the recording doesn't exist")
+
+	handler.player.Speed = 0
+	assert.NotNil(t, handler.Validate(), "the speed is not valid")
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/sync/rsync"
+	"github.com/continuouspipe/remote-environment-client/util/slice"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//RestoreCmdName is the command name identifier
+const RestoreCmdName = "restore"
+
+//extracts the tarball $2, or the standard input when $2 is -, in the directory $1
+const remoteExtractBackup = `mkdir -p "$1" && tar -xzf "$2" -C "$1"`
+
+func NewRestoreCmd() *cobra.Command {
+	handler := &RestoreHandle{}
+	handler.kubeCtlInit = kubectlapi.NewKubeCtlInit()
+	handler.writer = os.Stdout
+
+	command := &cobra.Command{
+		Use:     RestoreCmdName + " [backup]",
+		Short:   msgs.RestoreCommandShortDescription,
+		Long:    msgs.RestoreCommandLongDescription,
+		Example: fmt.Sprintf(msgs.RestoreCommandExampleDescription, config.AppName),
+		Run: func(cmd *cobra.Command, args []string) {
+			remoteCommand := remotecplogs.NewRemoteCommand(RestoreCmdName, os.Args)
+			cs := session.NewCommandSession().Start()
+
+			suggestion, err := handler.Complete(args)
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(suggestion)
+			}
+
+			err = handler.Validate()
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(err.Error())
+			}
+
+			suggestion, err = handler.Handle(pods.NewKubePodsFind(), pods.NewKubePodsFilter())
+			if err != nil {
+				remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+				cperrors.ExitWithMessage(suggestion)
+			}
+
+			err = remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cs))
+			if err != nil {
+				cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				cplogs.Flush()
+			}
+		},
+	}
+	command.PersistentFlags().BoolVarP(&handler.interactive, "interactive", "i", false, "Interactive mode allows to target a different environment")
+	command.PersistentFlags().StringVarP(&handler.environment, config.KubeEnvironmentName, "e", "", "The full remote environment name")
+	command.PersistentFlags().StringVarP(&handler.service, config.Service, "s", "", "The service to use (e.g.: web, mysql)")
+	command.PersistentFlags().StringVarP(&handler.flowID, config.FlowId, "f", "", "The flow to use")
+	command.PersistentFlags().StringVarP(&handler.remoteProjectPath, "remote-project-path", "a", "/app/", "Specify the absolute path to your project folder, by default set to /app/")
+	handler.addPodSelectionFlags(command.PersistentFlags())
+	handler.addContainerFlag(command.PersistentFlags())
+	return command
+}
+
+type RestoreHandle struct {
+	podTarget
+	kubeCtlInit       kubectlapi.KubeCtlInitializer
+	writer            io.Writer
+	interactive       bool
+	flowID            string
+	remoteProjectPath string
+	backup            string
+	//exec runs the command in the pod, sending in to its standard input when not nil
+	exec func(in io.Reader, out io.Writer, command ...string) error
+}
+
+// Complete verifies command line arguments and loads data from the command environment
+func (h *RestoreHandle) Complete(argsIn []string) (suggestion string, err error) {
+	if len(argsIn) > 0 {
+		h.backup = strings.TrimSuffix(argsIn[0], rsync.BackupExtension)
+	}
+	return h.completeTarget(h.interactive, h.flowID, argsIn)
+}
+
+// Validate checks that the environment, the service and the remote project path are specified.
+func (h *RestoreHandle) Validate() error {
+	err := h.validateTarget()
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(h.remoteProjectPath, "/") == false {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.RemoteProjectPathEmpty).String())
+	}
+	return nil
+}
+
+// Restores the backup in the remote project directory, lists the available backups when no backup is given
+func (h *RestoreHandle) Handle(podsFinder pods.Finder, podsFilter pods.Filter) (suggestion string, err error) {
+	pod, container, clientConfig, suggestion, err := h.findPod(h.kubeCtlInit, podsFinder, podsFilter, RestoreCmdName)
+	if err != nil {
+		return suggestion, err
+	}
+	h.exec = func(in io.Reader, out io.Writer, command ...string) error {
+		return streamInPod(clientConfig, pod.GetName(), container, in, out, os.Stderr, command...)
+	}
+
+	localDir, err := rsync.LocalBackupDir(h.environment, h.service)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionRestoreFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when getting the local backup directory").String())
+	}
+	localBackups, err := localBackupList(localDir)
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionRestoreFailed, session.CurrentSession.SessionID), err
+	}
+	podBackups, err := h.podBackupList()
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionRestoreFailed, session.CurrentSession.SessionID), err
+	}
+
+	if h.backup == "" {
+		if len(localBackups) == 0 && len(podBackups) == 0 {
+			fmt.Fprintf(h.writer, msgs.RestoreNoBackups+"\n", pod.GetName())
+			return "", nil
+		}
+		printBackupList(h.writer, fmt.Sprintf("Backups stored in %s", localDir), localBackups)
+		printBackupList(h.writer, fmt.Sprintf("Backups stored in the pod %s", pod.GetName()), podBackups)
+		return "", nil
+	}
+
+	name := h.backup + rsync.BackupExtension
+	switch {
+	case slice.ContainString(h.backup, localBackups):
+		f, err := os.Open(filepath.Join(localDir, name))
+		if err != nil {
+			return fmt.Sprintf(msgs.SuggestionRestoreFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when opening the backup %s", name)).String())
+		}
+		defer f.Close()
+		err = h.exec(f, h.writer, "sh", "-c", remoteExtractBackup, "sh", h.remoteProjectPath, "-")
+	case slice.ContainString(h.backup, podBackups):
+		err = h.exec(nil, h.writer, "sh", "-c", remoteExtractBackup, "sh", h.remoteProjectPath, rsync.RemoteBackupDir+"/"+name)
+	default:
+		err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusNotFound, fmt.Sprintf(msgs.RestoreBackupNotFound, h.backup, pod.GetName(), config.AppName)).String())
+		return err.Error(), err
+	}
+	if err != nil {
+		return fmt.Sprintf(msgs.SuggestionRestoreFailed, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when restoring the backup %s", h.backup)).String())
+	}
+	fmt.Fprintf(h.writer, msgs.RestoreCompleted+"\n", h.backup, h.remoteProjectPath)
+	return "", nil
+}
+
+//podBackupList returns the identifiers of the backups stored in the pod
+func (h *RestoreHandle) podBackupList() ([]string, error) {
+	out := &bytes.Buffer{}
+	err := h.exec(nil, out, "sh", "-c", `ls -1 "$1" 2>/dev/null; exit 0`, "sh", rsync.RemoteBackupDir)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the backups of the pod").String())
+	}
+	return backupIDs(strings.Split(out.String(), "\n")), nil
+}
+
+//localBackupList returns the identifiers of the backups stored in the directory
+func localBackupList(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when listing the backups of %s", dir)).String())
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	return backupIDs(names), nil
+}
+
+//backupIDs returns the identifiers of the backup tarballs in the file names
+func backupIDs(names []string) []string {
+	var ids []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if strings.HasSuffix(name, rsync.BackupExtension) {
+			ids = append(ids, strings.TrimSuffix(name, rsync.BackupExtension))
+		}
+	}
+	return ids
+}
+
+func printBackupList(writer io.Writer, title string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	fmt.Fprintf(writer, "%s:\n", title)
+	for _, id := range ids {
+		fmt.Fprintf(writer, "  %s\n", id)
+	}
+}
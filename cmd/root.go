@@ -88,10 +88,16 @@ func init() {
 	RootCmd.AddCommand(NewDeleteCmd())
 	RootCmd.AddCommand(NewBashCmd())
 	RootCmd.AddCommand(NewExecCmd())
+	RootCmd.AddCommand(NewRunCmd())
+	RootCmd.AddCommand(NewReplayCmd())
 	RootCmd.AddCommand(NewWatchCmd())
 	RootCmd.AddCommand(NewFetchCmd())
 	RootCmd.AddCommand(NewPushCmd())
 	RootCmd.AddCommand(NewSyncCmd())
+	RootCmd.AddCommand(NewDiffCmd())
+	RootCmd.AddCommand(NewFilesCmd())
+	RootCmd.AddCommand(NewRestoreCmd())
+	RootCmd.AddCommand(NewIgnoreCmd())
 	RootCmd.AddCommand(NewForwardCmd())
 	RootCmd.AddCommand(NewVersionCmd())
 	RootCmd.AddCommand(NewCheckUpdatesCmd())
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	remotecplogs "github.com/continuouspipe/remote-environment-client/cplogs/remote"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+//RunCmdName is the command name identifier
+const RunCmdName = "run"
+
+//NewRunCmd returns the exec command running the commands of the project configuration
+func NewRunCmd() *cobra.Command {
+	handler := newExecHandle()
+	runcmd := newExecCmd(handler, RunCmdName)
+	runcmd.Use = RunCmdName + " [command]"
+	runcmd.Aliases = nil
+	runcmd.Short = msgs.RunCommandShortDescription
+	runcmd.Long = msgs.RunCommandLongDescription
+	runcmd.Example = fmt.Sprintf(msgs.RunCommandExampleDescription, config.AppName)
+
+	var list bool
+	runcmd.PersistentFlags().BoolVar(&list, "list", false, "List the commands of the project")
+
+	//the session is reported by the exec command once the command of the project is found
+	execRun := runcmd.Run
+	runcmd.Run = func(cmd *cobra.Command, args []string) {
+		project, err := loadProject()
+		if err != nil {
+			remoteCommand := remotecplogs.NewRemoteCommand(RunCmdName, os.Args)
+			cs := session.NewCommandSession().Start()
+			remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+			cperrors.ExitWithMessage(fmt.Sprintf(msgs.SuggestionRunFailed, session.CurrentSession.SessionID))
+		}
+		if list || len(args) == 0 {
+			remoteCommand := remotecplogs.NewRemoteCommand(RunCmdName, os.Args)
+			cs := session.NewCommandSession().Start()
+			printRemoteCommands(os.Stdout, *project)
+			err = remotecplogs.NewRemoteCommandSender().Send(*remoteCommand.EndedOk(*cs))
+			if err != nil {
+				cplogs.V(4).Infof(remotecplogs.ErrorFailedToSendDataToLoggingAPI)
+				cplogs.Flush()
+			}
+			return
+		}
+		command, err := projectCommand(*project, args[0])
+		if err != nil {
+			remoteCommand := remotecplogs.NewRemoteCommand(RunCmdName, os.Args)
+			cs := session.NewCommandSession().Start()
+			remotecplogs.EndSessionAndSendErrorCause(remoteCommand, cs, err)
+			cperrors.ExitWithMessage(err.Error())
+		}
+		execRun(cmd, handler.useRemoteCommand(args[0], command, args[1:]))
+	}
+	return runcmd
+}
+
+//loadProject reads the project configuration of the current directory
+func loadProject() (*config.Project, error) {
+	project, err := config.LoadProject(config.ProjectConfigFile)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.ProjectConfigInvalid, config.ProjectConfigFile)).String())
+	}
+	return project, nil
+}
+
+//projectCommand returns the command of the project with the given name
+func projectCommand(project config.Project, name string) (config.RemoteCommand, error) {
+	command, ok := project.Commands[name]
+	if !ok {
+		return command, errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.RunCommandNotFound, name, config.ProjectConfigFile, strings.Join(project.CommandNames(), ", "))).String())
+	}
+	return command, nil
+}
+
+//printRemoteCommands prints the names and the descriptions of the commands of the project
+func printRemoteCommands(writer io.Writer, project config.Project) {
+	names := project.CommandNames()
+	if len(names) == 0 {
+		fmt.Fprintf(writer, msgs.RunNoCommands+"\n", config.ProjectConfigFile)
+		return
+	}
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	for _, name := range names {
+		command := project.Commands[name]
+		description := command.Description
+		if description == "" {
+			description = command.Command
+		}
+		fmt.Fprintf(writer, "  %-*s  %s\n", width, name, description)
+	}
+}
+
+//useRemoteCommand sets where the command of the project runs, unless the flags already did, and returns the arguments
+//executing its command line followed by args
+func (h *execHandle) useRemoteCommand(name string, command config.RemoteCommand, args []string) []string {
+	if h.service == "" {
+		h.service = command.Service
+	}
+	if h.container == "" {
+		h.container = command.Container
+	}
+	if h.workdir == "" {
+		h.workdir = command.Workdir
+	}
+	//the variables of the flags are last so that they override the ones of the command
+	h.env = append(command.EnvList(), h.env...)
+	return append([]string{"sh", "-c", command.Command + ` "$@"`, name}, args...)
+}
+
+//postSyncHooks runs the post-sync commands of the project once the files are pushed to the remote environment
+type postSyncHooks struct {
+	project           config.Project
+	environment       string
+	service           string
+	remoteProjectPath string
+	//selection is the pod the files were pushed to, the commands of the same service run in it
+	selection  podSelection
+	podsFinder pods.Finder
+	podsFilter pods.Filter
+	writer     io.Writer
+}
+
+func newPostSyncHooks(environment, service, remoteProjectPath string, selection podSelection, writer io.Writer) (*postSyncHooks, error) {
+	project, err := loadProject()
+	if err != nil {
+		return nil, err
+	}
+	return &postSyncHooks{
+		project:           *project,
+		environment:       environment,
+		service:           service,
+		remoteProjectPath: remoteProjectPath,
+		selection:         selection,
+		podsFinder:        pods.NewKubePodsFind(),
+		podsFilter:        pods.NewKubePodsFilter(),
+		writer:            writer,
+	}, nil
+}
+
+//run executes the post-sync commands one after the other and stops at the first one failing
+func (h postSyncHooks) run() (suggestion string, err error) {
+	for _, name := range h.project.Hooks.PostSync {
+		command, err := projectCommand(h.project, name)
+		if err != nil {
+			return err.Error(), err
+		}
+		fmt.Fprintf(h.writer, msgs.PostSyncHookRunning+"\n", name)
+
+		handler := newExecHandle()
+		handler.environment = h.environment
+		handler.remoteProjectPath = h.remoteProjectPath
+		handler.replicaIndex = -1
+		handler.withContainer = true
+		handler.noTTY = true
+		handler.noStdin = true
+		//the watch can't wait for an answer, the first pod of another service is used
+		handler.podSelection.isTerminal = func() bool { return false }
+		if command.Service == "" || command.Service == h.service {
+			handler.service = h.service
+			handler.pod = h.selection.pod
+			handler.replicaIndex = h.selection.replicaIndex
+		}
+		args := handler.useRemoteCommand(name, command, nil)
+		handler.complete(args, config.C)
+		err = handler.validate()
+		if err == nil {
+			_, err = handler.handle(h.podsFinder, h.podsFilter)
+		}
+		if err != nil {
+			return fmt.Sprintf(msgs.PostSyncHookFailed, name), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf(msgs.PostSyncHookFailed, name)).String())
+		}
+	}
+	return "", nil
+}
+
+//hookedObserver runs the post-sync commands after each sync of the watch command, a failing command doesn't stop the watch
+type hookedObserver struct {
+	observer monitor.EventsObserver
+	hooks    postSyncHooks
+}
+
+func (o hookedObserver) OnLastChange(events []monitor.PathEvent) error {
+	err := o.observer.OnLastChange(events)
+	if err != nil {
+		return err
+	}
+	suggestion, err := o.hooks.run()
+	if err != nil {
+		cplogs.V(4).Infof("post-sync hook failed: %s", err.Error())
+		cplogs.Flush()
+		color.Red(suggestion)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-remote-project")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, config.ProjectConfigFile)
+
+	project, err := config.LoadProject(file)
+	assert.Nil(t, err)
+	assert.Empty(t, project.Commands, "the project configuration is optional")
+
+	err = ioutil.WriteFile(file, []byte(`commands:
+  migrate:
+    description: Run the database migrations
+    command: bin/console doctrine:migrations:migrate -n
+  test:
+    command: vendor/bin/phpunit
+    container: php
+    workdir: /app/api
+    env:
+      APP_ENV: test
+      APP_DEBUG: "0"
+hooks:
+  post-sync:
+    - migrate
+`), 0644)
+	assert.Nil(t, err)
+
+	project, err = config.LoadProject(file)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"migrate", "test"}, project.CommandNames())
+	assert.Equal(t, []string{"migrate"}, project.Hooks.PostSync)
+	assert.Equal(t, "php", project.Commands["test"].Container)
+	assert.Equal(t, []string{"APP_DEBUG=0", "APP_ENV=test"}, project.Commands["test"].EnvList())
+
+	out := &bytes.Buffer{}
+	printRemoteCommands(out, *project)
+	assert.Equal(t, "  migrate  Run the database migrations\n  test     vendor/bin/phpunit\n", out.String())
+
+	_, err = projectCommand(*project, "deploy")
+	assert.NotNil(t, err, "the command is not defined")
+}
+
+func TestExecHandle_UseRemoteCommand(t *testing.T) {
+	command := config.RemoteCommand{
+		Command:   "vendor/bin/phpunit",
+		Service:   "api",
+		Container: "php",
+		Workdir:   "/app/api",
+		Env:       map[string]string{"APP_ENV": "test"},
+	}
+
+	h := execHandle{}
+	args := h.useRemoteCommand("test", command, []string{"--filter", "UserTest"})
+	assert.Equal(t, []string{"sh", "-c", `vendor/bin/phpunit "$@"`, "test", "--filter", "UserTest"}, args)
+	assert.Equal(t, "api", h.service)
+	assert.Equal(t, "php", h.container)
+	assert.Equal(t, "/app/api", h.workdir)
+	assert.Equal(t, []string{"APP_ENV=test"}, h.env)
+
+	h = execHandle{env: []string{"APP_ENV=dev"}}
+	h.service, h.container = "web", "nginx"
+	h.useRemoteCommand("test", command, nil)
+	assert.Equal(t, "web", h.service, "the flags take precedence over the command")
+	assert.Equal(t, "nginx", h.container, "the flags take precedence over the command")
+	assert.Equal(t, []string{"APP_ENV=test", "APP_ENV=dev"}, h.env, "the variables of the flags are set last")
+}
@@ -0,0 +1,635 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cpapi"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/session"
+	"github.com/continuouspipe/remote-environment-client/util"
+	"github.com/continuouspipe/remote-environment-client/util/slice"
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh/terminal"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/unversioned/clientcmd"
+	kubectlcmd "k8s.io/kubernetes/pkg/kubectl/cmd"
+	kubectlcmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+//defaultPodWait is how long the --wait flag waits for a pod when no duration is given
+const defaultPodWait = 5 * time.Minute
+
+//podTarget is the environment and the service of the pod targeted by the commands executed remotely
+type podTarget struct {
+	environment string
+	service     string
+	podSelection
+}
+
+//completeTarget applies the environment and the service of the configuration or, in interactive mode, asks the user
+//to choose them and connects to the cluster of the chosen flow
+func (t *podTarget) completeTarget(interactive bool, flowID string, args []string) (suggestion string, err error) {
+	settings := config.C
+
+	if !interactive {
+		if t.environment == "" {
+			t.environment = settings.GetStringQ(config.KubeEnvironmentName)
+		}
+		if t.service == "" {
+			t.service = settings.GetStringQ(config.Service)
+		}
+		return "", nil
+	}
+
+	cplogs.V(5).Infoln("targeting the pod in interactive mode")
+	//make sure config has an api key and a cp user set
+	initInteractiveH := NewInitInteractiveHandler(false)
+	initInteractiveH.SetWriter(ioutil.Discard)
+	suggestion, err = initInteractiveH.Complete(args)
+	if err != nil {
+		return suggestion, err
+	}
+	err = initInteractiveH.Validate()
+	if err != nil {
+		return err.Error(), err
+	}
+	suggestion, err = initInteractiveH.Handle()
+	if err != nil {
+		return suggestion, err
+	}
+
+	if flowID == "" && t.environment == "" && t.service == "" {
+		//guide the user to choose the right pod they want to target
+		questioner := cpapi.NewMultipleChoiceCpEntityQuestioner()
+		questioner.SetAPIKey(settings.GetStringQ(config.ApiKey))
+		_, flow, environment, pod, suggestion, err := questioner.WhichEntities()
+		if err != nil {
+			return suggestion, err
+		}
+
+		t.environment = environment.Identifier
+		t.service = pod.Name
+		flowID = flow.UUID
+
+		suggestedFlags := color.GreenString("-i -e %s -f %s -s %s", environment.Identifier, flow.UUID, pod.Name)
+		fmt.Printf(fmt.Sprintf("\n\n%s\n", msgs.InteractiveModeSuggestingFlags), suggestedFlags)
+	}
+
+	//alter the configuration so that we connect to the flow and environment specified by the user
+	return newInteractiveModeH().findTargetClusterAndApplyToConfig(flowID, t.environment)
+}
+
+// validateTarget checks that the environment and the service are specified and that the pod selection is valid.
+func (t podTarget) validateTarget() error {
+	if len(strings.Trim(t.environment, " ")) == 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.EnvironmentSpecifiedEmpty).String())
+	}
+	if len(strings.Trim(t.service, " ")) == 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.ServiceSpecifiedEmpty).String())
+	}
+	return t.validateSelection()
+}
+
+//findPod returns the running pod and the container chosen with the pod selection flags, by default the preferred running
+//pod of the service and its default container, and the client configuration used to reach it
+func (t podTarget) findPod(kubeCtlInit kubectlapi.KubeCtlInitializer, podsFinder pods.Finder, podsFilter pods.Filter, cmdName string) (pod *api.Pod, container string, clientConfig clientcmd.ClientConfig, suggestion string, err error) {
+	addr, user, apiKey, err := kubeCtlInit.GetSettings()
+	if err != nil {
+		return nil, "", nil, fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
+	}
+
+	podList, err := podsFinder.FindAll(user, apiKey, addr, t.environment)
+	if err != nil {
+		return nil, "", nil, fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+	}
+	serviceList, err := podsFinder.FindServices(user, apiKey, addr, t.environment)
+	if err != nil {
+		return nil, "", nil, fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+	}
+
+	if t.wait > 0 && len(t.matchingPods(podsFilter, *podList, *serviceList)) == 0 {
+		podList, serviceList, err = t.waitForPods(podsFinder, podsFilter, user, apiKey, addr, *podList, *serviceList)
+		if err != nil {
+			return nil, "", nil, err.Error(), err
+		}
+	}
+
+	running := podsFilter.List(*podList).ByStatus("Running").ByStatusReason("Running")
+	if t.pod != "" {
+		pod, err = t.selectPodByName(running.Items(), t.environment)
+		if err != nil {
+			return nil, "", nil, err.Error(), err
+		}
+	} else {
+		candidates := running.Services(*serviceList).ByService(t.service).Items()
+		if len(candidates) == 0 {
+			return nil, "", nil, fmt.Sprintf(msgs.SuggestionRunningPodNotFound, t.service, t.environment, config.AppName, cmdName, session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, t.service)).String())
+		}
+		pod, err = t.selectServicePod(candidates, t.service)
+		if err != nil {
+			return nil, "", nil, err.Error(), err
+		}
+	}
+
+	container, err = t.selectContainer(pod)
+	if err != nil {
+		return nil, "", nil, err.Error(), err
+	}
+	cplogs.V(5).Infof("targeting the pod %s, container '%s'", pod.GetName(), container)
+	cplogs.Flush()
+	return pod, container, kubectlapi.GetNonInteractiveDeferredLoadingClientConfig(user, apiKey, addr, t.environment), "", nil
+}
+
+//findPods returns the running pods of the service, or the running pods matching the label selector, with the client
+//configuration used to reach them
+func (t podTarget) findPods(kubeCtlInit kubectlapi.KubeCtlInitializer, podsFinder pods.Finder, podsFilter pods.Filter, cmdName string) (podList []api.Pod, clientConfig clientcmd.ClientConfig, suggestion string, err error) {
+	addr, user, apiKey, err := kubeCtlInit.GetSettings()
+	if err != nil {
+		return nil, nil, fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
+	}
+
+	allPods, err := podsFinder.FindAll(user, apiKey, addr, t.environment)
+	if err != nil {
+		return nil, nil, fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+	}
+	serviceList, err := podsFinder.FindServices(user, apiKey, addr, t.environment)
+	if err != nil {
+		return nil, nil, fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
+	}
+
+	if t.wait > 0 && len(t.matchingPods(podsFilter, *allPods, *serviceList)) == 0 {
+		allPods, serviceList, err = t.waitForPods(podsFinder, podsFilter, user, apiKey, addr, *allPods, *serviceList)
+		if err != nil {
+			return nil, nil, err.Error(), err
+		}
+	}
+
+	//the pods are all used, whether they are ready or not
+	t.wait = 0
+	podList = t.matchingPods(podsFilter, *allPods, *serviceList)
+	if len(podList) == 0 {
+		if t.selector != "" {
+			err = errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSelector, t.selector)).String())
+			return nil, nil, err.Error(), err
+		}
+		return nil, nil, fmt.Sprintf(msgs.SuggestionRunningPodNotFound, t.service, t.environment, config.AppName, cmdName, session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, t.service)).String())
+	}
+	sort.Sort(podsByName(podList))
+	return podList, kubectlapi.GetNonInteractiveDeferredLoadingClientConfig(user, apiKey, addr, t.environment), "", nil
+}
+
+//matchingPods returns the running pods that the selection can choose from: the pods whose name starts with the pod of the
+//selection, the pods matching the label selector or the pods of the service. Only the ready pods are returned when the
+//selection waits for a pod
+func (t podTarget) matchingPods(podsFilter pods.Filter, podList api.PodList, serviceList api.ServiceList) []api.Pod {
+	//the filter reuses the array of the list it filters
+	podList.Items = append([]api.Pod{}, podList.Items...)
+	running := podsFilter.List(podList).ByStatus("Running").ByStatusReason("Running")
+
+	var matches []api.Pod
+	if t.pod != "" {
+		for _, pod := range running.Items() {
+			if strings.HasPrefix(pod.GetName(), t.pod) {
+				matches = append(matches, pod)
+			}
+		}
+	} else if t.selector != "" {
+		matches = running.BySelector(t.labelSelector()).Items()
+	} else {
+		matches = running.Services(serviceList).ByService(t.service).Items()
+	}
+	if t.wait == 0 {
+		return matches
+	}
+	var ready []api.Pod
+	for _, pod := range matches {
+		if pods.IsReady(pod) {
+			ready = append(ready, pod)
+		}
+	}
+	return ready
+}
+
+//concerns returns true when the pod is one of the pods of the selection, whatever its status
+func (t podTarget) concerns(podsFilter pods.Filter, pod api.Pod, serviceList api.ServiceList) bool {
+	if t.pod != "" {
+		return strings.HasPrefix(pod.GetName(), t.pod)
+	}
+	if t.selector != "" {
+		return t.labelSelector().Matches(labels.Set(pod.GetLabels()))
+	}
+	return podsFilter.List(api.PodList{Items: []api.Pod{pod}}).Services(serviceList).ByService(t.service).First() != nil
+}
+
+//waitForPods watches the pods of the environment until the selection matches a ready pod and returns the pods and the
+//services of the environment at that time, the status of the pods of the selection is printed whenever it changes
+func (t podTarget) waitForPods(podsFinder pods.Finder, podsFilter pods.Filter, user, apiKey, addr string, podList api.PodList, serviceList api.ServiceList) (*api.PodList, *api.ServiceList, error) {
+	writer := t.writer
+	if writer == nil {
+		writer = os.Stdout
+	}
+	switch {
+	case t.selector != "":
+		fmt.Fprintf(writer, msgs.WaitingForSelectorPod+"\n", t.wait, t.selector)
+	case t.pod != "":
+		fmt.Fprintf(writer, msgs.WaitingForNamedPod+"\n", t.wait, t.pod)
+	default:
+		fmt.Fprintf(writer, msgs.WaitingForPod+"\n", t.wait, t.service)
+	}
+
+	current := map[string]api.Pod{}
+	statuses := map[string]string{}
+	printStatus := func(pod api.Pod) {
+		status := pods.StatusReason(pod)
+		if status == "Running" && !pods.IsReady(pod) {
+			status = "Running, not ready"
+		}
+		if statuses[pod.GetName()] != status && t.concerns(podsFilter, pod, serviceList) {
+			fmt.Fprintf(writer, msgs.WaitingPodStatus+"\n", pod.GetName(), status)
+		}
+		statuses[pod.GetName()] = status
+	}
+	for _, pod := range podList.Items {
+		current[pod.GetName()] = pod
+		printStatus(pod)
+	}
+
+	var ready *api.PodList
+	matched := func() bool {
+		list := api.PodList{}
+		for _, pod := range current {
+			list.Items = append(list.Items, pod)
+		}
+		if len(t.matchingPods(podsFilter, list, serviceList)) > 0 {
+			ready = &list
+			return true
+		}
+		return false
+	}
+
+	timedOut := make(chan struct{})
+	timer := time.AfterFunc(t.wait, func() { close(timedOut) })
+	defer timer.Stop()
+	watcher := podWatch{podsFinder, user, apiKey, addr, t.environment}
+	err := watcher.watch(podList.ResourceVersion, timedOut, func(event watch.Event, pod api.Pod) bool {
+		switch event.Type {
+		case watch.Deleted:
+			delete(current, pod.GetName())
+			delete(statuses, pod.GetName())
+			return true
+		case watch.Added:
+			//the service may have been created with the pod
+			if services, err := podsFinder.FindServices(user, apiKey, addr, t.environment); err == nil {
+				serviceList = *services
+			}
+		}
+		current[pod.GetName()] = pod
+		printStatus(pod)
+		return !matched()
+	}, func(podList api.PodList) bool {
+		//the pods deleted while the watch was expired are not in the new list
+		current = map[string]api.Pod{}
+		for _, pod := range podList.Items {
+			current[pod.GetName()] = pod
+			printStatus(pod)
+		}
+		return !matched()
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if ready == nil {
+		return nil, nil, errors.New(cperrors.NewStatefulErrorMessage(http.StatusNotFound, t.waitTimeoutMessage()).String())
+	}
+	return ready, &serviceList, nil
+}
+
+//minPodWatchBackoff and maxPodWatchBackoff bound the delay before a watch of the pods is started again, the delay
+//doubles at each restart until an event is received
+var (
+	minPodWatchBackoff = time.Second
+	maxPodWatchBackoff = 30 * time.Second
+)
+
+//podWatch is the environment whose pods are watched
+type podWatch struct {
+	podsFinder  pods.Finder
+	user        string
+	apiKey      string
+	addr        string
+	environment string
+}
+
+//watch calls handle with the events of the pods from the resource version until stop is closed or handle returns
+//false. The api server ends the watches after a while, the watch is then started again, and it sends an error when
+//the resource version is too old (410 Gone): the pods are then listed again, given to relisted, and the watch starts
+//from the resource version of the new list
+func (w podWatch) watch(resourceVersion string, stop <-chan struct{}, handle func(event watch.Event, pod api.Pod) bool, relisted func(podList api.PodList) bool) error {
+	backoff := minPodWatchBackoff
+	for {
+		watcher, err := w.podsFinder.WatchAll(w.user, w.apiKey, w.addr, w.environment, resourceVersion)
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when watching the pods of the environment").String())
+		}
+		expired := false
+		ended := false
+		for !ended {
+			select {
+			case <-stop:
+				watcher.Stop()
+				return nil
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					ended = true
+					continue
+				}
+				if event.Type == watch.Error {
+					cplogs.V(4).Infof("the watch of the pods of the environment %s failed: %v", w.environment, event.Object)
+					expired = true
+					ended = true
+					continue
+				}
+				pod, ok := event.Object.(*api.Pod)
+				if !ok {
+					continue
+				}
+				resourceVersion = pod.ResourceVersion
+				backoff = minPodWatchBackoff
+				if !handle(event, *pod) {
+					watcher.Stop()
+					return nil
+				}
+			}
+		}
+		watcher.Stop()
+
+		cplogs.V(5).Infof("starting the watch of the pods of the environment %s again in %s", w.environment, backoff)
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxPodWatchBackoff {
+			backoff = maxPodWatchBackoff
+		}
+		if !expired {
+			continue
+		}
+		podList, err := w.podsFinder.FindAll(w.user, w.apiKey, w.addr, w.environment)
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the pods of the environment").String())
+		}
+		resourceVersion = podList.ResourceVersion
+		if !relisted(*podList) {
+			return nil
+		}
+	}
+}
+
+//waitTimeoutMessage returns the reason of the failure when no pod of the selection was ready in time
+func (t podTarget) waitTimeoutMessage() string {
+	switch {
+	case t.selector != "":
+		return fmt.Sprintf(msgs.WaitForSelectorPodTimeout, t.selector, t.wait)
+	case t.pod != "":
+		return fmt.Sprintf(msgs.WaitForNamedPodTimeout, t.pod, t.wait)
+	}
+	return fmt.Sprintf(msgs.WaitForPodTimeout, t.service, t.wait)
+}
+
+//podSelection is the pod and the container chosen with the flags among the running pods, the user is asked to choose
+//when several of them match and the standard input is a terminal
+type podSelection struct {
+	//pod is the name, or the beginning of the name, of the pod to use instead of a pod of the service
+	pod string
+	//replicaIndex is the index of the pod of the service in the pods sorted by name, -1 when not set
+	replicaIndex int
+	container    string
+	//withContainer is true when the command runs in a container of the pod, which can then be chosen
+	withContainer bool
+	//wait is how long to wait for a ready pod when none is found, the command fails right away when it is zero
+	wait       time.Duration
+	qp         util.QuestionPrompter
+	isTerminal func() bool
+	writer     io.Writer
+	//allPods and selector select several pods: all the running pods of the service or the running pods matching the
+	//label selector
+	allPods  bool
+	selector string
+}
+
+//addPodSelectionFlags adds the flags choosing the pod of the command
+func (s *podSelection) addPodSelectionFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&s.pod, "pod", "", "The pod to use instead of a running pod of the service, the beginning of its name is enough when it is not ambiguous")
+	flags.IntVar(&s.replicaIndex, "replica-index", -1, "The running pod of the service to use, given by its index starting from 0 in the pods sorted by name")
+	flags.DurationVar(&s.wait, "wait", 0, "Wait for a pod to be running and ready, for 5 minutes or for the given duration (e.g.: --wait=30s), instead of failing when there is none")
+	flags.Lookup("wait").NoOptDefVal = defaultPodWait.String()
+}
+
+//addMultiplePodsFlags adds the flags selecting several pods for the command
+func (s *podSelection) addMultiplePodsFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&s.allPods, "all-pods", false, "Use all the running pods of the service")
+	flags.StringVarP(&s.selector, "selector", "l", "", "Use all the running pods matching the label selector (e.g.: -l app=web,tier=frontend) instead of the pods of the service")
+}
+
+//multiplePods returns true when the selection is several pods
+func (s podSelection) multiplePods() bool {
+	return s.allPods || s.selector != ""
+}
+
+//labelSelector returns the parsed label selector, it is checked by validateSelection
+func (s podSelection) labelSelector() labels.Selector {
+	selector, err := labels.Parse(s.selector)
+	if err != nil {
+		return labels.Everything()
+	}
+	return selector
+}
+
+//addContainerFlag adds the flag choosing the container of the pod where the command runs
+func (s *podSelection) addContainerFlag(flags *pflag.FlagSet) {
+	s.withContainer = true
+	flags.StringVar(&s.container, "container", "", "The container of the pod to use, by default the first container of the pod")
+}
+
+// validateSelection checks that the pod is chosen either by name or by replica index.
+func (s podSelection) validateSelection() error {
+	if s.pod != "" && s.replicaIndex >= 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.PodAndReplicaIndexConflict).String())
+	}
+	if s.multiplePods() && (s.pod != "" || s.replicaIndex >= 0 || (s.allPods && s.selector != "")) {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.MultiplePodsConflict).String())
+	}
+	if _, err := labels.Parse(s.selector); s.selector != "" && err != nil {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.SelectorInvalid, s.selector, err.Error())).String())
+	}
+	return nil
+}
+
+//selectPodByName returns the running pod whose name is, or starts with, the pod of the selection
+func (s podSelection) selectPodByName(running []api.Pod, environment string) (*api.Pod, error) {
+	var matches []api.Pod
+	for key, pod := range running {
+		if pod.GetName() == s.pod {
+			return &running[key], nil
+		}
+		if strings.HasPrefix(pod.GetName(), s.pod) {
+			matches = append(matches, pod)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return nil, errors.New(cperrors.NewStatefulErrorMessage(http.StatusNotFound, fmt.Sprintf(msgs.PodNameNotFound, s.pod, environment)).String())
+	case len(matches) == 1:
+		return &matches[0], nil
+	case !s.canAsk():
+		return nil, errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.PodNameAmbiguous, s.pod, strings.Join(podNames(matches), ", "))).String())
+	}
+	return &matches[s.choose("pod", podOptions(matches))], nil
+}
+
+//selectServicePod returns the pod of the service at the replica index, by default the user chooses among the pods or,
+//when the standard input is not a terminal, the first one is used. The candidates are in the order of preference
+func (s podSelection) selectServicePod(candidates []api.Pod, service string) (*api.Pod, error) {
+	if s.replicaIndex >= 0 {
+		sorted := append([]api.Pod{}, candidates...)
+		sort.Sort(podsByName(sorted))
+		if s.replicaIndex >= len(sorted) {
+			return nil, errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.ReplicaIndexOutOfRange, s.replicaIndex, service, len(sorted))).String())
+		}
+		return &sorted[s.replicaIndex], nil
+	}
+	if len(candidates) > 1 && s.canAsk() {
+		return &candidates[s.choose("pod", podOptions(candidates))], nil
+	}
+	return &candidates[0], nil
+}
+
+//selectContainer returns the container of the selection, when none is given the user chooses among the containers of
+//the pod or, when the standard input is not a terminal, an empty name selects the default container
+func (s podSelection) selectContainer(pod *api.Pod) (string, error) {
+	if !s.withContainer {
+		return "", nil
+	}
+	var names []string
+	for _, container := range pod.Spec.Containers {
+		names = append(names, container.Name)
+	}
+	if s.container != "" {
+		if !slice.ContainString(s.container, names) {
+			return "", errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.ContainerNotFound, pod.GetName(), s.container, strings.Join(names, ", "))).String())
+		}
+		return s.container, nil
+	}
+	if len(names) > 1 && s.canAsk() {
+		return names[s.choose("container", names)], nil
+	}
+	return "", nil
+}
+
+//canAsk returns true when the user can answer the questions of the picker
+func (s podSelection) canAsk() bool {
+	if s.isTerminal != nil {
+		return s.isTerminal()
+	}
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+//choose asks the user to pick one of the options and returns its index
+func (s podSelection) choose(entity string, options []string) int {
+	qp := s.qp
+	if qp == nil {
+		qp = util.NewQuestionPrompt()
+	}
+	printedOptions := ""
+	for key, option := range options {
+		printedOptions = printedOptions + fmt.Sprintf("[%d] %s\n", key, option)
+	}
+	question := fmt.Sprintf("Which %s would you like to use?\n"+
+		"%s\n"+
+		"Please insert the option in full or type its corresponding value between [0-%d]:", entity, printedOptions, len(options)-1)
+
+	index := -1
+	qp.RepeatUntilValid(question, func(answer string) (bool, error) {
+		for key, option := range options {
+			if answer == strconv.Itoa(key) || answer == strings.Fields(option)[0] {
+				index = key
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("the option '%s' is not valid", answer)
+	})
+	return index
+}
+
+//podOptions returns the names of the pods followed by their readiness, as presented in the picker
+func podOptions(list []api.Pod) []string {
+	var options []string
+	for _, pod := range list {
+		state := "ready"
+		if !pods.IsReady(pod) {
+			state = "not ready"
+		}
+		options = append(options, fmt.Sprintf("%s (%s)", pod.GetName(), state))
+	}
+	return options
+}
+
+func podNames(list []api.Pod) []string {
+	var names []string
+	for _, pod := range list {
+		names = append(names, pod.GetName())
+	}
+	return names
+}
+
+type podsByName []api.Pod
+
+func (s podsByName) Len() int           { return len(s) }
+func (s podsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s podsByName) Less(i, j int) bool { return s[i].GetName() < s[j].GetName() }
+
+//streamInPod executes the command in the container of the pod without a terminal, the input is sent to the command when not nil
+func streamInPod(clientConfig clientcmd.ClientConfig, pod string, container string, in io.Reader, out io.Writer, errOut io.Writer, command ...string) error {
+	kubeCmdExec := kubectlcmd.NewCmdExec(kubectlcmdutil.NewFactory(clientConfig), in, out, errOut)
+	kubeCmdExecOptions := &kubectlcmd.ExecOptions{
+		StreamOptions: kubectlcmd.StreamOptions{
+			In:  in,
+			Out: out,
+			Err: errOut,
+		},
+
+		Executor: &kubectlcmd.DefaultRemoteExecutor{},
+	}
+	kubeCmdExecOptions.Stdin = in != nil
+	kubeCmdExecOptions.PodName = pod
+	kubeCmdExecOptions.ContainerName = container
+
+	err := kubeCmdExecOptions.Complete(kubectlcmdutil.NewFactory(clientConfig), kubeCmdExec, command, kubeCmdExec.ArgsLenAtDash())
+	if err != nil {
+		return err
+	}
+	err = kubeCmdExecOptions.Validate()
+	if err != nil {
+		return err
+	}
+	return kubeCmdExecOptions.Run()
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+//answerPrompt answers the questions with the same answer
+type answerPrompt struct {
+	answer string
+}
+
+func (p answerPrompt) ApplyDefault(string, string) string { return p.answer }
+func (p answerPrompt) RepeatIfEmpty(string) string        { return p.answer }
+func (p answerPrompt) RepeatUntilValid(q string, isValid func(string) (bool, error)) string {
+	isValid(p.answer)
+	return p.answer
+}
+func (p answerPrompt) RepeatPasswordIfEmpty(string) string { return p.answer }
+func (p answerPrompt) RepeatPasswordUntilValid(q string, isValid func(string) (bool, error)) string {
+	return p.RepeatUntilValid(q, isValid)
+}
+
+func newTargetPod(name string, containers ...string) api.Pod {
+	pod := api.Pod{}
+	pod.Name = name
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, api.Container{Name: container})
+	}
+	return pod
+}
+
+func TestPodSelection_SelectServicePod(t *testing.T) {
+	candidates := []api.Pod{newTargetPod("web-2"), newTargetPod("web-0"), newTargetPod("web-1")}
+	notTerminal := func() bool { return false }
+	terminal := func() bool { return true }
+
+	tests := []struct {
+		scenario  string
+		selection podSelection
+		expected  string
+	}{
+		{"the preferred pod is used by default", podSelection{replicaIndex: -1, isTerminal: notTerminal}, "web-2"},
+		{"the replica index follows the order of the names", podSelection{replicaIndex: 1, isTerminal: notTerminal}, "web-1"},
+		{"the user picks the pod in a terminal", podSelection{replicaIndex: -1, isTerminal: terminal, qp: answerPrompt{"2"}}, "web-1"},
+	}
+	for _, test := range tests {
+		pod, err := test.selection.selectServicePod(candidates, "web")
+		assert.Nil(t, err, test.scenario)
+		assert.Equal(t, test.expected, pod.GetName(), test.scenario)
+	}
+
+	_, err := podSelection{replicaIndex: 3}.selectServicePod(candidates, "web")
+	assert.NotNil(t, err, "the replica index is out of range")
+}
+
+func TestPodSelection_SelectPodByName(t *testing.T) {
+	running := []api.Pod{newTargetPod("web-81237-mxiwy"), newTargetPod("web-81237-cosjd"), newTargetPod("web-worker-1")}
+	notTerminal := func() bool { return false }
+
+	pod, err := podSelection{pod: "web-81237-c", isTerminal: notTerminal}.selectPodByName(running, "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "web-81237-cosjd", pod.GetName(), "a unique prefix is enough")
+
+	_, err = podSelection{pod: "web-81237", isTerminal: notTerminal}.selectPodByName(running, "dev")
+	assert.NotNil(t, err, "an ambiguous prefix is refused when the user can't choose")
+
+	pod, err = podSelection{pod: "web-81237", isTerminal: func() bool { return true }, qp: answerPrompt{"web-81237-cosjd"}}.selectPodByName(running, "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "web-81237-cosjd", pod.GetName(), "the user chooses among the matching pods")
+
+	_, err = podSelection{pod: "mysql"}.selectPodByName(running, "dev")
+	assert.NotNil(t, err, "no pod matches")
+}
+
+func TestPodSelection_SelectContainer(t *testing.T) {
+	pod := newTargetPod("web-1", "php", "nginx")
+	notTerminal := func() bool { return false }
+
+	tests := []struct {
+		scenario  string
+		selection podSelection
+		expected  string
+	}{
+		{"the default container is used", podSelection{withContainer: true, isTerminal: notTerminal}, ""},
+		{"the container of the flag is used", podSelection{withContainer: true, container: "nginx"}, "nginx"},
+		{"the user picks the container in a terminal", podSelection{withContainer: true, isTerminal: func() bool { return true }, qp: answerPrompt{"1"}}, "nginx"},
+		{"the container is not chosen for a command that doesn't run in a container", podSelection{container: "nginx"}, ""},
+	}
+	for _, test := range tests {
+		container, err := test.selection.selectContainer(&pod)
+		assert.Nil(t, err, test.scenario)
+		assert.Equal(t, test.expected, container, test.scenario)
+	}
+
+	_, err := podSelection{withContainer: true, container: "redis"}.selectContainer(&pod)
+	assert.NotNil(t, err, "the container doesn't exist")
+}
+
+//fakePodsWatch sends the events of the pods to the watcher of the command
+type fakePodsWatch struct {
+	events chan watch.Event
+}
+
+func (w fakePodsWatch) Stop()                          {}
+func (w fakePodsWatch) ResultChan() <-chan watch.Event { return w.events }
+
+type fakePodsFinder struct {
+	watcher fakePodsWatch
+	//podList are the pods listed, an empty list when nil
+	podList *api.PodList
+}
+
+func (f fakePodsFinder) FindAll(user string, apiKey string, address string, environment string) (*api.PodList, error) {
+	if f.podList != nil {
+		return f.podList, nil
+	}
+	return &api.PodList{}, nil
+}
+func (f fakePodsFinder) FindServices(user string, apiKey string, address string, environment string) (*api.ServiceList, error) {
+	return &api.ServiceList{}, nil
+}
+func (f fakePodsFinder) WatchAll(user string, apiKey string, address string, environment string, resourceVersion string) (watch.Interface, error) {
+	return f.watcher, nil
+}
+
+func TestPodTarget_WaitForPods(t *testing.T) {
+	pending := newTargetPod("web-1")
+	pending.Status.Phase = api.PodPending
+	pending.Status.ContainerStatuses = []api.ContainerStatus{{State: api.ContainerState{Waiting: &api.ContainerStateWaiting{Reason: "ContainerCreating"}}}}
+	notReady := newTargetPod("web-1")
+	notReady.Status.Phase = api.PodRunning
+	ready := notReady
+	ready.Status.Conditions = []api.PodCondition{{Type: api.PodReady, Status: api.ConditionTrue}}
+	other := newTargetPod("mysql-1")
+	other.Status.Phase = api.PodPending
+
+	events := make(chan watch.Event, 5)
+	events <- watch.Event{Type: watch.Added, Object: &pending}
+	events <- watch.Event{Type: watch.Added, Object: &other}
+	events <- watch.Event{Type: watch.Modified, Object: &notReady}
+	events <- watch.Event{Type: watch.Modified, Object: &ready}
+
+	out := &bytes.Buffer{}
+	target := podTarget{environment: "dev", service: "web"}
+	target.wait = time.Minute
+	target.writer = out
+	podList, _, err := target.waitForPods(fakePodsFinder{watcher: fakePodsWatch{events}}, pods.NewKubePodsFilter(), "user", "key", "addr", api.PodList{}, api.ServiceList{})
+
+	assert.Nil(t, err)
+	assert.Len(t, podList.Items, 2)
+	assert.Equal(t, "Waiting up to 1m0s for a pod of the service 'web' to be ready.\n"+
+		"  web-1: ContainerCreating\n"+
+		"  web-1: Running, not ready\n"+
+		"  web-1: Running\n", out.String())
+
+	target.wait = 10 * time.Millisecond
+	_, _, err = target.waitForPods(fakePodsFinder{watcher: fakePodsWatch{make(chan watch.Event)}}, pods.NewKubePodsFilter(), "user", "key", "addr", api.PodList{}, api.ServiceList{})
+	assert.NotNil(t, err, "the wait times out")
+
+	target.pod = "web-1"
+	_, _, err = target.waitForPods(fakePodsFinder{watcher: fakePodsWatch{make(chan watch.Event)}}, pods.NewKubePodsFilter(), "user", "key", "addr", api.PodList{}, api.ServiceList{})
+	assert.Contains(t, err.Error(), "The pod 'web-1' was not ready after waiting 10ms.", "the timeout names the pod that was waited for")
+}
+
+func TestPodTarget_WaitForPods_ExpiredWatch(t *testing.T) {
+	minPodWatchBackoff = time.Millisecond
+	defer func() { minPodWatchBackoff = time.Second }()
+	ready := newTargetPod("web-1")
+	ready.Status.Phase = api.PodRunning
+	ready.Status.Conditions = []api.PodCondition{{Type: api.PodReady, Status: api.ConditionTrue}}
+
+	events := make(chan watch.Event, 1)
+	events <- watch.Event{Type: watch.Error, Object: &unversioned.Status{Code: 410, Reason: unversioned.StatusReasonExpired}}
+	finder := fakePodsFinder{watcher: fakePodsWatch{events}, podList: &api.PodList{Items: []api.Pod{ready}}}
+
+	out := &bytes.Buffer{}
+	target := podTarget{environment: "dev", service: "web"}
+	target.wait = time.Minute
+	target.writer = out
+	podList, _, err := target.waitForPods(finder, pods.NewKubePodsFilter(), "user", "key", "addr", api.PodList{}, api.ServiceList{})
+
+	assert.Nil(t, err)
+	assert.Len(t, podList.Items, 1, "the pods are listed again when the watch expired")
+	assert.Contains(t, out.String(), "  web-1: Running\n")
+}
@@ -5,7 +5,10 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	gosync "sync"
+	"syscall"
 	"time"
 
 	"github.com/continuouspipe/remote-environment-client/config"
@@ -21,6 +24,7 @@ import (
 	"github.com/continuouspipe/remote-environment-client/sync/monitor"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
 	"github.com/continuouspipe/remote-environment-client/util"
+	"github.com/continuouspipe/remote-environment-client/util/slice"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -28,6 +32,10 @@ import (
 //WatchCmdName is the command name identifier
 const WatchCmdName = "watch"
 
+//watchMaxBackups is the number of most recent backups kept by the watch, which backs up the remote files of each sync
+//deleting or overwriting them
+const watchMaxBackups = 20
+
 func NewWatchCmd() *cobra.Command {
 	settings := config.C
 	handler := &WatchHandle{}
@@ -73,9 +81,17 @@ func NewWatchCmd() *cobra.Command {
 	checkErr(err)
 	service, err := settings.GetString(config.Service)
 	checkErr(err)
+	useGitIgnore, err := settings.GetBool(config.UseGitIgnore)
+	checkErr(err)
+	changeDetection, err := settings.GetString(config.ChangeDetection)
+	checkErr(err)
+	backup, err := settings.GetString(config.Backup)
+	checkErr(err)
 
 	command.PersistentFlags().StringVarP(&handler.options.environment, config.KubeEnvironmentName, "e", environment, "The full remote environment name")
 	command.PersistentFlags().StringVarP(&handler.options.service, config.Service, "s", service, "The service to use (e.g.: web, mysql)")
+	handler.options.addPodSelectionFlags(command.PersistentFlags())
+	handler.options.addContainerFlag(command.PersistentFlags())
 	command.PersistentFlags().Int64VarP(&handler.options.latency, "latency", "l", 500, "Sync latency / speed in milli-seconds")
 	command.PersistentFlags().IntVarP(&handler.options.individualFileSyncThreshold, "individual-file-sync-threshold", "t", 10, "Above this threshold the watch command will sync any file or folder that is different compared to the local one")
 	command.PersistentFlags().StringVarP(&handler.options.remoteProjectPath, "remote-project-path", "a", "/app/", "Specify the absolute path to your project folder, by default set to /app/")
@@ -83,6 +99,11 @@ func NewWatchCmd() *cobra.Command {
 	command.PersistentFlags().BoolVar(&handler.options.rsyncVerbose, "rsync-verbose", false, "Allows to use rsync in verbose mode and debug issues with exclusions")
 	command.PersistentFlags().BoolVar(&handler.options.delete, "delete", false, "Delete extraneous files from destination directories")
 	command.PersistentFlags().BoolVarP(&handler.options.yall, "yes", "y", false, "Skip warning")
+	command.PersistentFlags().BoolVar(&handler.options.useGitIgnore, config.UseGitIgnore, useGitIgnore, "Exclude the files ignored by git using the .gitignore files of the project")
+	command.PersistentFlags().StringVar(&handler.options.changeDetection, config.ChangeDetection, changeDetection, fmt.Sprintf("Strategy used to find the changed files (%s)", strings.Join(options.ChangeDetectionStrategies(), ", ")))
+	command.PersistentFlags().StringVar(&handler.options.backup, config.Backup, backup, fmt.Sprintf("Where the remote files deleted or overwritten when using --delete are backed up (%s)", strings.Join(options.BackupModes(), ", ")))
+	command.PersistentFlags().BoolVar(&handler.options.noHooks, "no-hooks", false, "Don't run the post-sync commands of the project configuration after each sync")
+	addTransferFlags(command, &handler.options.transfer, settings)
 	return command
 }
 
@@ -90,10 +111,12 @@ func RunWatch(handler *WatchHandle, args []string, settings *config.Config) (rea
 	dirMonitor := monitor.GetOsDirectoryMonitor()
 
 	exclusion := monitor.NewExclusion()
+	exclusion.UseGitIgnore = handler.options.useGitIgnore
 	_, err = exclusion.WriteDefaultExclusionsToFile()
 	if err != nil {
 		return fmt.Sprintf(msgs.SuggestionWriteDefaultExclusionFileFailed, monitor.CustomExclusionsFile, session.CurrentSession.SessionID), errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, "write to the default exclusion file before push has failed").String())
 	}
+	dirMonitor.SetExclusions(exclusion)
 
 	podsFinder := pods.NewKubePodsFind()
 	podsFilter := pods.NewKubePodsFilter()
@@ -128,10 +151,14 @@ type WatchHandle struct {
 }
 
 type watchCmdOptions struct {
+	podSelection
 	environment, service, remoteProjectPath string
 	latency                                 int64
 	individualFileSyncThreshold             int
 	rsyncVerbose, dryRun, delete, yall      bool
+	useGitIgnore, noHooks                   bool
+	changeDetection, backup                 string
+	transfer                                options.TransferOptions
 }
 
 // Complete verifies command line arguments and loads data from the command environment
@@ -142,6 +169,12 @@ func (h *WatchHandle) Complete(argsIn []string, settings *config.Config) {
 	if h.options.service == "" {
 		h.options.service = settings.GetStringQ(config.Service)
 	}
+	if h.options.changeDetection == "" {
+		h.options.changeDetection = options.ChangeDetectionChecksum
+	}
+	if h.options.backup == "" {
+		h.options.backup = options.BackupPod
+	}
 	if strings.HasSuffix(h.options.remoteProjectPath, "/") == false {
 		h.options.remoteProjectPath = h.options.remoteProjectPath + "/"
 	}
@@ -161,6 +194,20 @@ func (h *WatchHandle) Validate() (suggestion string, err error) {
 	if strings.HasPrefix(h.options.remoteProjectPath, "/") == false {
 		return msgs.RemoteProjectPathEmpty, errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msgs.RemoteProjectPathEmpty).String())
 	}
+	if !slice.ContainString(h.options.changeDetection, options.ChangeDetectionStrategies()) {
+		reason := fmt.Sprintf(msgs.ChangeDetectionInvalid, h.options.changeDetection, strings.Join(options.ChangeDetectionStrategies(), ", "))
+		return reason, errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, reason).String())
+	}
+	if !slice.ContainString(h.options.backup, options.BackupModes()) {
+		reason := fmt.Sprintf(msgs.BackupModeInvalid, h.options.backup, strings.Join(options.BackupModes(), ", "))
+		return reason, errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, reason).String())
+	}
+	if err := h.options.validateSelection(); err != nil {
+		return err.Error(), err
+	}
+	if err := validateTransferOptions(h.options.transfer); err != nil {
+		return err.Error(), err
+	}
 	return "", nil
 }
 
@@ -183,19 +230,15 @@ func (h *WatchHandle) Handle(dirMonitor monitor.DirectoryMonitor, podsFinder pod
 
 	fmt.Fprintf(h.writer, "\nWatching for changes. Quit anytime with Ctrl-C.\n")
 
-	addr, user, apiKey, err := h.kubeCtlInit.GetSettings()
+	_, _, apiKey, err := h.kubeCtlInit.GetSettings()
 	if err != nil {
 		return fmt.Sprintf(msgs.SuggestionGetSettingsError, session.CurrentSession.SessionID), err
 	}
 
-	allPods, err := podsFinder.FindAll(user, apiKey, addr, h.options.environment)
+	target := podTarget{h.options.environment, h.options.service, h.options.podSelection}
+	pod, container, _, suggestion, err := target.findPod(h.kubeCtlInit, podsFinder, podsFilter, WatchCmdName)
 	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionFindPodsFailed, session.CurrentSession.SessionID), err
-	}
-
-	pod := podsFilter.List(*allPods).ByService(h.options.service).ByStatus("Running").ByStatusReason("Running").First()
-	if pod == nil {
-		return fmt.Sprintf(msgs.SuggestionRunningPodNotFound, h.options.service, h.options.environment, config.AppName, "bash", session.CurrentSession.SessionID), errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf(msgs.NoActivePodsFoundForSpecifiedServiceName, h.options.service)).String())
+		return suggestion, err
 	}
 
 	remoteEnvId := h.config.GetStringQ(config.RemoteEnvironmentId)
@@ -217,22 +260,80 @@ func (h *WatchHandle) Handle(dirMonitor monitor.DirectoryMonitor, podsFinder pod
 	syncOptions.KubeConfigKey = h.options.environment
 	syncOptions.Environment = h.options.environment
 	syncOptions.Pod = pod.GetName()
+	syncOptions.Container = container
+	syncOptions.Service = h.options.service
 	syncOptions.IndividualFileSyncThreshold = h.options.individualFileSyncThreshold
 	syncOptions.RemoteProjectPath = h.options.remoteProjectPath
 	syncOptions.DryRun = h.options.dryRun
 	syncOptions.Verbose = h.options.rsyncVerbose
 	syncOptions.Delete = h.options.delete
+	syncOptions.UseGitIgnore = h.options.useGitIgnore
+	syncOptions.ChangeDetection = h.options.changeDetection
+	syncOptions.Backup = h.options.backup
+	syncOptions.MaxBackups = watchMaxBackups
+	syncOptions.Transfer = h.options.transfer
+	//the transport is kept open for the whole watch session
+	syncOptions.KeepAlive = true
 	h.syncer.SetOptions(syncOptions)
+	defer h.syncer.Close()
 
 	dirMonitor.SetLatency(time.Duration(h.options.latency))
 
 	fmt.Fprintf(h.Stdout, "\nDestination Pod: %s\n", pod.GetName())
 
 	observer := sync.GetSyncOnEventObserver(h.syncer)
+	if !h.options.noHooks && !h.options.dryRun {
+		hooks, err := newPostSyncHooks(h.options.environment, h.options.service, h.options.remoteProjectPath, podSelection{pod: pod.GetName(), replicaIndex: -1}, h.writer)
+		if err != nil {
+			return fmt.Sprintf(msgs.SuggestionRunFailed, session.CurrentSession.SessionID), err
+		}
+		observer = hookedObserver{observer, *hooks}
+	}
+	stoppable := &stoppableObserver{observer: observer}
+
+	//the watch is stopped with Ctrl-C, the syncer is then closed once the sync in progress is done
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	monitorErr := make(chan error, 1)
+	go func() {
+		monitorErr <- dirMonitor.AnyEventCall(cwd, stoppable)
+	}()
+
+	select {
+	case err = <-monitorErr:
+		stoppable.stop()
+		if err != nil {
+			return rsyncSuggestion(err, fmt.Sprintf(msgs.SuggestionDirectoryMonitorFailed, session.CurrentSession.SessionID)), err
+		}
+		return "", nil
+	case sig := <-interrupted:
+		cplogs.V(5).Infoln("watch interrupted, closing the syncer")
+		cplogs.Flush()
+		stoppable.stop()
+		reason := fmt.Sprintf(msgs.WatchInterrupted, sig)
+		return reason, errors.New(cperrors.NewStatefulErrorMessage(http.StatusRequestTimeout, reason).String())
+	}
+}
 
-	err = dirMonitor.AnyEventCall(cwd, observer)
-	if err != nil {
-		return fmt.Sprintf(msgs.SuggestionDirectoryMonitorFailed, session.CurrentSession.SessionID), err
+//stoppableObserver ignores the events once the watch is stopped, stop waits for the sync in progress
+type stoppableObserver struct {
+	observer monitor.EventsObserver
+	lock     gosync.Mutex
+	stopped  bool
+}
+
+func (o *stoppableObserver) OnLastChange(events []monitor.PathEvent) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.stopped {
+		return nil
 	}
-	return "", nil
+	return o.observer.OnLastChange(events)
+}
+
+func (o *stoppableObserver) stop() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.stopped = true
 }
@@ -19,6 +19,14 @@ const (
 	AnybarPort          = "anybar-port"
 	RemoteEnvironmentId = "remote-environment-id"
 	InitStatus          = "init-status"
+	UseGitIgnore        = "use-gitignore"
+	ChangeDetection     = "change-detection"
+	BandwidthLimit      = "bwlimit"
+	Compress            = "compress"
+	MaxFileSize         = "max-file-size"
+	Symlinks            = "symlinks"
+	Transport           = "transport"
+	Backup              = "backup"
 
 	//settings to disable the kube proxy if required
 	CpKubeProxyEnabled        = "kube-proxy-enabled"
@@ -39,6 +47,14 @@ func newLocalConfig() *localConfig {
 		{Service, "web", true},                 //Kubernetes service name for the commands like (watch, bash, fetch and resync)
 		{AnybarPort, "", false},                //AnyBar port number
 		{InitStatus, "", false},                //Initialization status used in the init cmd
+		{UseGitIgnore, "false", false},         //Exclude from the sync the files ignored by git
+		{ChangeDetection, "checksum", false},   //Strategy used to find the changed files (checksum, size-mtime, hash-cache)
+		{BandwidthLimit, "", false},            //Maximum transfer rate of the sync in KiB per second
+		{Compress, "true", false},              //Compress the files during the sync
+		{MaxFileSize, "", false},               //Size of the largest file synced
+		{Symlinks, "preserve", false},          //Policy applied to the symbolic links (preserve, copy, skip)
+		{Transport, "", false},                 //Transport used by rsync (rsh, daemon), by default daemon on windows and rsh otherwise
+		{Backup, "pod", false},                 //Where the remote files deleted or overwritten by a push with --delete are backed up (pod, local, none)
 		{RemoteEnvironmentId, "", false},       //Remote environment Id
 		{CpKubeProxyEnabled, "true", false},    //Determine if the Cp Kube proxy is used
 		{KubeDirectClusterAddr, "", false},     //Cluster Address (Used only for direct connections to kubernetes)
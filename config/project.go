@@ -0,0 +1,82 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+//ProjectConfigFile is the configuration shared by the team, unlike the settings file it is committed with the project
+const ProjectConfigFile = ".cp-remote.yml"
+
+//RemoteCommand is a command line of the project configuration that runs in a container of the remote environment
+type RemoteCommand struct {
+	Description string `yaml:"description"`
+	//Command is the command line run by the shell of the container, the arguments given when running it are appended
+	Command string `yaml:"command"`
+	//Service, Container and Workdir are where the command runs, by default the service of the settings, the first
+	//container of the pod and the remote project directory
+	Service   string            `yaml:"service"`
+	Container string            `yaml:"container"`
+	Workdir   string            `yaml:"workdir"`
+	Env       map[string]string `yaml:"env"`
+}
+
+//ServiceSettings are the settings of a service of the remote environment
+type ServiceSettings struct {
+	//Shell is the shell started by the bash command, the shells of the container are probed when it is not found
+	Shell string `yaml:"shell"`
+}
+
+//Project is the content of the project configuration file
+type Project struct {
+	Commands map[string]RemoteCommand   `yaml:"commands"`
+	Services map[string]ServiceSettings `yaml:"services"`
+	Hooks    struct {
+		//PostSync are the names of the commands run after the files are pushed in the remote environment
+		PostSync []string `yaml:"post-sync"`
+	} `yaml:"hooks"`
+}
+
+//LoadProject reads the project configuration file, an empty configuration is returned when the file doesn't exist
+func LoadProject(file string) (*Project, error) {
+	project := &Project{}
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return project, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(content, project)
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+//CommandNames returns the names of the commands sorted alphabetically
+func (p Project) CommandNames() []string {
+	var names []string
+	for name := range p.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//EnvList returns the environment variables of the command as NAME=VALUE sorted by name
+func (c RemoteCommand) EnvList() []string {
+	var names []string
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = name + "=" + c.Env[name]
+	}
+	return list
+}
@@ -1,14 +1,21 @@
 // executes git diff commands
 // e.g. git diff --exit-code --quiet "feature/cp-remote-testing" "origin/feature/cp-remote-testing"
+// e.g. git diff --name-only -z --diff-filter=ACMRT "origin/feature/cp-remote-testing"
 package git
 
 import (
-	"github.com/continuouspipe/remote-environment-client/osapi"
+	"net/http"
 	"path/filepath"
+	"strings"
+
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/osapi"
+	"github.com/pkg/errors"
 )
 
 type DiffExecutor interface {
 	GetDiff(remoteName string, remoteBranch string) (string, error)
+	GetChangedFiles(ref string) ([]string, error)
 }
 
 type diff struct{}
@@ -27,3 +34,33 @@ func (g *diff) GetDiff(remoteName string, remoteBranch string) (string, error) {
 	}
 	return osapi.CommandExec(getGitScmd(), args...)
 }
+
+//GetChangedFiles returns the files added, modified, renamed or whose type changed in the working tree compared to the
+//reference, relative to the current directory
+func (g *diff) GetChangedFiles(ref string) ([]string, error) {
+	args := []string{
+		"diff",
+		"--name-only",
+		"--relative",
+		"-z",
+		"--diff-filter=ACMRT",
+		ref,
+	}
+	//the output is not trimmed, the file names can start or end with spaces
+	res, err := osapi.CommandOutput(getGitScmd(), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, "error when listing the files changed since "+ref).String())
+	}
+	return splitNul(res), nil
+}
+
+//splitNul splits the NUL separated output of the -z git commands
+func splitNul(output string) []string {
+	var files []string
+	for _, file := range strings.Split(output, "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
@@ -0,0 +1,53 @@
+// execute ls-files commands
+// e.g. git ls-files -z
+// e.g. git ls-files -z --others --exclude-standard
+package git
+
+import (
+	"net/http"
+
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/osapi"
+	"github.com/pkg/errors"
+)
+
+type LsFilesExecutor interface {
+	GetTrackedFiles() ([]string, error)
+	GetUntrackedFiles() ([]string, error)
+}
+
+type lsFiles struct{}
+
+func NewLsFiles() *lsFiles {
+	return &lsFiles{}
+}
+
+//GetTrackedFiles returns the files of the index relative to the current directory
+func (g *lsFiles) GetTrackedFiles() ([]string, error) {
+	args := []string{
+		"ls-files",
+		"-z",
+	}
+	//the output is not trimmed, the file names can start or end with spaces
+	res, err := osapi.CommandOutput(getGitScmd(), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, "error when listing the files tracked by git").String())
+	}
+	return splitNul(res), nil
+}
+
+//GetUntrackedFiles returns the files that are neither tracked nor ignored, relative to the current directory
+func (g *lsFiles) GetUntrackedFiles() ([]string, error) {
+	args := []string{
+		"ls-files",
+		"-z",
+		"--others",
+		"--exclude-standard",
+	}
+	//the output is not trimmed, the file names can start or end with spaces
+	res, err := osapi.CommandOutput(getGitScmd(), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusBadRequest, "error when listing the files not tracked by git").String())
+	}
+	return splitNul(res), nil
+}
@@ -1,11 +1,13 @@
 // execute rev-parse commands
 // e.g. git rev-parse --abbrev-ref HEAD
+// e.g. git rev-parse --verify --quiet "origin/feature/cp-remote-testing^{commit}"
 package git
 
 import "github.com/continuouspipe/remote-environment-client/osapi"
 
 type RevParseExecutor interface {
 	GetLocalBranchName() (string, error)
+	CommitExists(ref string) bool
 }
 
 type revParse struct{}
@@ -26,3 +28,15 @@ func (g *revParse) GetLocalBranchName() (string, error) {
 	}
 	return res, nil
 }
+
+//CommitExists returns true when the reference names a commit of the local repository
+func (g *revParse) CommitExists(ref string) bool {
+	args := []string{
+		"rev-parse",
+		"--verify",
+		"--quiet",
+		ref + "^{commit}",
+	}
+	_, err := osapi.CommandExec(getGitScmd(), args...)
+	return err == nil
+}
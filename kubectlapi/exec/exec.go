@@ -13,6 +13,8 @@ type KSCommand struct {
 	Stdin         io.Reader
 	Stdout        io.Writer
 	Stderr        io.Writer
+	//Container is the container of the pod where the command is executed, the default container when empty
+	Container string
 }
 
 type Spawner interface {
@@ -53,8 +55,11 @@ func (l Local) getAllArgs(kscmd KSCommand, execCmdArgs ...string) []string {
 		"exec",
 		"-it",
 		kscmd.Pod,
-		"--",
 	}
+	if kscmd.Container != "" {
+		kubeCmdArgs = append(kubeCmdArgs, "-c", kscmd.Container)
+	}
+	kubeCmdArgs = append(kubeCmdArgs, "--")
 
 	allArgs := append(kubeCmdArgs, execCmdArgs...)
 	return allArgs
@@ -0,0 +1,32 @@
+package logs
+
+import (
+	"io"
+
+	"github.com/continuouspipe/remote-environment-client/kubectlapi"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+//Streamer opens the logs of a container of a pod
+type Streamer interface {
+	Stream(user string, apiKey string, address string, environment string, pod string, options api.PodLogOptions) (io.ReadCloser, error)
+}
+
+type KubeLogsStreamer struct{}
+
+func NewKubeLogsStreamer() *KubeLogsStreamer {
+	return &KubeLogsStreamer{}
+}
+
+//Stream returns the logs of the container of the options, they are streamed as they are written when the options follow them
+func (s KubeLogsStreamer) Stream(user string, apiKey string, address string, environment string, pod string, options api.PodLogOptions) (io.ReadCloser, error) {
+	config, err := kubectlapi.GetNonInteractiveDeferredLoadingClientConfig(user, apiKey, address, environment).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubectlapi.CreateClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.Core().Pods(environment).GetLogs(pod, &options).Stream()
+}
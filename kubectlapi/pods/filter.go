@@ -1,21 +1,27 @@
 package pods
 
 import (
+	"sort"
 	"strings"
 
 	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/labels"
 )
 
 type Filter interface {
 	List(pods api.PodList) Filter
+	Services(services api.ServiceList) Filter
 	ByService(service string) Filter
+	BySelector(selector labels.Selector) Filter
 	ByStatus(status string) Filter
 	ByStatusReason(reason string) Filter
 	First() *api.Pod
+	Items() []api.Pod
 }
 
 type KubePodsFilter struct {
-	podList api.PodList
+	podList     api.PodList
+	serviceList api.ServiceList
 }
 
 func NewKubePodsFilter() *KubePodsFilter {
@@ -27,17 +33,49 @@ func (p KubePodsFilter) List(podList api.PodList) Filter {
 	return p
 }
 
+//Services sets the kubernetes services of the environment, used by ByService to select the pods
+func (p KubePodsFilter) Services(serviceList api.ServiceList) Filter {
+	p.serviceList = serviceList
+	return p
+}
+
+//First returns the preferred pod: the ready pods come before the others and, among them, the newest ones
 func (p KubePodsFilter) First() *api.Pod {
-	if len(p.podList.Items) > 0 {
-		return &p.podList.Items[0]
+	items := p.Items()
+	if len(items) == 0 {
+		return nil
 	}
-	return nil
+	return &items[0]
 }
 
+//Items returns the pods in the order of preference of First
+func (p KubePodsFilter) Items() []api.Pod {
+	items := byPreference(append([]api.Pod{}, p.podList.Items...))
+	sort.Sort(items)
+	return items
+}
+
+//ByService keeps the pods matching the label selector of the kubernetes service, when no such service exists
+//the pods whose name starts with the service name are kept
 func (p KubePodsFilter) ByService(service string) Filter {
+	selector := p.serviceSelector(service)
+	filteredPodItems := p.podList.Items[:0]
+	for _, pod := range p.podList.Items {
+		if selector != nil && selector.Matches(labels.Set(pod.GetLabels())) {
+			filteredPodItems = append(filteredPodItems, pod)
+		} else if selector == nil && strings.HasPrefix(pod.GetName(), service) {
+			filteredPodItems = append(filteredPodItems, pod)
+		}
+	}
+	p.podList.Items = filteredPodItems
+	return p
+}
+
+//BySelector keeps the pods whose labels match the label selector
+func (p KubePodsFilter) BySelector(selector labels.Selector) Filter {
 	filteredPodItems := p.podList.Items[:0]
 	for _, pod := range p.podList.Items {
-		if strings.HasPrefix(pod.GetName(), service) {
+		if selector.Matches(labels.Set(pod.GetLabels())) {
 			filteredPodItems = append(filteredPodItems, pod)
 		}
 	}
@@ -45,6 +83,16 @@ func (p KubePodsFilter) ByService(service string) Filter {
 	return p
 }
 
+//serviceSelector returns the label selector of the service, nil when the service doesn't exist or selects no pods
+func (p KubePodsFilter) serviceSelector(service string) labels.Selector {
+	for _, s := range p.serviceList.Items {
+		if s.GetName() == service && len(s.Spec.Selector) > 0 {
+			return labels.SelectorFromSet(labels.Set(s.Spec.Selector))
+		}
+	}
+	return nil
+}
+
 func (p KubePodsFilter) ByStatus(status string) Filter {
 	filteredPodItems := p.podList.Items[:0]
 	for _, pod := range p.podList.Items {
@@ -59,7 +107,7 @@ func (p KubePodsFilter) ByStatus(status string) Filter {
 func (p KubePodsFilter) ByStatusReason(reason string) Filter {
 	filteredPodItems := p.podList.Items[:0]
 	for _, pod := range p.podList.Items {
-		if reason == statusReason(pod) {
+		if reason == StatusReason(pod) {
 			filteredPodItems = append(filteredPodItems, pod)
 		}
 	}
@@ -82,3 +130,18 @@ func statusToPodPhase(status string) api.PodPhase {
 	}
 	return ""
 }
+
+//byPreference sorts the ready pods first, then the newest ones
+type byPreference []api.Pod
+
+func (s byPreference) Len() int      { return len(s) }
+func (s byPreference) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byPreference) Less(i, j int) bool {
+	if IsReady(s[i]) != IsReady(s[j]) {
+		return IsReady(s[i])
+	}
+	if !s[i].CreationTimestamp.Equal(s[j].CreationTimestamp.Time) {
+		return s[i].CreationTimestamp.After(s[j].CreationTimestamp.Time)
+	}
+	return s[i].GetName() < s[j].GetName()
+}
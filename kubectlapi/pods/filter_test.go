@@ -2,6 +2,7 @@ package pods
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"k8s.io/kubernetes/pkg/api"
@@ -45,6 +46,101 @@ func TestKubePodsFilter(t *testing.T) {
 		podSucceeded,
 	}
 
-	found := KubePodsFilter{podList}.ByService("web").ByStatus("Running").ByStatusReason("Running").First()
+	found := KubePodsFilter{podList: podList}.ByService("web").ByStatus("Running").ByStatusReason("Running").First()
 	assert.Equal(t, "web-812374193-mxiwy", found.Name)
 }
+
+func TestKubePodsFilter_ByService(t *testing.T) {
+	newPod := func(name string, labels map[string]string) api.Pod {
+		pod := api.Pod{}
+		pod.Name = name
+		pod.Labels = labels
+		return pod
+	}
+	podList := api.PodList{}
+	podList.Items = []api.Pod{
+		newPod("web-812374193-mxiwy", map[string]string{"component-identifier": "web"}),
+		newPod("webpack-151435215-nsdaf", map[string]string{"component-identifier": "webpack"}),
+		newPod("web-worker-981327404-bargs", map[string]string{"component-identifier": "web-worker"}),
+		newPod("frontend-989823427-cosjd", map[string]string{"component-identifier": "web"}),
+	}
+	web := api.Service{}
+	web.Name = "web"
+	web.Spec.Selector = map[string]string{"component-identifier": "web"}
+	serviceList := api.ServiceList{}
+	serviceList.Items = []api.Service{web}
+
+	scenarios := []struct {
+		serviceList api.ServiceList
+		service     string
+		description string
+		expected    []string
+	}{
+		{
+			serviceList,
+			"web",
+			"the pods are selected with the labels of the service",
+			[]string{"web-812374193-mxiwy", "frontend-989823427-cosjd"},
+		},
+		{
+			serviceList,
+			"web-worker",
+			"the pods are selected by name when the service doesn't exist",
+			[]string{"web-worker-981327404-bargs"},
+		},
+		{
+			api.ServiceList{},
+			"web",
+			"the pods are selected by name when there are no services",
+			[]string{"web-812374193-mxiwy", "webpack-151435215-nsdaf", "web-worker-981327404-bargs"},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		list := api.PodList{Items: append([]api.Pod{}, podList.Items...)}
+		filtered := KubePodsFilter{}.List(list).Services(scenario.serviceList).ByService(scenario.service).(KubePodsFilter)
+		var names []string
+		for _, pod := range filtered.podList.Items {
+			names = append(names, pod.Name)
+		}
+		assert.Equal(t, scenario.expected, names, scenario.description)
+	}
+}
+
+func TestKubePodsFilter_First(t *testing.T) {
+	newPod := func(name string, created time.Time, ready bool) api.Pod {
+		pod := api.Pod{}
+		pod.Name = name
+		pod.CreationTimestamp = unversioned.NewTime(created)
+		status := api.ConditionFalse
+		if ready {
+			status = api.ConditionTrue
+		}
+		pod.Status.Conditions = []api.PodCondition{{Type: api.PodReady, Status: status}}
+		return pod
+	}
+	now := time.Now()
+
+	scenarios := []struct {
+		pods        []api.Pod
+		description string
+		expected    string
+	}{
+		{
+			[]api.Pod{newPod("web-1-old", now.Add(-time.Hour), true), newPod("web-2-new", now, true)},
+			"the newest pod is preferred",
+			"web-2-new",
+		},
+		{
+			[]api.Pod{newPod("web-2-new", now, false), newPod("web-1-old", now.Add(-time.Hour), true)},
+			"a ready pod is preferred to a newer pod that is not ready",
+			"web-1-old",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		found := KubePodsFilter{}.List(api.PodList{Items: scenario.pods}).First()
+		assert.Equal(t, scenario.expected, found.Name, scenario.description)
+	}
+	assert.Nil(t, KubePodsFilter{}.First(), "no pod is returned when the list is empty")
+}
@@ -2,11 +2,15 @@ package pods
 
 import (
 	"github.com/continuouspipe/remote-environment-client/kubectlapi"
+	"github.com/continuouspipe/remote-environment-client/kubectlapi/services"
 	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/watch"
 )
 
 type Finder interface {
 	FindAll(user string, apiKey string, address string, environment string) (*api.PodList, error)
+	FindServices(user string, apiKey string, address string, environment string) (*api.ServiceList, error)
+	WatchAll(user string, apiKey string, address string, environment string, resourceVersion string) (watch.Interface, error)
 }
 
 type KubePodsFind struct{}
@@ -30,3 +34,21 @@ func (p KubePodsFind) FindAll(user string, apiKey string, address string, enviro
 
 	return client.Core().Pods(environment).List(api.ListOptions{})
 }
+
+//FindServices returns the kubernetes services of the environment, used to select the pods of a service
+func (p KubePodsFind) FindServices(user string, apiKey string, address string, environment string) (*api.ServiceList, error) {
+	return services.NewKubeService().FindAll(user, apiKey, address, environment)
+}
+
+//WatchAll watches the changes of the pods of the environment that happen after the resource version
+func (p KubePodsFind) WatchAll(user string, apiKey string, address string, environment string, resourceVersion string) (watch.Interface, error) {
+	config, err := kubectlapi.GetNonInteractiveDeferredLoadingClientConfig(user, apiKey, address, environment).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubectlapi.CreateClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.Core().Pods(environment).Watch(api.ListOptions{ResourceVersion: resourceVersion})
+}
@@ -7,8 +7,8 @@ import (
 	"k8s.io/kubernetes/pkg/util/node"
 )
 
-//statusReason returns the reason for the pod status, extracted from kuberentes resource_printer.go
-func statusReason(pod api.Pod) string {
+//StatusReason returns the reason for the pod status, extracted from kuberentes resource_printer.go
+func StatusReason(pod api.Pod) string {
 	initializing := false
 	reason := string(pod.Status.Phase)
 
@@ -67,3 +67,13 @@ func statusReason(pod api.Pod) string {
 
 	return reason
 }
+
+//IsReady returns true when the pod passes its readiness checks
+func IsReady(pod api.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == api.PodReady {
+			return condition.Status == api.ConditionTrue
+		}
+	}
+	return false
+}
@@ -5,6 +5,36 @@ Please contact support specifying the session number '%s'.`
 
 const NoActivePodsFoundForSpecifiedServiceName = `No running pods were found for the specified service name '%s'.`
 
+const NoActivePodsFoundForSelector = `No running pods were found matching the selector '%s'.`
+
+const MultiplePodsConflict = `The --all-pods and --selector flags can't be used together or with the --pod and --replica-index flags.`
+
+const SelectorInvalid = `The selector '%s' is not valid: %s.`
+
+const PodNameNotFound = `No running pod whose name starts with '%s' was found in the environment %s.`
+
+const PodNameAmbiguous = `Several running pods match the name '%s': %s. Please specify the full name of the pod with the --pod flag.`
+
+const PodAndReplicaIndexConflict = `The --pod and --replica-index flags can't be used together.`
+
+const ReplicaIndexOutOfRange = `The replica index %d is not valid, the service '%s' has %d running pods.`
+
+const ContainerNotFound = `The pod %s has no container '%s', please use one of: %s.`
+
+const WaitingForPod = `Waiting up to %s for a pod of the service '%s' to be ready.`
+
+const WaitingForSelectorPod = `Waiting up to %s for a pod matching the selector '%s' to be ready.`
+
+const WaitingForNamedPod = `Waiting up to %s for the pod '%s' to be ready.`
+
+const WaitingPodStatus = `  %s: %s`
+
+const WaitForPodTimeout = `No pod of the service '%s' was ready after waiting %s.`
+
+const WaitForSelectorPodTimeout = `No pod matching the selector '%s' was ready after waiting %s.`
+
+const WaitForNamedPodTimeout = `The pod '%s' was not ready after waiting %s.`
+
 const ProjectsNotFound = `No projects were found. Please ensure that you have at least one project set up in ContinuousPipe.`
 
 const FlowsNotFound = `No flows were found. Please ensure that the project has at least one flow.`
@@ -30,14 +60,54 @@ const ServiceSpecifiedEmpty = `The service name specified is an empty string. Pl
 
 const RemoteProjectPathEmpty = `The remote project path is an empty string. Please ensure that the remote project path specified with the --remote-project-path flag is a valid path.`
 
+const ChangeDetectionInvalid = `The change detection strategy '%s' is not valid, please use one of: %s.`
+
+const ParallelValueTooSmall = `The number of parallel transfers specified with the --parallel flag needs to be at least 1.`
+
+const BandwidthLimitInvalid = `The bandwidth limit '%s' is not valid, please specify the KiB per second optionally followed by a unit (e.g.: 500, 1.5m).`
+
+const MaxFileSizeInvalid = `The maximum file size '%s' is not valid, please specify the number of bytes optionally followed by a unit (e.g.: 500k, 10M, 1G).`
+
+const SymlinkPolicyInvalid = `The symbolic link policy '%s' is not valid, please use one of: %s.`
+
+const TransportInvalid = `The transport '%s' is not valid, please use one of: %s.`
+
+const BackupModeInvalid = `The backup location '%s' is not valid, please use one of: %s.`
+
+const BackupCreated = `The %d remote files deleted or overwritten by the sync have been backed up in the backup %s, stored in %s.
+`
+
+const BackupRestoreHint = `Run '%s restore %s' to put them back.
+`
+
+const RsyncNotFoundLocally = `rsync is not installed on this machine or is not in the PATH.
+Please install rsync %s or newer and try again.`
+
+const RsyncTooOldLocally = `The rsync %s installed on this machine uses the protocol version %d, the protocol version %d (rsync %s) or newer is required.
+Please upgrade rsync on this machine and try again.`
+
+const RsyncNotFoundInPod = `rsync is not installed in the pod %s, which runs the image %s.
+Please add rsync %s or newer to the image and deploy the environment again.`
+
+const RsyncTooOldInPod = `The rsync %s installed in the pod %s, which runs the image %s, uses the protocol version %d, the protocol version %d (rsync %s) or newer is required.
+Please upgrade rsync in the image and deploy the environment again.`
+
 const FetchInProgress = `Fetch in progress.`
 
 const FetchCompleted = `Fetch completed.`
 
+const FetchNothingModified = `No remote file has been modified since %s.`
+
+const FetchSinceInvalid = `The time '%s' given to --since is not valid, please specify a duration (e.g.: 30m, 2h) or a date optionally followed by a time (e.g.: 2017-06-12, 2017-06-12 15:30).`
+
+const FetchSinceWithDelete = `The --since and --delete flags can't be used together as only the files modified remotely are compared.`
+
 const PushInProgress = `Push in progress`
 
 const LatencyValueTooSmall = `Please specify a latency of at least 100 milli-seconds.`
 
+const WatchInterrupted = `The watch was stopped by the signal %s.`
+
 const CheckingConnectionForEnvironment = `Checking connection for environment %s.`
 
 const PodsFoundCount = `%d pods have been found:`
@@ -52,9 +122,54 @@ const DestroyCommandShortDescription = `Destroy the remote environment.`
 
 const DestroyCommandLongDescription = `The destroy command will delete the remote branch used for your remote environment. ContinuousPipe will then automatically delete the remote environment.`
 
+const BashCommandLongDescription = `This will remotely connect to a shell session onto the default container specified during setup but you can specify another container to connect to. The session starts in the remote project directory with a prompt showing the environment and the service. The shell given with --shell, or the shell of the service in the 'services' section of the .cp-remote.yml file, is started when it is installed in the container, otherwise the first of bash, ash and sh found is started.
+
+Example of .cp-remote.yml:
+
+services:
+  web:
+    shell: zsh`
+
+const RecordMultiplePods = `The session can't be recorded when the command runs in several pods.`
+
+const RecordingSession = `Recording the session in %s.`
+
+const RecordingSaved = `The session is recorded in %[1]s, play it back with '%[2]s replay %[1]s'.`
+
+const ReplayCommandShortDescription = `Play back a recorded bash session.`
+
+const ReplayCommandLongDescription = `The replay command plays back in the terminal a session recorded with 'bash --record', at the pace it was recorded. The recordings are in the asciicast v2 format, so they can also be played with asciinema or shared on asciinema.org.`
+
+const ReplayCommandExampleDescription = `
+# play back the session recorded in incident.cast
+%[1]s replay incident.cast
+
+# play it back twice as fast, shortening the pauses longer than 2 seconds
+%[1]s replay incident.cast --speed 2 --idle-time-limit 2s`
+
+const ReplayRecordingMissing = `Please specify the file of the recording to play back.`
+
+const ReplaySpeedInvalid = `The speed needs to be greater than 0.`
+
+const ReplayRecordingNotFound = `The recording %s could not be opened, please check that the file exists.`
+
+const ReplayRecordingInvalid = `The recording %s could not be played back: %s.`
+
+const ReplayCompleted = `End of the recording.`
+
 const ExecCommandShortDescription = `Execute a command on a container.`
 
-const ExecCommandLongDescription = `To execute a command on a container without first getting a bash session use the exec command. The remote command and its arguments need to follow a double dash (--).`
+const ExecCommandLongDescription = `To execute a command on a container without first getting a bash session use the exec command. The remote command and its arguments need to follow a double dash (--). A terminal is allocated when the standard input and output are terminals, which the --tty and --no-tty flags override, and the exit status of the remote command is the exit status of the exec command. The command runs in the remote project directory unless another one is given with --workdir. With the --all-pods or --selector flag the command runs concurrently in all the matching pods, the output lines are prefixed with the name of their pod, the exit code of each pod is listed at the end and the exit status is the highest of them.`
+
+const ExecTTYConflict = `The --tty and --no-tty flags can't be used together.`
+
+const ExecMultiplePodsTTY = `A terminal can't be allocated when the command runs in several pods, please remove the --tty flag.`
+
+const ExecPodsSummary = `Exit codes:`
+
+const ExecPodFailed = `failed: %s`
+
+const ExecEnvInvalid = `The environment variable '%s' is not valid, please specify it as NAME=VALUE.`
 
 const ExecCommandExampleDescription = `
 # execute -ls -all on the web pod
@@ -63,18 +178,78 @@ const ExecCommandExampleDescription = `
 # execute -ls -all on the web pod overriding the environment id
 %[1]s exec -e techup-dev-user -s web -- ls -all
 
+# execute -ls -all in the nginx container of the second pod of the web service
+%[1]s exec --replica-index 1 --container nginx -- ls -all
+
+# execute -ls -all once a pod of the web service is ready, waiting up to 2 minutes
+%[1]s exec --wait=2m -- ls -all
+
+# reload php-fpm, clearing its opcache, in all the pods of the web service, the output lines are prefixed with the name of the pod
+%[1]s exec --all-pods -- kill -USR2 1
+
+# reload the configuration of the pods labelled app=nginx
+%[1]s exec -l app=nginx -- nginx -s reload
+
+# run the tests in continuous integration with an environment variable, the build fails when the tests fail
+%[1]s exec --no-tty --env APP_ENV=test --workdir /app/api -- vendor/bin/phpunit
+
 # execute -ls -all on a different environment (without knowing which one yet)
 %[1]s exec --interactive -- ls -all`
 
+const RunCommandShortDescription = `Run a command of the project configuration on a container.`
+
+const RunCommandLongDescription = `The run command executes one of the commands defined in the 'commands' section of the .cp-remote.yml file of the project, which is shared by the team. A command has a command line and, optionally, a description, the service, the container, the working directory and the environment variables it runs with. The arguments following a double dash (--) are appended to the command line. The commands named in the 'post-sync' list of the 'hooks' section run after each push and after each sync of the watch command.
+
+Example of .cp-remote.yml:
+
+commands:
+  migrate:
+    description: Run the database migrations
+    command: bin/console doctrine:migrations:migrate --no-interaction
+  test:
+    command: vendor/bin/phpunit
+    container: php
+    env:
+      APP_ENV: test
+hooks:
+  post-sync:
+    - migrate`
+
+const RunCommandExampleDescription = `
+# list the commands of the project
+%[1]s run --list
+
+# run the migrations
+%[1]s run migrate
+
+# run the tests of a single class
+%[1]s run test -- --filter UserTest`
+
+const RunCommandNotFound = `The command '%s' is not defined in the %s file, the commands are: %s.`
+
+const RunNoCommands = `No command is defined in the %s file.`
+
+const ProjectConfigInvalid = `The %s file could not be read, please check that it is valid YAML.`
+
+const PostSyncHookRunning = `Running the post-sync command '%s'`
+
+const PostSyncHookFailed = `The post-sync command '%s' failed.`
+
 const FetchCommandShortDescription = `Transfers file changes from the remote environment to the local filesystem.`
 
-const FetchCommandLongDescription = `When the remote environment is rebuilt it may contain changes that you do not have on the local filesystem. For example, for a PHP project part of building the remote environment could be installing the vendors using composer. Any new or updated vendors would be on the remote environment but not on the local filesystem which would cause issues, such as autocomplete in your IDE not working correctly. The fetch command will copy changes from the remote to the local filesystem. This will resync with the default container specified during setup but you can specify another container.`
+const FetchCommandLongDescription = `When the remote environment is rebuilt it may contain changes that you do not have on the local filesystem. For example, for a PHP project part of building the remote environment could be installing the vendors using composer. Any new or updated vendors would be on the remote environment but not on the local filesystem which would cause issues, such as autocomplete in your IDE not working correctly. The fetch command will copy changes from the remote to the local filesystem. This will resync with the default container specified during setup but you can specify another container. Paths of the project, which can contain the * and ? wildcards, can be given to fetch only some files and directories, the --since flag fetches only the files modified remotely after the given time and the --delete flag removes the local files that no longer exist remotely.`
 
 const FetchCommandExampleDescription = `
 # fetch files and folders from the remote pod
 %[1]s fetch
 # fetch files and folders from the remote pod specifying the environment and pod
 %[1]s fetch -e techup-dev-user -s web
+# fetch the vendor directory and the lock files
+%[1]s fetch vendor '*.lock'
+# fetch the files modified remotely in the last two hours
+%[1]s fetch --since 2h
+# fetch the src directory and delete the local files that were removed remotely
+%[1]s fetch --delete src
 `
 
 const PushCmdExampleDescription = `
@@ -83,6 +258,12 @@ const PushCmdExampleDescription = `
 
 # push files and folders to the remote pod specifying the environment and pod
 %[1]s %[2]s -e techup-dev-user -s web
+
+# push only the files changed since the commit deployed in the remote environment
+%[1]s %[2]s --git-changed
+
+# push without running the post-sync commands of the .cp-remote.yml file
+%[1]s %[2]s --no-hooks
 `
 
 const SyncCommandShortDescription = `Sync local changes to the remote filesystem (alias for push).`
@@ -90,6 +271,14 @@ const SyncCommandShortDescription = `Sync local changes to the remote filesystem
 const SyncCommandLongDescription = `The sync command will copy changes from the local filesystem to the remote environment.
 Note: this will delete any files/folders in the remote environment that are not present locally.`
 
+const PushGitModeConflict = `The --git-tracked and --git-changed flags can't be used together, with the --file flag or with the --delete flag.`
+
+const PushParallelConflict = `The --parallel flag only splits a push of the whole project, it can't be used with the --git-tracked, --git-changed and --file flags or with the hash-cache change detection without the --delete flag.`
+
+const PushGitReferenceNotFound = `The commit deployed in the remote environment and the remote branch of the environment are not in the local repository, please fetch the remote branch with 'git fetch' and try again.`
+
+const PushGitNoFiles = `There are no files to push.`
+
 const PushCommandShortDescription = `Push local changes to the remote filesystem.`
 
 const PushCommandLongDescription = `The push command will copy changes from the local filesystem to the remote environment.
@@ -99,6 +288,104 @@ const WatchCommandShortDescription = `Watch local changes and synchronize with t
 
 const WatchCommandLongDescription = `The watch command will sync changes from the local filesystem to the remote environment. The default container (specified during setup) will be used but you can specify another container to sync with using the -s flag.`
 
+const IgnoreCommandShortDescription = `Explain which files are excluded from push, fetch and watch.`
+
+const IgnoreCommandLongDescription = `The ignore command shows how the exclusion rules of the .cp-remote-ignore and .cp-remote-ignore-fetch files, and of the .cp-remote-ignore files nested in the project directories, apply to the local files. For each path it prints whether it is pushed, fetched and watched, and the rule and the file that decided it.`
+
+const IgnoreCheckCommandShortDescription = `Explain why the given paths are or are not synchronized.`
+
+const IgnoreCheckPathsEmpty = `Please specify at least one path to check.`
+
+const IgnoreLsCommandShortDescription = `List the files of the project with their synchronization status.`
+
+const IgnoreCommandExampleDescription = `
+# explain why a file is not pushed to the remote pod
+%[1]s ignore check var/cache/dev/appDevDebugProjectContainer.php
+
+# list the status of the files in the src directory
+%[1]s ignore ls src
+
+# exclude the cache and the logs of a Symfony project
+%[1]s ignore preset add symfony
+`
+
+const IgnorePresetCommandShortDescription = `Manage the framework exclusion presets.`
+
+const IgnorePresetAddCommandShortDescription = `Add the exclusions of a framework preset to the .cp-remote-ignore file (%s).`
+
+const IgnorePresetNameEmpty = `Please specify the name of the preset to add, the available presets are: %s.`
+
+const IgnorePresetNotFound = `The preset '%s' does not exist, the available presets are: %s.`
+
+const DiffCommandShortDescription = `Show the differences between the local files and the files of the remote container.`
+
+const DiffCommandLongDescription = `The diff command compares the local project files with the ones of the remote container, using the exclusion rules of the .cp-remote-ignore files. It lists the files that exist only locally, the files that exist only in the container and the modified files, followed by the unified diff of the modified text files. The diff shows the changes that a push would make to the remote files. Paths can be given to compare only some files and directories of the project.`
+
+const DiffCommandExampleDescription = `
+# show the differences between the local project and the remote pod
+%[1]s diff
+
+# list the files of the src directory that differ without showing their content
+%[1]s diff --name-only src
+`
+
+const PathOutsideProject = `The path '%s' is not inside the project directory, please specify a path of the project.`
+
+const DiffNoDifferences = `The local files and the remote files are the same.`
+
+const FilesCommandShortDescription = `List, print, copy and remove files of a container.`
+
+const FilesCommandLongDescription = `The files command works with the files of the container of the configured service, the pod is found in the same way as the exec command. The remote paths can be outside of the project directory, the relative remote paths are in the directory given with the --remote-project-path flag. The remote paths can contain the * and ? wildcards. When copying, the remote paths start with a colon (:).`
+
+const FilesCommandExampleDescription = `
+# list the files of the project directory of the remote pod
+%[1]s files ls
+
+# print the remote log files
+%[1]s files cat '/var/log/app/*.log'
+
+# copy a generated file from the remote pod in the current directory
+%[1]s files cp :/tmp/report.csv .
+
+# copy the fixtures directory in the remote project directory
+%[1]s files cp -r tests/fixtures :tests/
+
+# remove the remote cache without asking for a confirmation
+%[1]s files rm -r -y var/cache
+`
+
+const FilesLsCommandShortDescription = `List the remote files.`
+
+const FilesCatCommandShortDescription = `Print the content of the remote files.`
+
+const FilesCpCommandShortDescription = `Copy files from or to the container, the remote paths start with a colon (:).`
+
+const FilesRmCommandShortDescription = `Remove the remote files.`
+
+const FilesPathsEmpty = `Please specify at least one remote path.`
+
+const FilesCopyArgumentsInvalid = `Please specify one or more sources and a destination, either all the sources or the destination need to be remote paths starting with a colon (:).`
+
+const FilesNoMatch = `No local file matches '%s'.`
+
+const RestoreCommandShortDescription = `Restore the remote files backed up before a push with --delete.`
+
+const RestoreCommandLongDescription = `The restore command puts back in the container the remote files that a push or a watch with the --delete flag has deleted or overwritten. The backups are stored in the pod, in the /tmp/cp-remote-backups directory, or on this machine in the ~/.cp-remote/backups/<environment>/<service> directory when the backup setting or the --backup flag is set to local. The backups stored in the pod are lost when the pod is deleted. A watch only keeps the 20 most recent backups. Run the command without a backup to list the available backups.`
+
+const RestoreCommandExampleDescription = `
+# list the backups of the remote pod and of this machine
+%[1]s restore
+
+# restore the files of a backup
+%[1]s restore 20170612-153012
+`
+
+const RestoreNoBackups = `There are no backups locally or in the pod %s.`
+
+const RestoreBackupNotFound = `The backup '%s' does not exist locally or in the pod %s, run '%s restore' to list the available backups.`
+
+const RestoreCompleted = `The files of the backup %s have been restored in %s.`
+
 const PortForwardCommandShortDescription = `Forward a port to a container`
 
 const PortForwardCommandLongDescription = `The forward command will set up port forwarding from the local environment
@@ -175,26 +462,73 @@ kubectl delete pods,services -l name=myLabel`
 
 const LogsCommandShortDescription = `Print the logs for a pod`
 
-const LogsCommandLongDescription = `Print the logs for a pod`
+const LogsCommandLongDescription = `Print the logs for a pod
+
+The logs of several services, of all the pods of a service (--all-pods), of the pods matching a label selector (--selector)
+or of all the pods of the environment (--all) are merged, the services are given with --service or as arguments. Each line
+is then prefixed with the service, the pod and, when the pod has several containers, the container it comes from and with
+its timestamp. The followed logs of a service are the ones of all its pods, the logs of the pods replacing the pods that
+stop are printed as well and the lines are only prefixed while several containers are streamed.
+
+The lines in JSON, like the ones of Monolog, are printed as text: their time, level, channel and message followed by their
+other fields. The lines can be filtered by level with --level and with a regular expression with --grep, a copy of them
+can be saved in a file rotated when it gets too big with --save.`
 
 const LogsCommandExampleDescription = `
 # Return snapshot logs from the pod that matches the default service
 %[1]s logs
 
-# Return snapshot logs from pod mysql with only one container
+# Return snapshot logs from the pod of the mysql service
 %[1]s logs mysql
 
-# Return snapshot of previous terminated ruby container logs from pod mysql
+# Return snapshot logs of the previous terminated container of the pod of the mysql service
 %[1]s logs -p mysql
 
-# Begin streaming the logs of the ruby container in pod mysql
+# Begin streaming the logs of the pod of the mysql service
 %[1]s logs -f mysql
 
-# Display only the most recent 20 lines of output in pod mysql
+# Display only the most recent 20 lines of output of the pod of the mysql service
 %[1]s logs --tail=20 mysql
 
-# Show all logs from pod mysql written in the last hour
-%[1]s logs --since=1h mysql`
+# Show all logs of the pod of the mysql service written in the last hour
+%[1]s logs --since=1h mysql
+
+# Follow the merged logs of the web and worker services
+%[1]s logs -f -s web,worker
+
+# Show the logs of the nginx container of all the pods of the environment written in the last 10 minutes
+%[1]s logs --all --container nginx --since=10m
+
+# Show the errors of the web service since 10 o'clock about the login
+%[1]s logs --level error --since-time 2017-06-01T10:00:00Z --grep login
+
+# Show only the message and the user of the context of the JSON lines
+%[1]s logs --fields message,context.user
+
+# Follow the logs all day and keep a copy of them in web.log, rotated every 50 megabytes
+%[1]s logs -f --save web.log --save-max-size 50`
+
+const LogsAggregationConflict = `The --pod, --replica-index and --selector flags can't be used with several services or with the --all flag.`
+
+const LogsNoRunningPods = `No running pods were found in the environment '%s'.`
+
+const LogsContainerNotFound = `No running pods with the container '%s' were found for the services '%s'.`
+
+const LogsStreamFailed = `The logs of %s could not be read: %s`
+
+const LogsStreamsFailed = `The logs of %d of the %d containers could not be read.`
+
+const LogsSinceConflict = `Only one of --since and --since-time can be used.`
+
+const LogsSinceTimeInvalid = `The date '%s' of --since-time is not valid, it has to be in the RFC3339 format (e.g.: 2017-06-01T10:00:00Z).`
+
+const LogsGrepInvalid = `The regular expression '%s' of --grep is not valid: %s`
+
+const LogsLevelInvalid = `The level '%s' is not valid, the levels are: %s.`
+
+const LogsSaveRotationInvalid = `The --save-max-size flag has to be greater than 0 and the --save-max-files flag can't be negative.`
+
+const LogsSaveFailed = `The logs could not be saved in the file %s: %s`
 
 const CheckConnectionCommandShortDescription = `Check the connection to the remote environment`
 
@@ -321,6 +655,14 @@ This issue is usually caused by a temporary unavailability of the cluster, a net
 Check the pod status with 'cp-remote pods' and reconnect once the pod is running again.
 If the issue persists please contact support specifying the session number '%s'.`
 
+const SuggestionRunFailed = `Something went wrong when reading the commands of the project.
+Please check that the .cp-remote.yml file of the project is valid YAML.
+If the issue persists please contact support specifying the session number '%s'.`
+
+const SuggestionRecordFailed = `Something went wrong when creating the recording file %s.
+Please check that its directory exists and can be written.
+If the issue persists please contact support specifying the session number '%s'.`
+
 const SuggestionFetchFailed = `Something went wrong during the fetch command execution.
 This issue is usually caused by a temporary unavailability of the cluster, a network issue or because the pod was deleted or moved to a different node.
 Check the pod status with 'cp-remote pods' and re-try once the pod is running again.
@@ -331,6 +673,27 @@ This issue is usually caused by a temporary unavailability of the cluster, a net
 Check the pod status with 'cp-remote pods' and re-try once the pod is running again.
 If the issue persists please contact support specifying the session number '%s'.`
 
+const SuggestionPushGitFilesFailed = `Something went wrong when listing the files to push with git.
+Please check that the project is a git repository and that git is in the PATH.
+If the issue persists please contact support specifying the session number '%s'.`
+
+const SuggestionDiffFailed = `Something went wrong during the diff command execution.
+This issue is usually caused by a temporary unavailability of the cluster, a network issue or because the pod was deleted or moved to a different node.
+Check the pod status with 'cp-remote pods' and re-try once the pod is running again.
+If the issue persists please contact support specifying the session number '%s'.`
+
+const SuggestionFilesFailed = `Something went wrong when working with the remote files.
+Please check that the remote paths exist and that the pod is running with 'cp-remote pods'.
+If the issue persists please contact support specifying the session number '%s'.`
+
+const SuggestionRestoreFailed = `Something went wrong when restoring the backup.
+Please check that the pod is running with 'cp-remote pods' and that the backup exists with 'cp-remote restore'.
+If the issue persists please contact support specifying the session number '%s'.`
+
+const SuggestionIgnoreRulesFailed = `Something went wrong when reading the exclusion rules of the '.cp-remote-ignore' files.
+Please ensure that the ignore files of the project can be read.
+If the issue persists please contact support specifying the session number '%s'.`
+
 const SuggestionDirectoryMonitorFailed = `Something went wrong during the watch command execution.
 This issue is usually caused by a temporary unavailability of the cluster, a network issue or because the pod was deleted or moved to a different node.
 Check the pod status with 'cp-remote pods' and reconnect once the pod is running again.
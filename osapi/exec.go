@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os/exec"
 	"strings"
 
@@ -20,6 +21,16 @@ type SCommand struct {
 
 //Executes a command and waits for it to finish
 func CommandExec(scmd SCommand, arg ...string) (string, error) {
+	out, err := CommandOutput(scmd, arg...)
+	if err != nil {
+		return "", err
+	}
+	//remove newline and space from string
+	return strings.Trim(out, "\n "), nil
+}
+
+//Executes a command, waits for it to finish and returns its output as it is written
+func CommandOutput(scmd SCommand, arg ...string) (string, error) {
 	cmd := exec.Command(scmd.Name, arg...)
 	cmd.Stdin = scmd.Stdin
 	cmd.Stderr = scmd.Stderr
@@ -35,11 +46,10 @@ func CommandExec(scmd SCommand, arg ...string) (string, error) {
 	sout := string(out[:])
 	cplogs.V(7).Infof("command output as string: %s", sout)
 	cplogs.Flush()
-	//remove newline and space from string
-	return strings.Trim(sout, "\n "), nil
+	return sout, nil
 }
 
-//execute the command and write output into log file
+//execute the command, write output into log file and wait for it to finish
 func CommandExecL(scmd SCommand, arg ...string) error {
 	cmd := exec.Command(scmd.Name, arg...)
 	cmd.Stdin = scmd.Stdin
@@ -65,7 +75,13 @@ func CommandExecL(scmd SCommand, arg ...string) error {
 			fmt.Fprintln(scmd.Stdout, line)
 		}
 	}
-	return nil
+	if err := scanner.Err(); err != nil {
+		cplogs.V(4).Infof("error when reading the command output: %s", err.Error())
+		cplogs.Flush()
+		//the command would block writing its output if the pipe is not read until the end
+		io.Copy(ioutil.Discard, stdout)
+	}
+	return cmd.Wait()
 }
 
 //Start a process and waits for it to finish
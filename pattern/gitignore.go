@@ -0,0 +1,131 @@
+package pattern
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//GitIgnoreFile is the ignore file of git, the per-directory merge files with this name are read with the git semantics
+const GitIgnoreFile = ".gitignore"
+
+//GitIgnoreRules converts the lines of a .gitignore file into the filter rules in effect in its directory. The last
+//line of a .gitignore matching a path decides whether it is ignored while rsync uses the first matching rule, so the
+//lines are reversed, a negated pattern "!x" becomes the include rule "+ x" and a pattern containing a slash is
+//anchored to the directory of the file
+func GitIgnoreRules(lines ...string) []string {
+	rules, _ := gitIgnoreRules(lines)
+	return rules
+}
+
+//gitIgnoreRules returns the rules of the lines and the line each rule comes from
+func gitIgnoreRules(lines []string) (rules []string, sources []string) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSuffix(lines[i], "\r")
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		source := line
+		prefix := "- "
+		if line[0] == '!' {
+			prefix = "+ "
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+		if line == "" {
+			continue
+		}
+		if strings.Contains(strings.TrimSuffix(line, "/"), "/") && !strings.HasPrefix(line, "/") {
+			line = "/" + line
+		}
+		rules = append(rules, prefix+line)
+		sources = append(sources, source)
+	}
+	return rules, sources
+}
+
+//GitIgnoreFilterRules returns the rules of all the .gitignore files found in the root directory and its
+//sub-directories, for the rsync filter option. The rules of a directory are anchored to it and come before the rules of
+//its parent directories, as the patterns of the nested .gitignore files take precedence in git
+func GitIgnoreFilterRules(root string) ([]string, error) {
+	return DirMergeFilterRules(root, GitIgnoreFile, GitIgnoreRules)
+}
+
+//DirMergeFilterRules returns the rules that rsync would merge from the files with the name found in the root directory
+//and its sub-directories, rules converts the lines of a file. The rules of a directory are anchored to it and come
+//before the rules of its parent directories, as a per-directory merge file does
+func DirMergeFilterRules(root string, name string, rules func(lines ...string) []string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == name {
+			dir, err := filepath.Rel(root, filepath.Dir(file))
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, filepath.ToSlash(dir))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byDepth(dirs))
+
+	var res []string
+	for _, dir := range dirs {
+		lines, err := readMergeFile(filepath.Join(root, filepath.FromSlash(dir), name))
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules(lines...) {
+			for _, pattern := range anchorToDir(rule[2:], dir) {
+				res = append(res, rule[:2]+pattern)
+			}
+		}
+	}
+	return res, nil
+}
+
+//anchorToDir returns the patterns of the root directory matching the paths that the pattern of a .gitignore of the
+//directory matches, a pattern without slash matches in the directory and in its sub-directories
+func anchorToDir(pattern string, dir string) []string {
+	if dir == "." || dir == "" {
+		return []string{pattern}
+	}
+	if strings.HasPrefix(pattern, "/") {
+		return []string{"/" + dir + pattern}
+	}
+	return []string{"/" + dir + "/" + pattern, "/" + dir + "/**/" + pattern}
+}
+
+//byDepth sorts the directories from the deepest to the root
+type byDepth []string
+
+func (s byDepth) Len() int      { return len(s) }
+func (s byDepth) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byDepth) Less(i, j int) bool {
+	di, dj := depth(s[i]), depth(s[j])
+	if di != dj {
+		return di > dj
+	}
+	return s[i] < s[j]
+}
+
+func depth(dir string) int {
+	if dir == "." || dir == "" {
+		return 0
+	}
+	return strings.Count(path.Clean(dir), "/") + 1
+}
@@ -0,0 +1,75 @@
+package pattern
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitIgnoreRules(t *testing.T) {
+	rules := GitIgnoreRules("# logs", "*.log", "!important.log", "", "config/*.yml", `\!bang`, "build/ ")
+	assert.Equal(t, []string{"- build/", "- !bang", "- /config/*.yml", "+ important.log", "- *.log"}, rules)
+}
+
+func TestGitIgnoreFilterRules(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitignore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		".gitignore":             "*.log\n/vendor\n",
+		"app/.gitignore":         "!debug.log\ncache/\n",
+		"app/modules/.gitignore": "/generated\n",
+		".git/info/.gitignore":   "ignored\n",
+	}
+	for file, content := range files {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filepath.Join(root, file)), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(root, file), []byte(content), 0644))
+	}
+
+	rules, err := GitIgnoreFilterRules(root)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"- /app/modules/generated",
+		"- /app/cache/",
+		"- /app/**/cache/",
+		"+ /app/debug.log",
+		"+ /app/**/debug.log",
+		"- /vendor",
+		"- *.log",
+	}, rules, "the rules of the nested directories come first")
+
+	//the rules give the same decisions as the per-directory merge of the .gitignore files
+	matcher := NewRsyncMatcherPath()
+	matcher.AddPattern(rules...)
+	for path, included := range map[string]bool{"app/debug.log": true, "app/src/debug.log": true, "debug.log": false, "app/src/cache/file.php": false} {
+		match, _, err := matcher.HasMatchAndIsIncluded(path)
+		assert.Nil(t, err)
+		assert.Equal(t, included, match, path)
+	}
+}
+
+func TestDirMergeFilterRules(t *testing.T) {
+	root, err := ioutil.TempDir("", "dirmerge")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		".cp-remote-ignore":     "# comment\n*.tmp\n",
+		"web/.cp-remote-ignore": "/uploads\nassets/*.map\n",
+	}
+	for file, content := range files {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filepath.Join(root, file)), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(root, file), []byte(content), 0644))
+	}
+
+	rules, err := DirMergeFilterRules(root, ".cp-remote-ignore", ExcludeRules)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"- /web/uploads",
+		"- /web/assets/*.map",
+		"- /web/**/assets/*.map",
+		"- *.tmp",
+	}, rules)
+}
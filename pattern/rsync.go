@@ -1,10 +1,13 @@
-// Package pattern implements some of the rsync filter rules and include/exclude pattern rules
+// Package pattern implements the rsync filter rules and include/exclude pattern rules
 package pattern
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/continuouspipe/remote-environment-client/cplogs"
@@ -13,12 +16,29 @@ import (
 const (
 	filterRuleInclude = iota
 	filterRuleExclude
+	filterRuleDirMerge
+	filterRuleMerge
+	filterRuleClear
+)
+
+//sides of the transfer a rule can apply to
+const (
+	senderSide = iota
+	receiverSide
 )
 
 type pathPatternItem struct {
 	prefix     rune
 	pattern    string
 	rawPattern string
+	//modifiers is a bit mask of the rule modifiers and of the pattern properties
+	modifiers int
+	//slashCount is the number of slashes in the pattern, not counting the trailing one
+	slashCount int
+	//noPrefixesType is the type of the rules read from a merge file when the merge rule has the '-' or '+' modifier
+	noPrefixesType rune
+	//source is the merge file that contained the rule, empty for the rules added with AddPattern
+	source string
 }
 
 // PathPatternMatcher match a path against a list of patterns.
@@ -27,54 +47,67 @@ type PathPatternMatcher interface {
 	HasMatchAndIsIncluded(path string) (include bool, message string, err error)
 }
 
-// RsyncMatcherPath allows to match a path using some of the rsync filter rules and include/exclude pattern rules
+// RsyncMatcherPath allows to match a path using the rsync filter rules and include/exclude pattern rules
 type RsyncMatcherPath struct {
 	patternItems []pathPatternItem
+	//root is the directory the matched paths and the merge files are relative to, the working directory when empty
+	root string
+	//readLines returns the lines contained in a merge file, the file path is relative to the root
+	readLines func(file string) ([]string, error)
+	//perDirectory caches the rules of a dir-merge rule in effect in a directory
+	perDirectory map[string][]pathPatternItem
 }
 
 // NewRsyncMatcherPath ctor returns a pointer to RsyncMatcherPath
 func NewRsyncMatcherPath() *RsyncMatcherPath {
-	return &RsyncMatcherPath{}
+	m := &RsyncMatcherPath{}
+	m.readLines = func(file string) ([]string, error) {
+		return readMergeFile(filepath.Join(m.root, filepath.FromSlash(file)))
+	}
+	return m
 }
 
-// AddPattern convert a pattern string into a pathPatternItem struct and stores it in an array
+// AddPattern parses the filter rules and stores them in an array replacing the existing ones
+//
+// Each pattern is parsed as a line of a file given to the rsync --filter option, a line that is not a valid filter rule
+// is an exclude pattern as it would be if the file was given to the --exclude-from option
 func (m *RsyncMatcherPath) AddPattern(pattern ...string) {
-	var patternItems []pathPatternItem
-	for _, p := range pattern {
-
-		if len(p) == 0 {
-			continue
-		}
-
-		patternItem := pathPatternItem{}
-		patternItem.rawPattern = p
-		patternItem.pattern = p
-		//default prefix exclude
-		patternItem.prefix = filterRuleExclude
+	m.patternItems, _ = m.parseRules(nil, pattern, ruleContext{})
+	m.perDirectory = map[string][]pathPatternItem{}
+}
 
-		if p[0] == '+' && p[1] == ' ' {
-			patternItem.prefix = filterRuleInclude
-			patternItem.pattern = p[2:]
-		}
-		if p[0] == '-' && p[1] == ' ' {
-			patternItem.pattern = p[2:]
-		}
-		patternItems = append(patternItems, patternItem)
-	}
-	m.patternItems = patternItems
+// AddPatternFrom parses the filter rules read from the file source and appends them to the existing ones, the rules
+// are parsed as the ones given to AddPattern and the source is reported in the details of the matches
+func (m *RsyncMatcherPath) AddPatternFrom(source string, pattern ...string) {
+	m.patternItems, _ = m.parseRules(m.patternItems, pattern, ruleContext{source: source})
+	m.perDirectory = map[string][]pathPatternItem{}
 }
 
-// HasMatchAndIsIncluded determins if a
+// HasMatchAndIsIncluded determins if a path is transferred by the sending side of rsync
 //
-// FILTER RULES (sub-set of the all supported rsync filter rules)
+// FILTER RULES
 //
 // - exclude, - specifies an exclude pattern.
 // - include, + specifies an include pattern.
+// - merge, . specifies a merge-file to read for more rules.
+// - dir-merge, : specifies a per-directory merge-file.
+// - hide, H specifies a pattern for hiding files from the transfer.
+// - show, S files that match the pattern are not hidden.
+// - protect, P specifies a pattern for protecting files from deletion.
+// - risk, R files that match the pattern are not protected.
+// - clear, ! clears the current include/exclude list (takes no arg)
+//
+// The rules accept the rsync modifiers (/ ! C n e w s r p x and the - + of the merge rules), e.g. "-! */" or "dir-merge,-n .cp-remote-ignore"
 //
-// INCLUDE/EXCLUDE PATTERN RULES  (sub-set of the all supported rsync include/exclude pattern rules)
+// INCLUDE/EXCLUDE PATTERN RULES
 // - if the pattern starts with a / then it is anchored to a particular spot in the hierarchy of files, otherwise it is matched against the end of the pathname.
-// - a '*' matches any non-empty path component (it stops at slashes)
-// - use '**' to match anything, including slashes
+// - if the pattern ends with a / then it will only match a directory, not a regular file, symlink, or device.
+// - a '*' matches any path component, but it stops at slashes.
+// - use '**' to match anything, including slashes.
+// - a '?' matches any character except a slash (/).
+// - a '[' introduces a character class, such as [a-z] or [[:alpha:]].
+// - in a wildcard pattern, a backslash can be used to escape a wildcard character.
+// - a trailing "dir_name/***" will match both the directory (as if "dir_name/" had been specified) and everything in the directory.
 //
 //
 // Note  that, this is implemented as when rsync uses the --recursive (-r) option (which is implied by -a),
@@ -95,244 +128,279 @@ func (m *RsyncMatcherPath) HasMatchAndIsIncluded(path string) (include bool, det
 		msg := fmt.Sprintf("error: %s", err.Error())
 		return false, msg, err
 	}
-	if len(m.patternItems) == 0 {
-		return true, "", nil
-	}
-
-	inc, found := m.filteredMatch(path)
-	if inc == false && found != nil {
-		msg := fmt.Sprintf("Not transferring %s because of pattern %s", path, found.rawPattern)
-		return false, msg, nil
-	}
-
-	//before saying that we can safely include a path we need to check that none of is parent
-	//has been excluded
-	if inc == true && found != nil {
-		//check if any of the parent folder is excluded
-		parts := strings.Split(path, "/")
-		current := ""
-		for i := 1; i < len(parts)-1; i++ {
-			current = current + "/" + parts[i]
-
-			inc, found := m.filteredMatch(current)
-
-			if inc == false && found != nil {
-				msg := fmt.Sprintf("Not transferring %s because of pattern %s", path, found.rawPattern)
-				return false, msg, nil
-			}
-		}
+	if found := m.excludedBy(path, senderSide); found != nil {
+		return false, ruleMessage("Not transferring", path, found), nil
 	}
 	return true, "", nil
 }
 
-func (m RsyncMatcherPath) filteredMatch(path string) (include bool, found *pathPatternItem) {
-	matchedPatterns := m.match(path)
-	if len(matchedPatterns) > 0 {
-		switch matchedPatterns[0].prefix {
-		case filterRuleExclude:
-			cplogs.V(5).Infof("not transferring %s because of the first pattern found: %s. List of all matches: %#v", path, matchedPatterns[0].rawPattern, matchedPatterns)
-			cplogs.Flush()
-			return false, &matchedPatterns[0]
-		case filterRuleInclude:
-			return true, &matchedPatterns[0]
-		}
+// HasMatchAndIsProtected determins if a path is protected from the deletion on the receiving side of rsync
+//
+// The path is protected when it, or one of its parents, is excluded by a rule that applies to the receiving side, for
+// example a protect (P) rule or an exclude rule without the 's' modifier
+func (m *RsyncMatcherPath) HasMatchAndIsProtected(path string) (protected bool, details string, err error) {
+	if len(path) == 0 {
+		err := errors.New("empty path given")
+		msg := fmt.Sprintf("error: %s", err.Error())
+		return false, msg, err
 	}
-	return true, nil
+	if found := m.excludedBy(path, receiverSide); found != nil {
+		return true, ruleMessage("Not deleting", path, found), nil
+	}
+	return false, "", nil
 }
 
-// Match matches a path against a list of patterns.
-func (m RsyncMatcherPath) match(targetPath string) (matchedPatterns []pathPatternItem) {
-	for _, patternItem := range m.patternItems {
-
-		if strings.HasPrefix(patternItem.pattern, "/") {
-			if res := m.matchedAnchoredPattern(targetPath, patternItem.pattern); res == true {
-				matchedPatterns = append(matchedPatterns, patternItem)
-			}
-		} else if res := m.matchedRelativePattern(targetPath, patternItem.pattern); res == true {
-			matchedPatterns = append(matchedPatterns, patternItem)
-		}
-
-	}
-	return matchedPatterns
+// Decision describes the filter rule that decides if a path is transferred
+type Decision struct {
+	//Included is false when the path, or one of its parents, is excluded from the transfer
+	Included bool
+	//Path is the path, or the parent directory, matched by the rule
+	Path string
+	//Rule is the filter rule as written in the source, empty when no rule matched the path
+	Rule string
+	//Source is the file that contains the rule, empty for the rules added without a source
+	Source string
 }
 
-func (m RsyncMatcherPath) matchedAnchoredPattern(targetPath string, pattern string) (matched bool) {
-	if targetPath == pattern {
-		return true
+// Explain returns the filter rule that decides if a path is transferred by the sending side of rsync
+func (m *RsyncMatcherPath) Explain(path string) (decision Decision, err error) {
+	if len(path) == 0 {
+		return decision, errors.New("empty path given")
 	}
-	if matches := m.sequentialPartMatches(targetPath, pattern, 0); matches {
-		return true
+	include, found, name := m.decide(path, senderSide)
+	decision.Included = include
+	decision.Path = name
+	if found != nil {
+		decision.Rule = found.rawPattern
+		decision.Source = found.source
 	}
-	return false
+	return decision, nil
 }
 
-func (m RsyncMatcherPath) matchedRelativePattern(targetPath string, pattern string) (matched bool) {
-
-	//Iterate recursively through all the target path elements and return true if one of them matches the given pattern
-	targetElems := strings.Split(targetPath, "/")
-	//remove first empty element
-	if len(targetElems) > 0 && targetElems[0] == "" {
-		targetElems = targetElems[1:]
+func ruleMessage(action string, path string, found *pathPatternItem) string {
+	if found.source != "" {
+		return fmt.Sprintf("%s %s because of pattern %s in %s", action, path, found.rawPattern, found.source)
 	}
+	return fmt.Sprintf("%s %s because of pattern %s", action, path, found.rawPattern)
+}
 
-	for _, targetPathElem := range targetElems {
-		if targetPathElem == pattern {
-			return true
-		}
+//excludedBy returns the rule that excludes the path, or the first of its parents from the top down that is excluded,
+//nil when the path is included
+func (m *RsyncMatcherPath) excludedBy(targetPath string, side int) *pathPatternItem {
+	if include, found, _ := m.decide(targetPath, side); include == false {
+		return found
 	}
+	return nil
+}
 
-	patternElems := strings.Split(pattern, "/")
-
-	//remove first empty element
-	if len(patternElems) > 0 && patternElems[0] == "" {
-		patternElems = patternElems[1:]
+//decide returns the rule that decides if the path is transferred and the name, the path or one of its parents, that
+//matched it. When the path is included found is the include rule that matched the path, nil when no rule matched it
+func (m *RsyncMatcherPath) decide(targetPath string, side int) (include bool, found *pathPatternItem, name string) {
+	if len(m.patternItems) == 0 {
+		return true, nil, ""
 	}
-
-	//when there is only 1 pattern element and is not anchored
-	//we need to match it against all patternElems and only return a failure if all of them don't match
-	if len(patternElems) == 1 {
-		return m.singlePartMatches(targetElems, patternElems[0])
+	parts := strings.Split(strings.Trim(targetPath, "/"), "/")
+	last := len(parts) - 1
+	name = strings.Join(parts, "/")
+	include, found = m.filteredMatch(name, strings.Join(parts[:last], "/"), m.isDir(targetPath), side)
+	if include == false {
+		return false, found, name
 	}
 
-	//Find the first matching pattern element and store is key in the offset
-	offset := 0
-	for key, targetElem := range targetElems {
-		if targetElem == patternElems[0] {
-			offset = key
+	//before saying that we can safely include a path we need to check that none of is parent has been excluded
+	for i := 1; i <= last; i++ {
+		parent := strings.Join(parts[:i], "/")
+		if parentInclude, parentFound := m.filteredMatch(parent, strings.Join(parts[:i-1], "/"), true, side); parentInclude == false {
+			return false, parentFound, parent
 		}
 	}
+	return true, found, name
+}
 
-	//Find how many parts match the given pattern taking in consideration the calcualted offset
-	if matches := m.sequentialPartMatches(targetPath, pattern, offset); matches {
-		return true
+//filteredMatch returns the first rule in effect in the directory dir that matches the name
+func (m *RsyncMatcherPath) filteredMatch(name string, dir string, isDir bool, side int) (include bool, found *pathPatternItem) {
+	for _, patternItem := range m.rulesFor(dir) {
+		if !patternItem.appliesTo(side) || !m.ruleMatches(patternItem, name, isDir) {
+			continue
+		}
+		switch patternItem.prefix {
+		case filterRuleExclude:
+			cplogs.V(5).Infof("not transferring %s because of the first pattern found: %s", name, patternItem.rawPattern)
+			cplogs.Flush()
+			return false, &patternItem
+		case filterRuleInclude:
+			return true, &patternItem
+		}
 	}
-
-	return false
+	return true, nil
 }
 
-//singlePartMatches matches a single pattern against a list of targets
-func (m RsyncMatcherPath) singlePartMatches(target []string, pattern string) (matches bool) {
-	if pattern == "*" || pattern == "**" {
-		return true
+//appliesTo returns true if the rule applies to the given side of the transfer
+func (p pathPatternItem) appliesTo(side int) bool {
+	sides := p.modifiers & (modifierSenderSide | modifierReceiverSide)
+	switch side {
+	case senderSide:
+		return sides == 0 || sides&modifierSenderSide != 0
+	case receiverSide:
+		return sides == 0 || sides&modifierReceiverSide != 0
 	}
+	return false
+}
 
-	if strings.ContainsRune(pattern, '*') {
-		return matchAny(pattern, target)
+//rulesFor returns the list of rules in effect in the directory dir, replacing every dir-merge rule with the
+//rules loaded from the per-directory files
+func (m *RsyncMatcherPath) rulesFor(dir string) (items []pathPatternItem) {
+	for _, patternItem := range m.patternItems {
+		if patternItem.prefix != filterRuleDirMerge {
+			items = append(items, patternItem)
+			continue
+		}
+		items = append(items, m.perDirectoryItems(patternItem, dir)...)
 	}
-	return false
+	return items
 }
 
-//sequentialPartMatches iterates until we either reach the end of the pattern or we reach the end of the target string
-func (m RsyncMatcherPath) sequentialPartMatches(target string, pattern string, offset int) (matches bool) {
-	targetElems := strings.Split(target, "/")
-	patternElems := strings.Split(pattern, "/")
+//perDirectoryItems returns the rules of the dir-merge rule in effect in the directory dir. As rsync does, the rules
+//of the file contained in dir come first, followed by the ones inherited from the parent directories, and the
+//anchored patterns are anchored to the directory that contains the merge file
+func (m *RsyncMatcherPath) perDirectoryItems(dirMerge pathPatternItem, dir string) []pathPatternItem {
+	key := dirMerge.rawPattern + "\x00" + dir
+	if items, ok := m.perDirectory[key]; ok {
+		return items
+	}
 
-	//remove first empty element
-	if len(targetElems) > 0 && targetElems[0] == "" {
-		targetElems = targetElems[1:]
+	source := path.Join(dir, dirMerge.pattern)
+	var lines []string
+	if m.readLines != nil {
+		var err error
+		lines, err = m.readLines(source)
+		if err != nil {
+			cplogs.V(4).Infof("error when reading the per-directory merge file %s, details %s", source, err.Error())
+			cplogs.Flush()
+		}
 	}
-	if len(patternElems) > 0 && patternElems[0] == "" {
-		patternElems = patternElems[1:]
+	var items []pathPatternItem
+	cleared := false
+	ctx := ruleContext{template: &dirMerge, dir: dir, perDirectory: true, source: source}
+	if path.Base(dirMerge.pattern) == GitIgnoreFile {
+		//the .gitignore files are read as git does, their negated patterns include the paths. The rules report the
+		//line of the file they come from
+		template := dirMerge
+		template.modifiers &^= modifierNoPrefixes | modifierWordSplit
+		ctx.template = &template
+		rules, sources := gitIgnoreRules(lines)
+		for i, rule := range rules {
+			items, _ = m.parseRules(items, []string{rule}, ctx)
+			items[len(items)-1].rawPattern = sources[i]
+		}
+	} else {
+		items, cleared = m.parseRules(nil, lines, ctx)
 	}
 
-	patternKey := 0
-	targetKey := 0 + offset
-
-	//by default we return that the target matches the pattern
-	matches = true
+	if dir != "" && !cleared && dirMerge.modifiers&modifierNoInherit == 0 {
+		parent := path.Dir(dir)
+		if parent == "." {
+			parent = ""
+		}
+		items = append(items, m.perDirectoryItems(dirMerge, parent)...)
+	}
 
-	for patternKey < len(patternElems) && targetKey < len(targetElems) {
-		patternElem := patternElems[patternKey]
-		targetElem := targetElems[targetKey]
+	if m.perDirectory == nil {
+		m.perDirectory = map[string][]pathPatternItem{}
+	}
+	m.perDirectory[key] = items
+	return items
+}
 
-		//text combined with a double or a single star
-		if patternElem != "*" && patternElem != "**" && strings.Contains(patternElem, "*") {
-			matches, _ := path.Match(patternElem, targetElem)
-			if matches {
-				matches = false
-				break
-			} else {
-			}
-		}
+//ruleMatches returns true if the rule matches the name, the name is relative to the root
+func (m *RsyncMatcherPath) ruleMatches(p pathPatternItem, name string, isDir bool) bool {
+	retMatch := p.modifiers&modifierNegate == 0
+	if name == "" || p.modifiers&modifierXattr != 0 {
+		return false
+	}
 
-		//check if the patternElem doesn't match the targetElem
-		//e.g. target: /user/a, targetElem: a,
-		//     pattern: /user/b, patternEle: b
-		if patternElem != targetElem && patternElem != "*" && patternElem != "**" {
-			matches = false
-			break
-		}
+	text := name
+	prefixed := false
+	if p.slashCount == 0 && p.modifiers&modifierWild2 == 0 {
+		//a pattern without slashes is matched against the final component of the name
+		text = path.Base(name)
+	} else if p.modifiers&modifierAbsPath != 0 {
+		text = m.absRoot() + "/" + name
+		prefixed = true
+	} else if p.modifiers&modifierWild2Prefix != 0 {
+		//"**/" can match the beginning of the name
+		text = "/" + name
+		prefixed = true
+	}
+	if isDir && p.modifiers&modifierWild3Suffix != 0 {
+		//"dir_name/***" matches the directory
+		text += "/"
+		prefixed = true
+	} else if !isDir && p.modifiers&modifierDirectory != 0 {
+		return !retMatch
+	}
 
-		//if patternElem is ** we need to skip to the next targetElement
-		//e.g. target:              /user/a/b/c/d/d/e/f
-		//     targetKey:    -------------^
-		//     pattern:             /user/**/d/e/e/f
-		//     patternKey:   -------------^
-		// targetKey will point to b, c until 'd' is reached which is the next element after **
-		if patternElem == "**" {
-			//iterate to the next pattern part only if the next patternElem is not "*" or "**" and it matches the targetElem
-			nextValidPatternElemKey := 0
-
-			for i := patternKey; i < len(patternElems); i++ {
-				if patternElems[i] != "*" && patternElems[i] != "**" {
-					nextValidPatternElemKey = i
-					break
-				}
-			}
-
-			//if the target path element is the same as the next valid pattern element increment
-			//ex.:
-			// given path /a/b/c/d/e/f/g/h/i
-			// and patternElems /a/b/**/**/h/i
-			//
-			// when patternKey reaches 2, the value would be ** and the next valid pattern key is 'h'
-			if patternElems[nextValidPatternElemKey] == targetElem {
-				patternKey = nextValidPatternElemKey + 1
-			}
-
-		} else {
-			patternKey++
-		}
+	pattern := p.pattern
+	anchored := strings.HasPrefix(pattern, "/")
+	if anchored {
+		pattern = pattern[1:]
+	}
 
-		targetKey++
+	where := 0
+	if !anchored && p.slashCount > 0 && p.modifiers&modifierWild2 == 0 {
+		//match the same number of trailing elements of the name
+		where = p.slashCount + 1
+	} else if !anchored && p.modifiers&modifierWild2 != 0 && p.modifiers&modifierWild2Prefix == 0 {
+		//match the full name or any trailing part of it that starts after a slash
+		where = -1
 	}
 
-	//we reached the end of the target string, and there are still other pattern elements to match
-	//e.g. target: /user/a/b, pattern: /user/a/b/**
-	//or   target: /user/a/b, pattern: /user/a/b/c/d/e/*/g
-	if patternKey < len(patternElems) && targetKey == len(targetElems) {
-		patternElem := patternElems[patternKey]
-
-		//if the current pattern element is a double star symbol
-		//break as it would match anything after
-		if patternElem == "**" {
-			//there are other pattern after '**'
-			if patternKey+1 == len(patternElems) {
-				//no other patterns, return true
-				matches = true
-			} else {
-				//there are other patterns
-				matches = false
-			}
-		} else {
-			//else it means that we had other parts of the pattern that had to be matched
-			//but the target string doesn't contain enough parts
-			matches = false
-		}
+	var matched bool
+	switch {
+	case p.modifiers&modifierWild != 0:
+		matched = wildmatch(pattern, text, where)
+	case prefixed || where > 0:
+		matched = litmatch(pattern, text, where)
+	case anchored:
+		matched = text == pattern
+	default:
+		matched = text == pattern || strings.HasSuffix(text, "/"+pattern)
+	}
+	if matched {
+		return retMatch
+	}
+	return !retMatch
+}
 
+//absRoot returns the absolute path of the root without the leading slash
+func (m *RsyncMatcherPath) absRoot() string {
+	root, err := filepath.Abs(m.root)
+	if err != nil {
+		return ""
 	}
+	return strings.TrimPrefix(filepath.ToSlash(root), "/")
+}
 
-	return
+//isDir returns true if the path, relative to the root, is an existing directory
+func (m *RsyncMatcherPath) isDir(targetPath string) bool {
+	fi, err := os.Stat(filepath.Join(m.root, filepath.FromSlash(strings.TrimPrefix(targetPath, "/"))))
+	return err == nil && fi.IsDir()
 }
 
-func matchAny(pattern string, items []string) (matched bool) {
-	for _, item := range items {
-		matches, _ := path.Match(pattern, item)
+//readMergeFile returns the lines contained in a merge file, a missing file doesn't contain any rule
+func readMergeFile(file string) (lines []string, err error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-		if matches == false {
-			continue
-		}
-		return true
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
 	}
-	return false
+	return lines, scanner.Err()
 }
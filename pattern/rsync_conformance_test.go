@@ -0,0 +1,241 @@
+package pattern
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//the conformance scenarios compare the matcher with the output of rsync --dry-run on a generated tree of files
+var conformanceScenarios = []struct {
+	description string
+	rules       []string
+	//files are added to the generated tree, e.g. the merge files used by the rules
+	files map[string]string
+	//deletion compares the files that rsync --delete removes with the files that are not protected
+	deletion bool
+}{
+	{"basename patterns", []string{"- *.log", "- tmp", "+ keep.log"}, nil, true},
+	{"first matching rule wins", []string{"+ keep.log", "- *.log"}, nil, true},
+	{"anchored patterns", []string{"- /src/cache", "- /vendor/", "+ /a/b/**", "- /a/*"}, nil, true},
+	{"patterns with slashes", []string{"- */*.go", "- a/*/main.c", "- b/cache/", "- logs/a.log"}, nil, true},
+	{"double star", []string{"- **/cache", "- a/**/x1", "- src/**"}, nil, true},
+	{"triple star", []string{"+ /src/", "+ /src/a/***", "- /src/*", "- logs/***"}, nil, true},
+	{"directory only rules", []string{"- tmp/", "- core/", "+ */", "- *.c"}, nil, true},
+	{"include only a type of file", []string{"+ */", "+ *.go", "- *"}, nil, true},
+	{"negated rules", []string{"-! */", "+ */", "- cache"}, nil, true},
+	{"negated wildcard", []string{"+ */", "-! *.[ch]"}, nil, true},
+	{"character classes", []string{"- photo[0-9].jpg", "- [[:upper:]]*", "- [!a-m]1*", "- ?.tmp"}, nil, true},
+	{"escaped wildcard", []string{"- star\\*"}, map[string]string{"star*": "", "stars": ""}, true},
+	{"long rule names", []string{"include keep.log", "exclude *.log", "exclude,! */"}, nil, true},
+	{"hide and show", []string{"S keep.log", "H *.log", "H tmp/"}, nil, true},
+	{"protect and risk", []string{"R keep.log", "P *.log", "P /src/"}, nil, true},
+	{"sender and receiver modifiers", []string{"-s *.log", "-r *.tmp", "+s keep.log", "-r cache"}, nil, true},
+	{"clear", []string{"- *.log", "- src", "!", "- tmp"}, nil, true},
+	{"invalid rules are exclude patterns", []string{"README", "node_modules", "main.go"}, nil, true},
+	{"cvs exclude", []string{"-C"}, map[string]string{"a/x.o": "", "b/x.orig": "", "CVS/Entries": ""}, true},
+	{
+		"merge file",
+		[]string{"merge rules.txt", "- b"},
+		map[string]string{"rules.txt": "# comment\n+ keep.log\n- *.log\n\n. more.txt\n", "more.txt": "- cache\n"},
+		false,
+	},
+	{
+		"merge file with modifiers",
+		[]string{".e-w rules.txt"},
+		map[string]string{"rules.txt": "*.log\ttmp  cache\n"},
+		false,
+	},
+	{
+		"dir-merge",
+		[]string{":- .cp-remote-ignore", "- *.tmp"},
+		map[string]string{
+			".cp-remote-ignore":        "*.log\n+ keep.log\n",
+			"src/.cp-remote-ignore":    "/cache\nx1\n",
+			"a/b/.cp-remote-ignore":    "main.*\n",
+			"vendor/.cp-remote-ignore": "/tmp/\n",
+		},
+		false,
+	},
+	{
+		"dir-merge with rules prefixes",
+		[]string{"dir-merge .rules", "- *.jpg"},
+		map[string]string{
+			".rules":      "+ keep.log\n- *.log\n+ photo1.jpg\n",
+			"src/.rules":  "- keep.log\n- /a\n",
+			"a/.rules":    "!\n- main.go\n",
+			"a/b/.rules":  "+ *.log\n",
+			"logs/.rules": "- /*.log\n",
+		},
+		false,
+	},
+	{
+		"dir-merge not inherited and excluded",
+		[]string{":n-e .rules"},
+		map[string]string{
+			".rules":     "*.log\n",
+			"src/.rules": "cache\n",
+			"a/.rules":   "b/x1\n",
+		},
+		false,
+	},
+}
+
+//conformanceNames are the names used to generate the tree of files
+var conformanceNames = []string{
+	"a", "b", "src", "cache", "tmp", "logs", "vendor", "core", "x1", "y2", "Zeta",
+	"main.go", "main.c", "main.h", "a.log", "keep.log", "b.tmp", "photo1.jpg", "photoA.jpg", "README", "node_modules",
+}
+
+func TestRsyncPathPattern_Conformance(t *testing.T) {
+	rsync, err := exec.LookPath("rsync")
+	if err != nil {
+		t.Skip("rsync is not available")
+	}
+
+	for _, scenario := range conformanceScenarios {
+		src := generateConformanceTree(t, scenario.files)
+		defer os.RemoveAll(src)
+		dst, err := ioutil.TempDir("", "rsync-conformance-dst")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dst)
+
+		filterArgs := conformanceFilterArgs(scenario.rules)
+
+		//compare the files transferred to an empty directory with the files included on the sending side
+		out := runConformanceRsync(t, rsync, append([]string{"-r", "-n", "--out-format=%n"}, filterArgs...), src, dst)
+		transferred := map[string]bool{}
+		for _, line := range out {
+			transferred[strings.TrimSuffix(line, "/")] = true
+		}
+
+		subject := NewRsyncMatcherPath()
+		subject.root = src
+		subject.AddPattern(scenario.rules...)
+		for _, file := range listConformanceTree(t, src) {
+			include, msg, err := subject.HasMatchAndIsIncluded(file)
+			assert.Nil(t, err)
+			assert.Equal(t, transferred[file], include, "%s: %s is transferred by rsync %v: %s", scenario.description, file, transferred[file], msg)
+		}
+
+		if !scenario.deletion {
+			continue
+		}
+
+		//compare the files deleted from the destination by a transfer of an empty directory with the files not protected
+		empty, err := ioutil.TempDir("", "rsync-conformance-empty")
+		assert.Nil(t, err)
+		defer os.RemoveAll(empty)
+		out = runConformanceRsync(t, rsync, append([]string{"-r", "-n", "--delete", "--info=del"}, filterArgs...), empty, src)
+		deleted := map[string]bool{}
+		for _, line := range out {
+			if strings.HasPrefix(line, "deleting ") {
+				deleted[strings.TrimSuffix(strings.TrimPrefix(line, "deleting "), "/")] = true
+			}
+		}
+
+		files := listConformanceTree(t, src)
+		protected := map[string]bool{}
+		for _, file := range files {
+			isProtected, _, err := subject.HasMatchAndIsProtected(file)
+			assert.Nil(t, err)
+			if isProtected {
+				//a directory containing a protected file can't be deleted
+				for dir := file; dir != "."; dir = filepath.ToSlash(filepath.Dir(dir)) {
+					protected[dir] = true
+				}
+			}
+		}
+		for _, file := range files {
+			assert.Equal(t, deleted[file], !protected[file], "%s: %s is deleted by rsync %v", scenario.description, file, deleted[file])
+		}
+	}
+}
+
+//generateConformanceTree creates a tree of files using always the same random sequence, and adds the given files
+func generateConformanceTree(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "rsync-conformance-src")
+	assert.Nil(t, err)
+
+	r := rand.New(rand.NewSource(42))
+	var generate func(dir string, depth int)
+	generate = func(dir string, depth int) {
+		for _, name := range conformanceNames {
+			if r.Intn(3) != 0 {
+				continue
+			}
+			target := filepath.Join(dir, name)
+			if depth < 3 && r.Intn(2) == 0 {
+				assert.Nil(t, os.Mkdir(target, 0755))
+				generate(target, depth+1)
+				continue
+			}
+			assert.Nil(t, ioutil.WriteFile(target, []byte(name), 0644))
+		}
+	}
+	generate(root, 0)
+
+	for file, content := range files {
+		target := filepath.Join(root, filepath.FromSlash(file))
+		//replace the generated files and directories that are in the way
+		for dir := filepath.Dir(target); dir != root; dir = filepath.Dir(dir) {
+			if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+				assert.Nil(t, os.Remove(dir))
+			}
+		}
+		if fi, err := os.Stat(target); err == nil && fi.IsDir() {
+			assert.Nil(t, os.RemoveAll(target))
+		}
+		assert.Nil(t, os.MkdirAll(filepath.Dir(target), 0755))
+		assert.Nil(t, ioutil.WriteFile(target, []byte(content), 0644))
+	}
+	return root
+}
+
+//listConformanceTree returns the slash separated path of every file and directory in the tree
+func listConformanceTree(t *testing.T, root string) (files []string) {
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	assert.Nil(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func conformanceFilterArgs(rules []string) (args []string) {
+	for _, rule := range FilterRules(rules...) {
+		args = append(args, "--filter="+rule)
+	}
+	return args
+}
+
+func runConformanceRsync(t *testing.T, rsync string, args []string, src string, dst string) (lines []string) {
+	cmd := exec.Command(rsync, append(args, src+"/", dst+"/")...)
+	cmd.Dir = src
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	assert.Nil(t, err, "rsync %v: %s", args, stderr.String())
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" && line != "./" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -3,6 +3,9 @@ package pattern
 import (
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -839,3 +842,462 @@ func TestRsyncPathPattern_Match(t *testing.T) {
 		}
 	}
 }
+
+func TestRsyncPathPattern_DirMerge(t *testing.T) {
+	mergeFiles := map[string][]string{
+		".cp-remote-ignore":             {"*.log", "+ keep.log"},
+		"app/.cp-remote-ignore":         {"/cache", "*.tmp"},
+		"app/modules/.cp-remote-ignore": {"generated"},
+		"app/.gitignore":                {"/vendor", "*.log", "!important.log", "config/*.yml", "!config/parameters.yml.dist"},
+		"app/modules/.gitignore":        {"!debug.log"},
+	}
+
+	scenarios := []struct {
+		path        string
+		patterns    []string
+		description string
+		msg         string
+		toTransfer  bool
+	}{
+		{
+			"app/cache/file.php",
+			[]string{":- .cp-remote-ignore"},
+			"anchored pattern of a nested file is anchored to its directory",
+			"Not transferring app/cache/file.php because of pattern /cache in app/.cp-remote-ignore",
+			false,
+		},
+		{
+			"cache/file.php",
+			[]string{":- .cp-remote-ignore"},
+			"anchored pattern of a nested file doesn't apply outside its directory",
+			"",
+			true,
+		},
+		{
+			"app/modules/a/b.tmp",
+			[]string{":- .cp-remote-ignore"},
+			"patterns are inherited from the parent directories",
+			"Not transferring app/modules/a/b.tmp because of pattern *.tmp in app/.cp-remote-ignore",
+			false,
+		},
+		{
+			"app/modules/generated/c.php",
+			[]string{":- .cp-remote-ignore"},
+			"excluded parent directory of a deeper nested file",
+			"Not transferring app/modules/generated/c.php because of pattern generated in app/modules/.cp-remote-ignore",
+			false,
+		},
+		{
+			"app/keep.log",
+			[]string{":- .cp-remote-ignore"},
+			"the exclude-only modifier doesn't parse the include prefix",
+			"Not transferring app/keep.log because of pattern *.log in .cp-remote-ignore",
+			false,
+		},
+		{
+			"app/keep.log",
+			[]string{"+ keep.log", ":- .cp-remote-ignore"},
+			"rules preceding the dir-merge take precedence",
+			"",
+			true,
+		},
+		{
+			"app/vendor/autoload.php",
+			[]string{":- .cp-remote-ignore"},
+			"files not merged are not used",
+			"",
+			true,
+		},
+		{
+			"app/vendor/autoload.php",
+			[]string{":- .cp-remote-ignore", "dir-merge,- .gitignore"},
+			"multiple dir-merge rules",
+			"Not transferring app/vendor/autoload.php because of pattern /vendor in app/.gitignore",
+			false,
+		},
+		{
+			"app/var/important.log",
+			[]string{"dir-merge,- .gitignore"},
+			"a negated pattern of a .gitignore includes the paths excluded by the previous lines",
+			"",
+			true,
+		},
+		{
+			"app/var/debug.log",
+			[]string{"dir-merge,- .gitignore"},
+			"the other paths stay excluded",
+			"Not transferring app/var/debug.log because of pattern *.log in app/.gitignore",
+			false,
+		},
+		{
+			"app/modules/debug.log",
+			[]string{"dir-merge,- .gitignore"},
+			"a nested .gitignore includes the paths excluded by the parent .gitignore",
+			"",
+			true,
+		},
+		{
+			"app/config/parameters.yml",
+			[]string{"dir-merge,- .gitignore"},
+			"the patterns with a slash are anchored to the directory of the .gitignore",
+			"Not transferring app/config/parameters.yml because of pattern config/*.yml in app/.gitignore",
+			false,
+		},
+		{
+			"app/modules/config/parameters.yml",
+			[]string{"dir-merge,- .gitignore"},
+			"the anchored patterns don't match in the sub-directories",
+			"",
+			true,
+		},
+	}
+
+	subject := NewRsyncMatcherPath()
+	subject.readLines = func(file string) ([]string, error) {
+		return mergeFiles[file], nil
+	}
+
+	for _, scenario := range scenarios {
+		subject.AddPattern(scenario.patterns...)
+		match, msg, err := subject.HasMatchAndIsIncluded(scenario.path)
+		assert.Nil(t, err, scenario.description)
+		assert.Equal(t, scenario.toTransfer, match, scenario.description)
+		assert.Equal(t, scenario.msg, msg, scenario.description)
+	}
+}
+
+func TestRsyncPathPattern_FilterRules(t *testing.T) {
+	mergeFiles := map[string][]string{
+		"rules.txt":  {"# comment", "- *.bak", "merge nested.txt"},
+		"nested.txt": {"- *.swp"},
+		"words.txt":  {"*.o *.a", "core"},
+	}
+
+	scenarios := []struct {
+		path        string
+		patterns    []string
+		description string
+		msg         string
+		toTransfer  bool
+	}{
+		{
+			"src/main.o",
+			[]string{"exclude *.o"},
+			"long form of the exclude rule",
+			"Not transferring src/main.o because of pattern exclude *.o",
+			false,
+		},
+		{
+			"main.go",
+			[]string{"-! *.go"},
+			"negated rule doesn't match",
+			"",
+			true,
+		},
+		{
+			"main.c",
+			[]string{"-! *.go"},
+			"negated rule matches the paths that don't match the pattern",
+			"Not transferring main.c because of pattern -! *.go",
+			false,
+		},
+		{
+			"src/main.go",
+			[]string{"+ */", "+ *.go", "- *"},
+			"include the directories and a type of file only",
+			"",
+			true,
+		},
+		{
+			"src/main.c",
+			[]string{"-! */"},
+			"negated directory rule excludes the files",
+			"Not transferring src/main.c because of pattern -! */",
+			false,
+		},
+		{
+			"build",
+			[]string{"- build/"},
+			"pattern with a trailing slash matches a directory",
+			"Not transferring build because of pattern - build/",
+			false,
+		},
+		{
+			"src/build",
+			[]string{"- build/"},
+			"pattern with a trailing slash doesn't match a file",
+			"",
+			true,
+		},
+		{
+			"logs/app.log",
+			[]string{"H *.log"},
+			"hide rule excludes on the sending side",
+			"Not transferring logs/app.log because of pattern H *.log",
+			false,
+		},
+		{
+			"logs/app.log",
+			[]string{"S app.log", "H *.log"},
+			"show rule includes on the sending side",
+			"",
+			true,
+		},
+		{
+			"logs/app.log",
+			[]string{"P *.log"},
+			"protect rule doesn't affect the sending side",
+			"",
+			true,
+		},
+		{
+			"logs/app.log",
+			[]string{"- *.log", "!"},
+			"clear rule removes the previous rules",
+			"",
+			true,
+		},
+		{
+			"logs/app.log",
+			[]string{"- *.log", "!", "- app.*"},
+			"rules after the clear rule are kept",
+			"Not transferring logs/app.log because of pattern - app.*",
+			false,
+		},
+		{
+			"img/photo1.jpg",
+			[]string{"- photo[0-9].jpg"},
+			"character range",
+			"Not transferring img/photo1.jpg because of pattern - photo[0-9].jpg",
+			false,
+		},
+		{
+			"img/photoA.jpg",
+			[]string{"- photo[[:digit:]].jpg"},
+			"character class doesn't match",
+			"",
+			true,
+		},
+		{
+			"img/photoA.jpg",
+			[]string{"- photo[!0-9].jpg"},
+			"negated character range",
+			"Not transferring img/photoA.jpg because of pattern - photo[!0-9].jpg",
+			false,
+		},
+		{
+			"a/b/c.txt",
+			[]string{"- a/?/c.txt"},
+			"question mark matches a single character",
+			"Not transferring a/b/c.txt because of pattern - a/?/c.txt",
+			false,
+		},
+		{
+			"x/a/b/c.txt",
+			[]string{"- a/*/c.txt"},
+			"unanchored pattern with slashes matches the end of the path",
+			"Not transferring x/a/b/c.txt because of pattern - a/*/c.txt",
+			false,
+		},
+		{
+			"a/x/b/c.txt",
+			[]string{"- a/*/c.txt"},
+			"single star doesn't match slashes",
+			"",
+			true,
+		},
+		{
+			"a/x/b/c.txt",
+			[]string{"- /a/**/c.txt"},
+			"double star matches slashes",
+			"Not transferring a/x/b/c.txt because of pattern - /a/**/c.txt",
+			false,
+		},
+		{
+			"tmp",
+			[]string{"- tmp/***"},
+			"triple star matches the directory itself",
+			"Not transferring tmp because of pattern - tmp/***",
+			false,
+		},
+		{
+			"a/tmp/x/y.txt",
+			[]string{"+ a/", "+ a/tmp/***", "- *"},
+			"triple star matches everything in the directory",
+			"",
+			true,
+		},
+		{
+			"src/file.bak",
+			[]string{"merge rules.txt"},
+			"merge rule reads the rules of the file",
+			"Not transferring src/file.bak because of pattern - *.bak in rules.txt",
+			false,
+		},
+		{
+			"src/file.swp",
+			[]string{". rules.txt"},
+			"merge files can merge other files",
+			"Not transferring src/file.swp because of pattern - *.swp in nested.txt",
+			false,
+		},
+		{
+			"rules.txt",
+			[]string{".e rules.txt"},
+			"exclude-self modifier excludes the merge file",
+			"Not transferring rules.txt because of pattern .e rules.txt",
+			false,
+		},
+		{
+			"src/core",
+			[]string{"merge,w- words.txt"},
+			"word-split modifier splits the rules on whitespace",
+			"Not transferring src/core because of pattern core in words.txt",
+			false,
+		},
+		{
+			"src/main.o",
+			[]string{"-C"},
+			"cvs-exclude rule adds the default CVS exclusions",
+			"Not transferring src/main.o because of pattern *.o in -C",
+			false,
+		},
+		{
+			"src/main.o",
+			[]string{"unknown rule *.o"},
+			"invalid rule is an exclude pattern",
+			"",
+			true,
+		},
+		{
+			"src/*.o",
+			[]string{"x *.o"},
+			"invalid rule is an exclude pattern matching the full line",
+			"",
+			true,
+		},
+	}
+
+	root, err := ioutil.TempDir("", "rsync-filter-rules")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"build", "src", "tmp", "a/tmp/x"} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "src", "build"), []byte{}, 0644), "src/build is a file")
+
+	subject := NewRsyncMatcherPath()
+	subject.root = root
+	subject.readLines = func(file string) ([]string, error) {
+		return mergeFiles[file], nil
+	}
+
+	for _, scenario := range scenarios {
+		subject.AddPattern(scenario.patterns...)
+		match, msg, err := subject.HasMatchAndIsIncluded(scenario.path)
+		assert.Nil(t, err, scenario.description)
+		assert.Equal(t, scenario.toTransfer, match, scenario.description)
+		assert.Equal(t, scenario.msg, msg, scenario.description)
+	}
+}
+
+func TestRsyncPathPattern_HasMatchAndIsProtected(t *testing.T) {
+	scenarios := []struct {
+		path        string
+		patterns    []string
+		description string
+		msg         string
+		protected   bool
+	}{
+		{
+			"var/cache/a.php",
+			[]string{"P /var/cache"},
+			"protect rule protects the content of the directory",
+			"Not deleting var/cache/a.php because of pattern P /var/cache",
+			true,
+		},
+		{
+			"var/cache/a.php",
+			[]string{"R a.php", "P /var/cache"},
+			"parent directory is protected",
+			"Not deleting var/cache/a.php because of pattern P /var/cache",
+			true,
+		},
+		{
+			"var/logs/a.log",
+			[]string{"R *.log", "P /var/logs/*"},
+			"risk rule takes precedence",
+			"",
+			false,
+		},
+		{
+			"var/logs/a.log",
+			[]string{"- *.log"},
+			"exclude rule protects on the receiving side",
+			"Not deleting var/logs/a.log because of pattern - *.log",
+			true,
+		},
+		{
+			"var/logs/a.log",
+			[]string{"H *.log"},
+			"hide rule doesn't protect",
+			"",
+			false,
+		},
+	}
+
+	subject := NewRsyncMatcherPath()
+
+	for _, scenario := range scenarios {
+		subject.AddPattern(scenario.patterns...)
+		protected, msg, err := subject.HasMatchAndIsProtected(scenario.path)
+		assert.Nil(t, err, scenario.description)
+		assert.Equal(t, scenario.protected, protected, scenario.description)
+		assert.Equal(t, scenario.msg, msg, scenario.description)
+	}
+}
+
+func TestFilterRules(t *testing.T) {
+	rules := FilterRules("# comment", "", "vendor", "+ /vendor/autoload.php", "dir-merge,- .gitignore", "!", "x y", "- *.log")
+	assert.Equal(t, []string{"- vendor", "+ /vendor/autoload.php", "dir-merge,- .gitignore", "!", "- x y", "- *.log"}, rules)
+}
+
+func TestRsyncPathPattern_Explain(t *testing.T) {
+	scenarios := []struct {
+		path        string
+		description string
+		expected    Decision
+	}{
+		{
+			"src/app.log",
+			"excluded by a rule of the ignore file",
+			Decision{false, "src/app.log", "*.log", ".cp-remote-ignore"},
+		},
+		{
+			"src/keep.log",
+			"included by a rule of the ignore file",
+			Decision{true, "src/keep.log", "+ keep.log", ".cp-remote-ignore-fetch"},
+		},
+		{
+			"var/cache/file.php",
+			"excluded by a rule matching the parent directory",
+			Decision{false, "var/cache", "- /var/cache", ""},
+		},
+		{
+			"src/main.go",
+			"no rule matches",
+			Decision{true, "src/main.go", "", ""},
+		},
+	}
+
+	subject := NewRsyncMatcherPath()
+	subject.AddPatternFrom(".cp-remote-ignore-fetch", "+ keep.log")
+	subject.AddPatternFrom(".cp-remote-ignore", "# comment", "*.log")
+	subject.AddPatternFrom("", "- /var/cache")
+
+	for _, scenario := range scenarios {
+		decision, err := subject.Explain(scenario.path)
+		assert.Nil(t, err, scenario.description)
+		assert.Equal(t, scenario.expected, decision, scenario.description)
+	}
+}
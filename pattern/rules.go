@@ -0,0 +1,403 @@
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	"github.com/mitchellh/go-homedir"
+)
+
+//modifiers of a filter rule
+const (
+	//modifierAbsPath '/' matches the pattern against the absolute pathname of the item
+	modifierAbsPath = 1 << iota
+	//modifierNegate '!' makes the rule take effect when the pattern does not match
+	modifierNegate
+	//modifierNoPrefixes '-' or '+' on a merge rule, all the lines of the merged file are exclude or include patterns
+	modifierNoPrefixes
+	//modifierExcludeSelf 'e' excludes the merge file from the transfer
+	modifierExcludeSelf
+	//modifierNoInherit 'n' the rules of a per-directory merge file are not inherited by the sub-directories
+	modifierNoInherit
+	//modifierWordSplit 'w' the rules of the merge file are split on whitespace instead of lines
+	modifierWordSplit
+	//modifierCvsIgnore 'C' parses the merge file as a .cvsignore file, or adds the default CVS exclusions
+	modifierCvsIgnore
+	//modifierPerishable 'p' the rule is ignored in directories that are being deleted
+	modifierPerishable
+	//modifierSenderSide 's' the rule affects only the sending side
+	modifierSenderSide
+	//modifierReceiverSide 'r' the rule affects only the receiving side
+	modifierReceiverSide
+	//modifierXattr 'x' the rule affects only the extended attribute names
+	modifierXattr
+
+	//modifierDirectory is set when the pattern has a trailing slash, the rule matches only directories
+	modifierDirectory
+	//modifierWild is set when the pattern contains one of the wildcard characters *, ? or [
+	modifierWild
+	//modifierWild2 is set when the pattern contains **
+	modifierWild2
+	//modifierWild2Prefix is set when the pattern starts with **
+	modifierWild2Prefix
+	//modifierWild3Suffix is set when the pattern ends with ***
+	modifierWild3Suffix
+)
+
+//modifiers that the rules read from a merge file inherit from the merge rule
+const inheritedModifiers = modifierSenderSide | modifierReceiverSide | modifierPerishable | modifierXattr
+
+//maxMergeDepth prevents merge files from merging each others endlessly
+const maxMergeDepth = 16
+
+//defaultCvsIgnore is the list of exclusions that rsync uses for the -C rule and the --cvs-exclude option
+const defaultCvsIgnore = "RCS SCCS CVS CVS.adm RCSLOG cvslog.* tags TAGS .make.state .nse_depinfo *~ #* .#* ,* _$* *$" +
+	" *.old *.bak *.BAK *.orig *.rej .del-* *.a *.olb *.o *.obj *.so *.exe *.Z *.elc *.ln core .svn/ .git/ .hg/ .bzr/"
+
+var ruleNames = []struct {
+	name  string
+	short byte
+}{
+	{"clear", '!'},
+	{"dir-merge", ':'},
+	{"exclude", '-'},
+	{"hide", 'H'},
+	{"include", '+'},
+	{"merge", '.'},
+	{"protect", 'P'},
+	{"risk", 'R'},
+	{"show", 'S'},
+}
+
+//FilterRules converts the lines of an ignore file into the rules that can be given to the rsync --filter option.
+//Empty lines and comments are skipped and a line that is not a valid filter rule is an exclude pattern, as it would be
+//when the file is given to --exclude-from
+func FilterRules(lines ...string) []string {
+	var rules []string
+	for _, line := range lines {
+		if isCommentLine(line) {
+			continue
+		}
+		if _, err := parseRule(line); err != nil {
+			line = "- " + line
+		}
+		rules = append(rules, line)
+	}
+	return rules
+}
+
+//ExcludeRules converts the lines of a merge file read with the "-" modifier, like the per-directory ignore files, into
+//filter rules: every line that is not a comment is an exclude pattern
+func ExcludeRules(lines ...string) []string {
+	var rules []string
+	for _, line := range lines {
+		if isCommentLine(line) {
+			continue
+		}
+		rules = append(rules, "- "+line)
+	}
+	return rules
+}
+
+func isCommentLine(line string) bool {
+	return line == "" || line[0] == '#' || line[0] == ';'
+}
+
+//parseRule parses a filter rule written in the short form, e.g. "-! *.log", or in the long form, e.g. "exclude,! *.log"
+func parseRule(rule string) (item pathPatternItem, err error) {
+	item.rawPattern = rule
+	s := rule
+
+	var ch byte
+	for _, n := range ruleNames {
+		if !strings.HasPrefix(s, n.name) {
+			continue
+		}
+		next := s[len(n.name):]
+		switch {
+		case next == "" || next[0] == ' ' || next[0] == '_':
+			ch, s = n.short, next
+		case next[0] == ',':
+			ch, s = n.short, next[1:]
+		default:
+			continue
+		}
+		break
+	}
+	if ch == 0 {
+		if s == "" {
+			return item, errors.New("empty filter rule")
+		}
+		ch, s = s[0], s[1:]
+		if strings.HasPrefix(s, ",") {
+			s = s[1:]
+		}
+	}
+
+	switch ch {
+	case '-':
+		item.prefix = filterRuleExclude
+	case '+':
+		item.prefix = filterRuleInclude
+	case 'H':
+		item.prefix = filterRuleExclude
+		item.modifiers |= modifierSenderSide
+	case 'S':
+		item.prefix = filterRuleInclude
+		item.modifiers |= modifierSenderSide
+	case 'P':
+		item.prefix = filterRuleExclude
+		item.modifiers |= modifierReceiverSide
+	case 'R':
+		item.prefix = filterRuleInclude
+		item.modifiers |= modifierReceiverSide
+	case '.':
+		item.prefix = filterRuleMerge
+	case ':':
+		item.prefix = filterRuleDirMerge
+	case '!':
+		item.prefix = filterRuleClear
+	default:
+		return item, fmt.Errorf("unknown filter rule: `%s'", rule)
+	}
+	sideFromPrefix := ch == 'H' || ch == 'S' || ch == 'P' || ch == 'R'
+	isMerge := item.prefix == filterRuleMerge || item.prefix == filterRuleDirMerge
+
+	if ch != '!' {
+		for len(s) > 0 && s[0] != ' ' && s[0] != '_' {
+			mod := s[0]
+			s = s[1:]
+			switch {
+			case (mod == '-' || mod == '+') && isMerge && item.modifiers&modifierNoPrefixes == 0:
+				item.modifiers |= modifierNoPrefixes
+				if mod == '+' {
+					item.noPrefixesType = filterRuleInclude
+				} else {
+					item.noPrefixesType = filterRuleExclude
+				}
+			case mod == '/':
+				item.modifiers |= modifierAbsPath
+			case mod == '!' && !isMerge:
+				item.modifiers |= modifierNegate
+			case mod == 'C' && item.modifiers&modifierNoPrefixes == 0 && !sideFromPrefix:
+				item.modifiers |= modifierNoPrefixes | modifierWordSplit | modifierNoInherit | modifierCvsIgnore
+				item.noPrefixesType = filterRuleExclude
+			case mod == 'e' && isMerge:
+				item.modifiers |= modifierExcludeSelf
+			case mod == 'n' && isMerge:
+				item.modifiers |= modifierNoInherit
+			case mod == 'p':
+				item.modifiers |= modifierPerishable
+			case mod == 'r' && !sideFromPrefix:
+				item.modifiers |= modifierReceiverSide
+			case mod == 's' && !sideFromPrefix:
+				item.modifiers |= modifierSenderSide
+			case mod == 'w' && isMerge:
+				item.modifiers |= modifierWordSplit
+			case mod == 'x':
+				item.modifiers |= modifierXattr
+			default:
+				return item, fmt.Errorf("invalid modifier '%c' in filter rule: %s", mod, rule)
+			}
+		}
+	}
+	//skip the separator between the rule and the pattern
+	if len(s) > 0 && ch != '!' {
+		s = s[1:]
+	}
+	item.pattern = s
+
+	switch {
+	case item.prefix == filterRuleClear:
+		if item.pattern != "" {
+			return item, fmt.Errorf("'!' rule has trailing characters: %s", rule)
+		}
+	case isMerge && item.pattern == "" && item.modifiers&modifierCvsIgnore != 0:
+		item.pattern = ".cvsignore"
+	case item.pattern == "" && (isMerge || item.modifiers&modifierCvsIgnore == 0):
+		return item, fmt.Errorf("unexpected end of filter rule: %s", rule)
+	}
+	return item, nil
+}
+
+//newPathPatternItem returns an include or exclude rule for the pattern and sets the modifiers derived from the pattern
+func newPathPatternItem(prefix rune, pattern string, rawPattern string, modifiers int) pathPatternItem {
+	item := pathPatternItem{
+		prefix:     prefix,
+		pattern:    pattern,
+		rawPattern: rawPattern,
+		modifiers:  modifiers,
+	}
+	item.setPatternModifiers()
+	return item
+}
+
+//setPatternModifiers strips the trailing slash of a directory pattern and sets the wildcard modifiers
+func (item *pathPatternItem) setPatternModifiers() {
+	p := item.pattern
+	if len(p) > 1 && strings.HasSuffix(p, "/") {
+		p = p[:len(p)-1]
+		item.modifiers |= modifierDirectory
+	}
+	if strings.ContainsAny(p, "*[?") {
+		item.modifiers |= modifierWild
+		if i := strings.Index(p, "**"); i >= 0 {
+			item.modifiers |= modifierWild2
+			if i == 0 {
+				item.modifiers |= modifierWild2Prefix
+			}
+			if strings.HasSuffix(p, "***") {
+				item.modifiers |= modifierWild3Suffix
+			}
+		}
+	}
+	item.slashCount = strings.Count(p, "/")
+	item.pattern = p
+}
+
+//ruleContext describes where the rules being parsed come from
+type ruleContext struct {
+	//template is the merge rule the rules were read from, nil for the rules given to AddPattern
+	template *pathPatternItem
+	//dir is the directory containing the per-directory merge file, the anchored patterns are anchored to it
+	dir string
+	//perDirectory is true when parsing the rules of a per-directory merge file
+	perDirectory bool
+	//source is the file the rules were read from
+	source string
+	depth  int
+}
+
+//parseRules parses the lines and appends the rules to items, cleared is true when a clear rule emptied the list
+func (m *RsyncMatcherPath) parseRules(items []pathPatternItem, lines []string, ctx ruleContext) (result []pathPatternItem, cleared bool) {
+	wordSplit := ctx.template != nil && ctx.template.modifiers&modifierWordSplit != 0
+	for _, line := range lines {
+		if wordSplit {
+			for _, word := range strings.Fields(line) {
+				items, cleared = m.addRule(items, word, ctx, cleared)
+			}
+			continue
+		}
+		if isCommentLine(line) {
+			continue
+		}
+		items, cleared = m.addRule(items, line, ctx, cleared)
+	}
+	return items, cleared
+}
+
+//addRule parses the line and appends the resulting rules to items
+func (m *RsyncMatcherPath) addRule(items []pathPatternItem, line string, ctx ruleContext, cleared bool) ([]pathPatternItem, bool) {
+	var item pathPatternItem
+	var err error
+	switch {
+	case ctx.template != nil && ctx.template.modifiers&modifierNoPrefixes != 0:
+		if line == "!" && ctx.template.modifiers&modifierCvsIgnore != 0 {
+			return nil, true
+		}
+		item = pathPatternItem{prefix: ctx.template.noPrefixesType, pattern: line, rawPattern: line}
+	default:
+		item, err = parseRule(line)
+		if err != nil && ctx.template == nil {
+			//as --exclude-from does, a line that is not a filter rule is an exclude pattern
+			item, err = pathPatternItem{prefix: filterRuleExclude, pattern: line, rawPattern: line}, nil
+		}
+	}
+	if err != nil {
+		cplogs.V(4).Infof("ignoring the filter rule %s of %s, details %s", line, ctx.source, err.Error())
+		cplogs.Flush()
+		return items, cleared
+	}
+	if ctx.template != nil {
+		item.modifiers |= ctx.template.modifiers & inheritedModifiers
+	}
+	item.source = ctx.source
+
+	switch item.prefix {
+	case filterRuleClear:
+		return nil, true
+	case filterRuleMerge, filterRuleDirMerge:
+		return m.parseMergeRule(items, item, ctx), cleared
+	}
+
+	if item.modifiers&modifierCvsIgnore != 0 {
+		return append(items, m.cvsIgnoreItems(item)...), cleared
+	}
+	if ctx.perDirectory && ctx.dir != "" && strings.HasPrefix(item.pattern, "/") {
+		item.pattern = "/" + ctx.dir + item.pattern
+	}
+	item.setPatternModifiers()
+	return append(items, item), cleared
+}
+
+//parseMergeRule adds the rules of a merge file, or the dir-merge rule that will load the per-directory files
+func (m *RsyncMatcherPath) parseMergeRule(items []pathPatternItem, item pathPatternItem, ctx ruleContext) []pathPatternItem {
+	file := item.pattern
+	if item.modifiers&modifierExcludeSelf != 0 {
+		items = append(items, newPathPatternItem(filterRuleExclude, path.Base(file), item.rawPattern, item.modifiers&inheritedModifiers))
+		items[len(items)-1].source = item.source
+	}
+
+	if item.prefix == filterRuleDirMerge {
+		if ctx.perDirectory {
+			cplogs.V(4).Infof("ignoring the rule %s of %s, a per-directory file cannot contain dir-merge rules", item.rawPattern, ctx.source)
+			cplogs.Flush()
+			return items
+		}
+		item.pattern = path.Base(file)
+		return append(items, item)
+	}
+
+	if ctx.depth >= maxMergeDepth {
+		cplogs.V(4).Infof("ignoring the rule %s of %s, too many nested merge files", item.rawPattern, ctx.source)
+		cplogs.Flush()
+		return items
+	}
+	if ctx.perDirectory && !path.IsAbs(file) {
+		file = path.Join(ctx.dir, file)
+	}
+	lines, err := m.readLines(file)
+	if err != nil {
+		cplogs.V(4).Infof("error when reading the merge file %s, details %s", file, err.Error())
+		cplogs.Flush()
+		return items
+	}
+	mergeCtx := ctx
+	mergeCtx.template = &item
+	mergeCtx.source = file
+	mergeCtx.depth++
+	items, cleared := m.parseRules(items, lines, mergeCtx)
+	if cleared && ctx.perDirectory {
+		cplogs.V(4).Infof("the merge file %s cleared the rules of %s", file, ctx.source)
+		cplogs.Flush()
+	}
+	return items
+}
+
+//cvsIgnoreItems returns the exclusions of the -C rule: the default CVS list, the rules in $HOME/.cvsignore and the
+//rules in the CVSIGNORE environment variable
+func (m *RsyncMatcherPath) cvsIgnoreItems(rule pathPatternItem) (items []pathPatternItem) {
+	template := rule
+	template.modifiers |= modifierNoPrefixes | modifierWordSplit
+	template.noPrefixesType = rule.prefix
+
+	ctx := ruleContext{template: &template, source: rule.rawPattern}
+	items, _ = m.parseRules(items, []string{defaultCvsIgnore}, ctx)
+	if home, err := homedir.Dir(); err == nil {
+		file := filepath.Join(home, ".cvsignore")
+		if lines, err := readMergeFile(file); err == nil {
+			ctx.source = file
+			items, _ = m.parseRules(items, lines, ctx)
+		}
+	}
+	if env := os.Getenv("CVSIGNORE"); env != "" {
+		ctx.source = "CVSIGNORE"
+		items, _ = m.parseRules(items, []string{env}, ctx)
+	}
+	return items
+}
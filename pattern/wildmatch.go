@@ -0,0 +1,265 @@
+package pattern
+
+import "strings"
+
+//results of the wildcard matching, the abort results allow to stop the backtracking early as rsync does
+const (
+	wildAbortToStarStar = iota - 2
+	wildAbortAll
+	wildFalse
+	wildTrue
+)
+
+//wildmatch matches the text against a wildcard pattern using the rsync rules:
+//
+// - '*' matches any path component, but it stops at slashes.
+// - '**' matches anything, including slashes.
+// - '?' matches any character except a slash.
+// - '[' introduces a character class, such as [a-z], [!0-9], [^a-z] or [[:alpha:]].
+// - a backslash escapes the following wildcard character.
+//
+//when where is greater than 0 only the last where path elements of the text are matched, when where is -1 the
+//pattern is matched starting from the beginning of the text and after every slash
+func wildmatch(pattern string, text string, where int) bool {
+	if where > 0 {
+		var ok bool
+		if text, ok = trailingElements(text, where); !ok {
+			return false
+		}
+	}
+
+	matched := doWild(pattern, text)
+	if matched != wildTrue && where < 0 && matched != wildAbortAll {
+		for i := 0; i < len(text); i++ {
+			if text[i] != '/' {
+				continue
+			}
+			matched = doWild(pattern, text[i+1:])
+			if matched != wildFalse && matched != wildAbortToStarStar {
+				break
+			}
+		}
+	}
+	return matched == wildTrue
+}
+
+//litmatch compares the literal pattern with the text, when where is greater than 0 only the last where path elements
+//of the text are compared
+func litmatch(pattern string, text string, where int) bool {
+	if where > 0 {
+		var ok bool
+		if text, ok = trailingElements(text, where); !ok {
+			return false
+		}
+	}
+	return pattern == text
+}
+
+//trailingElements returns the last count path elements of the text, ok is false when the text doesn't have enough elements
+func trailingElements(text string, count int) (elements string, ok bool) {
+	for i := len(text) - 1; i >= 0; i-- {
+		if text[i] == '/' {
+			count--
+			if count == 0 {
+				return text[i+1:], true
+			}
+		}
+	}
+	if count == 1 {
+		return text, true
+	}
+	return "", false
+}
+
+func doWild(p string, text string) int {
+	pi, ti := 0, 0
+	for ; pi < len(p); pi, ti = pi+1, ti+1 {
+		pCh := p[pi]
+		var tCh byte
+		if ti < len(text) {
+			tCh = text[ti]
+		} else if pCh != '*' {
+			return wildAbortAll
+		}
+
+		switch pCh {
+		case '\\':
+			//literal match with the following character
+			pi++
+			if pi == len(p) || tCh != p[pi] {
+				return wildFalse
+			}
+		case '?':
+			//match anything but a slash
+			if tCh == '/' {
+				return wildFalse
+			}
+		case '*':
+			special := false
+			pi++
+			if pi < len(p) && p[pi] == '*' {
+				for pi < len(p) && p[pi] == '*' {
+					pi++
+				}
+				special = true
+			}
+			if pi == len(p) {
+				//trailing "**" matches everything, trailing "*" matches only if there are no more slashes
+				if !special && strings.ContainsRune(text[ti:], '/') {
+					return wildFalse
+				}
+				return wildTrue
+			}
+			for ; ti < len(text); ti++ {
+				matched := doWild(p[pi:], text[ti:])
+				if matched != wildFalse {
+					if !special || matched != wildAbortToStarStar {
+						return matched
+					}
+				} else if !special && text[ti] == '/' {
+					return wildAbortToStarStar
+				}
+			}
+			return wildFalse
+		case '[':
+			var matched int
+			pi, matched = matchClass(p, pi, tCh)
+			if matched != wildTrue {
+				return matched
+			}
+		default:
+			if tCh != pCh {
+				return wildFalse
+			}
+		}
+	}
+
+	if ti < len(text) {
+		return wildFalse
+	}
+	return wildTrue
+}
+
+//matchClass matches the character against the class starting at p[pi], it returns the index of the closing bracket
+func matchClass(p string, pi int, tCh byte) (end int, matched int) {
+	next := func() (byte, bool) {
+		pi++
+		if pi >= len(p) {
+			return 0, false
+		}
+		return p[pi], true
+	}
+
+	pCh, ok := next()
+	if !ok {
+		return pi, wildAbortAll
+	}
+	negated := pCh == '!' || pCh == '^'
+	if negated {
+		if pCh, ok = next(); !ok {
+			return pi, wildAbortAll
+		}
+	}
+
+	found := false
+	var prevCh byte
+	for {
+		switch {
+		case pCh == '\\':
+			if pCh, ok = next(); !ok {
+				return pi, wildAbortAll
+			}
+			if tCh == pCh {
+				found = true
+			}
+		case pCh == '-' && prevCh != 0 && pi+1 < len(p) && p[pi+1] != ']':
+			if pCh, ok = next(); !ok {
+				return pi, wildAbortAll
+			}
+			if pCh == '\\' {
+				if pCh, ok = next(); !ok {
+					return pi, wildAbortAll
+				}
+			}
+			if tCh <= pCh && tCh >= prevCh {
+				found = true
+			}
+			pCh = 0
+		case pCh == '[' && pi+1 < len(p) && p[pi+1] == ':':
+			start := pi + 2
+			closing := strings.IndexByte(p[start:], ']')
+			if closing < 0 {
+				return pi, wildAbortAll
+			}
+			if closing == 0 || p[start+closing-1] != ':' {
+				//didn't find ":]", treat it like a normal set
+				if tCh == '[' {
+					found = true
+				}
+				break
+			}
+			inClass, known := characterClass(p[start:start+closing-1], tCh)
+			if !known {
+				return pi, wildAbortAll
+			}
+			if inClass {
+				found = true
+			}
+			pi = start + closing
+			pCh = 0
+		default:
+			if tCh == pCh {
+				found = true
+			}
+		}
+
+		prevCh = pCh
+		if pCh, ok = next(); !ok {
+			return pi, wildAbortAll
+		}
+		if pCh == ']' {
+			break
+		}
+	}
+
+	if found == negated || tCh == '/' {
+		return pi, wildFalse
+	}
+	return pi, wildTrue
+}
+
+//characterClass reports if the character belongs to the named POSIX class, known is false for an unknown class name
+func characterClass(class string, c byte) (inClass bool, known bool) {
+	isUpper := c >= 'A' && c <= 'Z'
+	isLower := c >= 'a' && c <= 'z'
+	isDigit := c >= '0' && c <= '9'
+	isAlpha := isUpper || isLower
+	isPrint := c >= 0x20 && c < 0x7f
+	switch class {
+	case "alnum":
+		return isAlpha || isDigit, true
+	case "alpha":
+		return isAlpha, true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isDigit, true
+	case "graph":
+		return isPrint && c != ' ', true
+	case "lower":
+		return isLower, true
+	case "print":
+		return isPrint, true
+	case "punct":
+		return isPrint && c != ' ' && !isAlpha && !isDigit, true
+	case "space":
+		return c == ' ' || (c >= '\t' && c <= '\r'), true
+	case "upper":
+		return isUpper, true
+	case "xdigit":
+		return isDigit || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'), true
+	}
+	return false, false
+}
@@ -0,0 +1,282 @@
+package diff
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/continuouspipe/remote-environment-client/sync/rsync"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+//lists the files of the paths given as arguments, relative to the directory $1, with their content hash.
+//The first line is the hash algorithm, none when neither sha1sum nor md5sum are available
+const remoteListFiles = `cd "$1" || exit 1
+shift
+if command -v sha1sum >/dev/null 2>&1; then
+	echo sha1
+	find "$@" -type f -exec sha1sum {} + 2>/dev/null
+elif command -v md5sum >/dev/null 2>&1; then
+	echo md5
+	find "$@" -type f -exec md5sum {} + 2>/dev/null
+else
+	echo none
+	find "$@" -type f 2>/dev/null
+fi
+exit 0`
+
+//prints the content of the file $1 between brackets so that the leading and trailing new lines are kept
+const remoteReadFile = `printf '[' && cat "$1" && printf ']'`
+
+//the number of bytes looked at to decide if a file is binary
+const binarySniffLen = 8000
+
+//Result lists the differences between the local and the remote project files, the paths are relative to the project
+type Result struct {
+	OnlyLocal  []string
+	OnlyRemote []string
+	Modified   []string
+}
+
+//Empty returns true when the local and the remote files are the same
+func (r Result) Empty() bool {
+	return len(r.OnlyLocal) == 0 && len(r.OnlyRemote) == 0 && len(r.Modified) == 0
+}
+
+//Differ compares the project files that are not excluded by the ignore files with the ones of the pod
+type Differ struct {
+	spawner           kexec.Spawner
+	kscmd             kexec.KSCommand
+	remoteProjectPath string
+	useGitIgnore      bool
+}
+
+func NewDiffer(kscmd kexec.KSCommand, remoteProjectPath string, useGitIgnore bool) *Differ {
+	d := &Differ{}
+	d.spawner = kexec.NewLocal()
+	d.kscmd = kscmd
+	d.remoteProjectPath = remoteProjectPath
+	d.useGitIgnore = useGitIgnore
+	return d
+}
+
+//Compare returns the files that differ in the given paths, relative to the project in the slash separated form,
+//the whole project is compared when paths is empty
+func (d Differ) Compare(paths []string) (*Result, error) {
+	matcher, err := rsync.NewExclusionMatcher(false, d.useGitIgnore)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the push exclusions").String())
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	algorithm, remote, err := d.remoteFiles(paths, matcher)
+	if err != nil {
+		return nil, err
+	}
+	local, err := localFiles(paths, matcher, algorithm)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the local files").String())
+	}
+
+	res := &Result{}
+	for file, localHash := range local {
+		remoteHash, ok := remote[file]
+		if !ok {
+			res.OnlyLocal = append(res.OnlyLocal, file)
+			continue
+		}
+		modified := localHash != remoteHash
+		if algorithm == "none" {
+			modified, err = d.contentDiffers(file)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if modified {
+			res.Modified = append(res.Modified, file)
+		}
+	}
+	for file := range remote {
+		if _, ok := local[file]; !ok {
+			res.OnlyRemote = append(res.OnlyRemote, file)
+		}
+	}
+	sort.Strings(res.OnlyLocal)
+	sort.Strings(res.OnlyRemote)
+	sort.Strings(res.Modified)
+	return res, nil
+}
+
+//UnifiedDiff returns the changes that a push would make to the remote file, binary is true when one of the
+//versions of the file is not a text file
+func (d Differ) UnifiedDiff(file string) (diff string, binary bool, err error) {
+	remoteContent, err := d.readRemoteFile(file)
+	if err != nil {
+		return "", false, err
+	}
+	localContent, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", false, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when reading the local file %s", file)).String())
+	}
+	if isBinary([]byte(remoteContent)) || isBinary(localContent) {
+		return "", true, nil
+	}
+	diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(remoteContent),
+		B:        splitLines(string(localContent)),
+		FromFile: "remote/" + file,
+		ToFile:   "local/" + file,
+		Context:  3,
+	})
+	return diff, false, err
+}
+
+//remoteFiles lists the remote files that are not excluded with their content hash
+func (d Differ) remoteFiles(paths []string, matcher *pattern.RsyncMatcherPath) (algorithm string, files map[string]string, err error) {
+	args := []string{"sh", "-c", remoteListFiles, "sh", d.remoteProjectPath}
+	for _, p := range paths {
+		args = append(args, "./"+strings.TrimPrefix(p, "./"))
+	}
+	out, err := d.spawner.CommandExec(d.commandWithoutStdio(), args...)
+	if err != nil {
+		return "", nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when listing the files of the pod %s", d.kscmd.Pod)).String())
+	}
+
+	lines := strings.Split(strings.Replace(out, "\r", "", -1), "\n")
+	algorithm = strings.TrimSpace(lines[0])
+	files = map[string]string{}
+	for _, line := range lines[1:] {
+		fileHash, file := "", line
+		if algorithm != "none" {
+			//the hash and the path are separated by two spaces
+			parts := strings.SplitN(line, "  ", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			fileHash, file = parts[0], parts[1]
+		}
+		file = path.Clean(file)
+		if file == "." || file == ".git" || strings.HasPrefix(file, ".git/") {
+			continue
+		}
+		if include, _, err := matcher.HasMatchAndIsIncluded(file); err != nil || !include {
+			continue
+		}
+		files[file] = fileHash
+	}
+	cplogs.V(5).Infof("%d remote files listed using the %s hash", len(files), algorithm)
+	cplogs.Flush()
+	return algorithm, files, nil
+}
+
+//localFiles lists the local files that are not excluded with their content hash
+func localFiles(paths []string, matcher *pattern.RsyncMatcherPath, algorithm string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, root := range paths {
+		err := rsync.WalkIncluded(root, matcher, func(p string, relPath string, info os.FileInfo) error {
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			fileHash, err := hashFile(p, algorithm)
+			if err != nil {
+				return err
+			}
+			files[path.Clean(relPath)] = fileHash
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func hashFile(file string, algorithm string) (string, error) {
+	var h hash.Hash
+	switch algorithm {
+	case "sha1":
+		h = sha1.New()
+	case "md5":
+		h = md5.New()
+	default:
+		return "", nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//contentDiffers compares the local and the remote content when the pod can't compute the hash of the files
+func (d Differ) contentDiffers(file string) (bool, error) {
+	remoteContent, err := d.readRemoteFile(file)
+	if err != nil {
+		return false, err
+	}
+	localContent, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when reading the local file %s", file)).String())
+	}
+	return !bytes.Equal([]byte(remoteContent), localContent), nil
+}
+
+//readRemoteFile streams the content of the remote file
+func (d Differ) readRemoteFile(file string) (string, error) {
+	remotePath := path.Join(d.remoteProjectPath, file)
+	out, err := d.spawner.CommandExec(d.commandWithoutStdio(), "sh", "-c", remoteReadFile, "sh", remotePath)
+	if err != nil {
+		return "", errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when reading the remote file %s", remotePath)).String())
+	}
+	if !strings.HasPrefix(out, "[") || !strings.HasSuffix(out, "]") {
+		return "", errors.New(cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("unexpected output when reading the remote file %s", remotePath)).String())
+	}
+	return out[1 : len(out)-1], nil
+}
+
+func (d Differ) commandWithoutStdio() kexec.KSCommand {
+	kscmd := d.kscmd
+	kscmd.Stdin = nil
+	kscmd.Stdout = nil
+	kscmd.Stderr = ioutil.Discard
+	return kscmd
+}
+
+//splitLines splits the content in lines that end with a new line, a new line is added to the last line when missing
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+//isBinary returns true when the beginning of the content contains a NUL byte
+func isBinary(content []byte) bool {
+	if len(content) > binarySniffLen {
+		content = content[:binarySniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -0,0 +1,112 @@
+package diff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeSpawner returns the file list for the list script and the content of the remote files for the read script
+type fakeSpawner struct {
+	list    string
+	content map[string]string
+}
+
+func (f fakeSpawner) CommandExec(kscmd kexec.KSCommand, execCmdArgs ...string) (string, error) {
+	if execCmdArgs[2] == remoteListFiles {
+		return f.list, nil
+	}
+	return "[" + f.content[execCmdArgs[len(execCmdArgs)-1]] + "]", nil
+}
+
+func TestDiffer_Compare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-remote-diff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(dir)
+
+	files := map[string]string{
+		"same.txt":           "same\n",
+		"changed.txt":        "local\n",
+		"local.txt":          "only local\n",
+		"var/cache/file.txt": "excluded\n",
+		".cp-remote-ignore":  "var/cache/\n",
+	}
+	for file, content := range files {
+		os.MkdirAll(filepath.Dir(file), 0755)
+		assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	sameHash, _ := hashFile("same.txt", "sha1")
+	ignoreHash, _ := hashFile(".cp-remote-ignore", "sha1")
+
+	tests := []struct {
+		scenario string
+		spawner  fakeSpawner
+	}{
+		{
+			"the pod computes the hashes",
+			fakeSpawner{list: strings.Join([]string{
+				"sha1",
+				sameHash + "  ./same.txt",
+				"da39a3ee5e6b4b0d3255bfef95601890afd80709  ./changed.txt",
+				ignoreHash + "  ./.cp-remote-ignore",
+				"da39a3ee5e6b4b0d3255bfef95601890afd80709  ./remote.txt",
+				"da39a3ee5e6b4b0d3255bfef95601890afd80709  ./var/cache/remote.txt",
+				"da39a3ee5e6b4b0d3255bfef95601890afd80709  ./.git/HEAD",
+			}, "\n")},
+		},
+		{
+			"the pod can't compute the hashes",
+			fakeSpawner{
+				list: "none\n./same.txt\n./changed.txt\n./.cp-remote-ignore\n./remote.txt\n./var/cache/remote.txt",
+				content: map[string]string{
+					"/app/same.txt":          "same\n",
+					"/app/changed.txt":       "remote\n",
+					"/app/.cp-remote-ignore": "var/cache/\n",
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		differ := NewDiffer(kexec.KSCommand{}, "/app/", false)
+		differ.spawner = test.spawner
+		res, err := differ.Compare(nil)
+		assert.Nil(t, err, test.scenario)
+		assert.Equal(t, &Result{
+			OnlyLocal:  []string{"local.txt"},
+			OnlyRemote: []string{"remote.txt"},
+			Modified:   []string{"changed.txt"},
+		}, res, test.scenario)
+	}
+}
+
+func TestDiffer_UnifiedDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cp-remote-diff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(dir)
+
+	assert.Nil(t, ioutil.WriteFile("file.txt", []byte("a\nb\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile("file.bin", []byte("a\x00b"), 0644))
+
+	differ := NewDiffer(kexec.KSCommand{}, "/app/", false)
+	differ.spawner = fakeSpawner{content: map[string]string{"/app/file.txt": "a\nc\n", "/app/file.bin": "a\x00c"}}
+
+	unified, binary, err := differ.UnifiedDiff("file.txt")
+	assert.Nil(t, err)
+	assert.False(t, binary)
+	assert.Equal(t, "--- remote/file.txt\n+++ local/file.txt\n@@ -1,2 +1,2 @@\n a\n-c\n+b\n", unified)
+
+	_, binary, err = differ.UnifiedDiff("file.bin")
+	assert.Nil(t, err)
+	assert.True(t, binary)
+}
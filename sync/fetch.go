@@ -5,10 +5,10 @@ import (
 	"github.com/continuouspipe/remote-environment-client/sync/options"
 )
 
-//fetch all the project files from the pod, or if the filePath is not empty it
-//fetch a specific file
+//fetch all the project files from the pod, or if paths is not empty it
+//fetch the given files, directories and patterns
 type Fetcher interface {
-	Fetch(string) error
+	Fetch(paths []string) error
 	SetOptions(syncOptions options.SyncOptions)
 }
 
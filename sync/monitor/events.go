@@ -0,0 +1,86 @@
+package monitor
+
+//EventOp is the kind of change that happened on a path
+type EventOp int
+
+const (
+	//EventWrite is a path that has been created or modified
+	EventWrite EventOp = iota
+	//EventRemove is a path that has been removed
+	EventRemove
+	//EventRename is a path that has been moved from OldPath
+	EventRename
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventRemove:
+		return "remove"
+	case EventRename:
+		return "rename"
+	}
+	return "write"
+}
+
+//PathEvent is a change on a file or directory reported by the directory monitor
+type PathEvent struct {
+	Path string
+	Op   EventOp
+	//OldPath is the path before the rename, it is only set for EventRename
+	OldPath string
+}
+
+//eventBatch collects the events that happened since the last sync
+//
+//the monitors report a rename as two notifications, one for the old path followed by one for the new path,
+//eventBatch pairs them in a single rename event. When the new path is not reported (e.g. moved outside of the
+//watched directory or into an excluded one) the old path has been removed
+type eventBatch struct {
+	events        []PathEvent
+	pendingRename string
+}
+
+//write adds a created or modified path
+func (b *eventBatch) write(path string) {
+	b.closeRename()
+	b.events = append(b.events, PathEvent{Path: path, Op: EventWrite})
+}
+
+//remove adds a removed path
+func (b *eventBatch) remove(path string) {
+	b.closeRename()
+	b.events = append(b.events, PathEvent{Path: path, Op: EventRemove})
+}
+
+//renamedFrom records the old path of a rename, waiting for the new path
+func (b *eventBatch) renamedFrom(path string) {
+	b.closeRename()
+	b.pendingRename = path
+}
+
+//created adds a path that appeared, which is the new path of the pending rename if there is one
+func (b *eventBatch) created(path string) {
+	if b.pendingRename == "" || b.pendingRename == path {
+		b.write(path)
+		return
+	}
+	b.events = append(b.events, PathEvent{Path: path, Op: EventRename, OldPath: b.pendingRename})
+	b.pendingRename = ""
+}
+
+//closeRename turns a rename that didn't get a new path in a removal
+func (b *eventBatch) closeRename() {
+	if b.pendingRename == "" {
+		return
+	}
+	b.events = append(b.events, PathEvent{Path: b.pendingRename, Op: EventRemove})
+	b.pendingRename = ""
+}
+
+//flush returns the collected events and empties the batch
+func (b *eventBatch) flush() []PathEvent {
+	b.closeRename()
+	events := b.events
+	b.events = nil
+	return events
+}
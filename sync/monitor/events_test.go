@@ -0,0 +1,28 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBatch_Flush(t *testing.T) {
+	batch := eventBatch{}
+	batch.write("/project/a.txt")
+	batch.renamedFrom("/project/a.txt")
+	batch.created("/project/b.txt")
+	batch.renamedFrom("/project/dir")
+	batch.remove("/project/c.txt")
+	batch.created("/project/d.txt")
+	batch.renamedFrom("/project/e.txt")
+
+	assert.Equal(t, []PathEvent{
+		{Path: "/project/a.txt", Op: EventWrite},
+		{Path: "/project/b.txt", Op: EventRename, OldPath: "/project/a.txt"},
+		{Path: "/project/dir", Op: EventRemove},
+		{Path: "/project/c.txt", Op: EventRemove},
+		{Path: "/project/d.txt", Op: EventWrite},
+		{Path: "/project/e.txt", Op: EventRemove},
+	}, batch.flush())
+	assert.Empty(t, batch.flush())
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/continuouspipe/remote-environment-client/config"
 	"github.com/continuouspipe/remote-environment-client/cplogs"
 	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/continuouspipe/remote-environment-client/util/slice"
 )
 
 //CustomExclusionsFile is the default ignore file for rsync exclusions
@@ -28,9 +29,13 @@ type ExclusionProvider interface {
 type Exclusion struct {
 	DefaultExclusions       []string
 	FirstCreationExclusions []string
-	ignore                  *config.Ignore
-	rsyncMatcherPath        pattern.PathPatternMatcher
-	writer                  io.Writer
+	//UseGitIgnore excludes the paths matched by the .gitignore files found in the project directories
+	UseGitIgnore bool
+	//Presets are the framework exclusions added when the exclusion file is created for a project using the framework
+	Presets          []ExclusionPreset
+	ignore           *config.Ignore
+	rsyncMatcherPath pattern.PathPatternMatcher
+	writer           io.Writer
 }
 
 //NewExclusion default constructor for Exclusion
@@ -52,23 +57,51 @@ func NewExclusion() *Exclusion {
 	m.FirstCreationExclusions = []string{
 		`.*`,
 	}
+	m.Presets = ExclusionPresets()
 	return m
 }
 
 // WriteDefaultExclusionsToFile check if the exclusion file exists
-// if the exclusion file does not already exist in the system it will add the values contained on FirstCreationExclusions
+// if the exclusion file does not already exist in the system it will add the values contained on FirstCreationExclusions,
+// unless the .gitignore files are used to decide which files stay local, and the exclusions of the presets detected in the project
 // if the exclusion file already exists it simply add the missing DefaultExclusions using the config.Ignore struct
 func (m *Exclusion) WriteDefaultExclusionsToFile() (bool, error) {
 	exclusions := []string{}
 	if _, err := os.Stat(m.ignore.File); os.IsNotExist(err) {
-		exclusions = append(exclusions, m.FirstCreationExclusions...)
+		if !m.UseGitIgnore {
+			exclusions = append(exclusions, m.FirstCreationExclusions...)
+		}
+		for _, preset := range m.DetectPresets() {
+			fmt.Fprintf(m.writer, "Detected a %s project, adding its exclusions to %s\n", preset.Name, m.ignore.File)
+			exclusions = append(exclusions, preset.Exclusions...)
+		}
 	}
 	exclusions = append(exclusions, m.DefaultExclusions...)
-	return m.ignore.AddToIgnore(exclusions...)
+	return m.ignore.AddToIgnore(slice.RemoveDuplicateString(exclusions)...)
+}
+
+//DetectPresets returns the presets of the frameworks used by the project in the current directory
+func (m Exclusion) DetectPresets() (detected []ExclusionPreset) {
+	for _, preset := range m.Presets {
+		if preset.Detect(".") {
+			detected = append(detected, preset)
+		}
+	}
+	return detected
+}
+
+//AddPreset adds the exclusions of the preset to the exclusion file
+func (m *Exclusion) AddPreset(name string) (bool, error) {
+	for _, preset := range m.Presets {
+		if strings.EqualFold(preset.Name, name) {
+			return m.ignore.AddToIgnore(slice.RemoveDuplicateString(preset.Exclusions)...)
+		}
+	}
+	return false, fmt.Errorf("the exclusion preset %s does not exist", name)
 }
 
 //MatchExclusionList loads the list of exclusions from the ignore file and
-//feeds them to the NewRsyncMatcherPath
+//feeds them to the NewRsyncMatcherPath followed by the per-directory rules
 func (m Exclusion) MatchExclusionList(target string) (bool, error) {
 	err := m.ignore.LoadFromIgnoreFile()
 	if err != nil {
@@ -85,7 +118,7 @@ func (m Exclusion) MatchExclusionList(target string) (bool, error) {
 		return false, err
 	}
 
-	m.rsyncMatcherPath.AddPattern(m.ignore.List...)
+	m.rsyncMatcherPath.AddPattern(append(m.ignore.List, m.PerDirectoryRules()...)...)
 	matchIncluded, msg, err := m.rsyncMatcherPath.HasMatchAndIsIncluded(target)
 	if msg != "" {
 		fmt.Fprintln(m.writer, msg)
@@ -104,6 +137,35 @@ func (m Exclusion) MatchExclusionList(target string) (bool, error) {
 	return !matchIncluded, nil
 }
 
+//Explain returns the rule of the exclusion list that decides if the changes of the path, relative to the project
+//directory, are synced
+func (m Exclusion) Explain(target string) (pattern.Decision, error) {
+	err := m.ignore.LoadFromIgnoreFile()
+	if err != nil {
+		return pattern.Decision{}, err
+	}
+	matcher := pattern.NewRsyncMatcherPath()
+	matcher.AddPatternFrom(m.ignore.File, m.ignore.List...)
+	matcher.AddPatternFrom("", m.PerDirectoryRules()...)
+	return matcher.Explain(target)
+}
+
+//PerDirectoryRules returns the rsync dir-merge rules that load the exclusions from the ignore files
+//nested in the project directories, the same rules are given to rsync so that it agrees with the watcher
+func (m Exclusion) PerDirectoryRules() []string {
+	return PerDirectoryRules(m.UseGitIgnore)
+}
+
+//PerDirectoryRules returns the dir-merge rules of the nested ignore files and, when they are used, of the .gitignore
+//files. The watcher and the matcher of rsync both use them so that they exclude the same paths
+func PerDirectoryRules(useGitIgnore bool) []string {
+	rules := []string{":- " + CustomExclusionsFile}
+	if useGitIgnore {
+		rules = append(rules, ":- "+config.GitIgnore)
+	}
+	return rules
+}
+
 // convertWindowsPath converts a windows native path to a path that can be used by rsyncMatcherPath
 func (m Exclusion) convertWindowsPath(path string) string {
 	// If the path starts with a single letter followed by a ":", it needs to
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/continuouspipe/remote-environment-client/cplogs"
 	"github.com/fsnotify/fsevents"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -52,10 +53,10 @@ func (m FsEvents) AnyEventCall(directory string, observer EventsObserver) error
 	cplogs.V(5).Infof("Device UUID %s", fsevents.GetDeviceUUID(dev))
 
 	var (
-		changeLock  sync.Mutex
-		dirty       bool
-		lastChange  time.Time
-		pathsToSync []string
+		changeLock sync.Mutex
+		dirty      bool
+		lastChange time.Time
+		batch      eventBatch
 	)
 
 	go func() {
@@ -86,6 +87,10 @@ func (m FsEvents) AnyEventCall(directory string, observer EventsObserver) error
 				if match == true {
 					cplogs.V(5).Infof("skipping %s %s as is in the exclusion list", desc, e.Path)
 					cplogs.Flush()
+					//a file renamed into an excluded path is gone for the sync
+					changeLock.Lock()
+					batch.closeRename()
+					changeLock.Unlock()
 					continue
 				} else {
 					cplogs.V(5).Infof("not skipping %s %s as was not in the exclusion list", desc, e.Path)
@@ -93,7 +98,20 @@ func (m FsEvents) AnyEventCall(directory string, observer EventsObserver) error
 				}
 
 				changeLock.Lock()
-				pathsToSync = append(pathsToSync, e.Path)
+				//fsevents flags both the old and the new path of a rename as renamed, the one that is still
+				//on disk is the new path
+				_, statErr := os.Lstat(fullPath)
+				exists := statErr == nil
+				switch {
+				case e.Flags&fsevents.ItemRenamed != 0 && !exists:
+					batch.renamedFrom(fullPath)
+				case e.Flags&fsevents.ItemRenamed != 0:
+					batch.created(fullPath)
+				case e.Flags&fsevents.ItemRemoved != 0 && !exists:
+					batch.remove(fullPath)
+				default:
+					batch.write(fullPath)
+				}
 				lastChange = time.Now()
 				dirty = true
 				changeLock.Unlock()
@@ -120,14 +138,13 @@ func (m FsEvents) AnyEventCall(directory string, observer EventsObserver) error
 		// set of changes (such as a local build in progress).
 		if dirty && time.Now().After(lastChange.Add(delay)) {
 			fmt.Println("Synchronizing filesystem changes...")
-			err = observer.OnLastChange(pathsToSync)
+			err = observer.OnLastChange(batch.flush())
 			if err != nil {
 				return err
 			}
 			fmt.Println("Done.")
 			cplogs.Flush()
 			dirty = false
-			pathsToSync = []string{}
 		}
 		changeLock.Unlock()
 		<-ticker.C
@@ -47,11 +47,11 @@ func (m FsWatch) AnyEventCall(directory string, observer EventsObserver) error {
 	// mutex as they are shared between goroutines to communicate
 	// sync state/events.
 	var (
-		changeLock  sync.Mutex
-		dirty       bool
-		lastChange  time.Time
-		watchError  error
-		pathsToSync []string
+		changeLock sync.Mutex
+		dirty      bool
+		lastChange time.Time
+		watchError error
+		batch      eventBatch
 	)
 
 	watcher, err := fsnotify.NewWatcher()
@@ -73,11 +73,22 @@ func (m FsWatch) AnyEventCall(directory string, observer EventsObserver) error {
 				if match == true {
 					cplogs.V(5).Infof("skipped %s(%s) as is in the exclusion list", event.Name, event.Op)
 					cplogs.Flush()
+					//a file renamed into an excluded path is gone for the sync
+					batch.closeRename()
 					changeLock.Unlock()
 					continue
 				}
 
-				pathsToSync = append(pathsToSync, event.Name)
+				switch {
+				case event.Op&fsnotify.Rename == fsnotify.Rename:
+					batch.renamedFrom(event.Name)
+				case event.Op&fsnotify.Remove == fsnotify.Remove:
+					batch.remove(event.Name)
+				case event.Op&fsnotify.Create == fsnotify.Create:
+					batch.created(event.Name)
+				default:
+					batch.write(event.Name)
+				}
 				lastChange = time.Now()
 				dirty = true
 				if event.Op&fsnotify.Remove == fsnotify.Remove {
@@ -126,14 +137,13 @@ func (m FsWatch) AnyEventCall(directory string, observer EventsObserver) error {
 		// set of changes (such as a local build in progress).
 		if dirty && time.Now().After(lastChange.Add(delay)) {
 			fmt.Println("Synchronizing filesystem changes...")
-			err = observer.OnLastChange(pathsToSync)
+			err = observer.OnLastChange(batch.flush())
 			if err != nil {
 				return err
 			}
 			fmt.Println("Done.")
 			cplogs.Flush()
 			dirty = false
-			pathsToSync = []string{}
 		}
 		changeLock.Unlock()
 		<-ticker.C
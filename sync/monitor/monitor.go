@@ -3,7 +3,7 @@ package monitor
 import "time"
 
 type EventsObserver interface {
-	OnLastChange([]string) error
+	OnLastChange([]PathEvent) error
 }
 
 type DirectoryMonitor interface {
@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//ExclusionPreset contains the exclusions of the files generated at runtime by a framework or a language tool chain
+type ExclusionPreset struct {
+	Name       string
+	Exclusions []string
+	//detect returns true when the project in the directory uses the framework
+	detect func(dir string) bool
+}
+
+//Detect returns true when the project in the directory uses the framework of the preset
+func (p ExclusionPreset) Detect(dir string) bool {
+	return p.detect != nil && p.detect(dir)
+}
+
+//ExclusionPresets returns the exclusion presets bundled with the tool
+func ExclusionPresets() []ExclusionPreset {
+	return []ExclusionPreset{
+		{
+			Name: "symfony",
+			Exclusions: []string{
+				`/var/cache`,
+				`/var/log`,
+				`/var/logs`,
+				`/var/sessions`,
+				`/app/cache`,
+				`/app/logs`,
+			},
+			detect: func(dir string) bool {
+				return fileExists(dir, "symfony.lock") || composerRequires(dir, "symfony/symfony", "symfony/framework-bundle")
+			},
+		},
+		{
+			Name: "laravel",
+			Exclusions: []string{
+				`/storage/logs`,
+				`/storage/framework/cache`,
+				`/storage/framework/sessions`,
+				`/storage/framework/views`,
+				`/bootstrap/cache`,
+			},
+			detect: func(dir string) bool {
+				return fileExists(dir, "artisan") || composerRequires(dir, "laravel/framework")
+			},
+		},
+		{
+			Name: "drupal",
+			Exclusions: []string{
+				`/sites/*/files`,
+				`/web/sites/*/files`,
+				`/docroot/sites/*/files`,
+			},
+			detect: func(dir string) bool {
+				return fileExists(dir, "core/lib/Drupal.php") || fileExists(dir, "web/core/lib/Drupal.php") ||
+					composerRequires(dir, "drupal/core", "drupal/core-recommended", "drupal/drupal")
+			},
+		},
+		{
+			Name: "magento",
+			Exclusions: []string{
+				`/var/cache`,
+				`/var/page_cache`,
+				`/var/session`,
+				`/var/log`,
+				`/var/view_preprocessed`,
+				`/var/di`,
+				`/var/generation`,
+				`/generated`,
+				`/pub/static`,
+			},
+			detect: func(dir string) bool {
+				return fileExists(dir, "bin/magento") || composerRequires(dir, "magento/product-community-edition", "magento/product-enterprise-edition", "magento/magento2-base")
+			},
+		},
+		{
+			Name: "node",
+			Exclusions: []string{
+				`node_modules`,
+				`npm-debug.log*`,
+				`yarn-error.log`,
+			},
+			detect: func(dir string) bool {
+				return fileExists(dir, "package.json")
+			},
+		},
+		{
+			Name: "python",
+			Exclusions: []string{
+				`__pycache__`,
+				`*.py[co]`,
+				`/venv`,
+				`/env`,
+				`*.egg-info`,
+			},
+			detect: func(dir string) bool {
+				return fileExists(dir, "requirements.txt") || fileExists(dir, "setup.py") || fileExists(dir, "Pipfile") || fileExists(dir, "pyproject.toml")
+			},
+		},
+	}
+}
+
+//FindExclusionPreset returns the preset with the given name, the name is case insensitive
+func FindExclusionPreset(name string) (ExclusionPreset, bool) {
+	for _, preset := range ExclusionPresets() {
+		if strings.EqualFold(preset.Name, name) {
+			return preset, true
+		}
+	}
+	return ExclusionPreset{}, false
+}
+
+//ExclusionPresetNames returns the names of the bundled presets
+func ExclusionPresetNames() (names []string) {
+	for _, preset := range ExclusionPresets() {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+func fileExists(dir string, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+//composerRequires returns true if the composer.json file of the project requires one of the packages
+func composerRequires(dir string, packages ...string) bool {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "composer.json"))
+	if err != nil {
+		return false
+	}
+	composer := struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}{}
+	if err := json.Unmarshal(content, &composer); err != nil {
+		return false
+	}
+	for _, p := range packages {
+		if _, ok := composer.Require[p]; ok {
+			return true
+		}
+		if _, ok := composer.RequireDev[p]; ok {
+			return true
+		}
+	}
+	return false
+}
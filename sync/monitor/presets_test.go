@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExclusionPreset_Detect(t *testing.T) {
+	scenarios := []struct {
+		files       map[string]string
+		description string
+		expected    []string
+	}{
+		{
+			map[string]string{"composer.json": `{"require": {"symfony/symfony": "3.2.*"}}`},
+			"symfony project",
+			[]string{"symfony"},
+		},
+		{
+			map[string]string{"composer.json": `{"require": {"php": ">=7.0"}, "require-dev": {"laravel/framework": "5.4.*"}}`, "package.json": `{}`},
+			"laravel project with node dependencies",
+			[]string{"laravel", "node"},
+		},
+		{
+			map[string]string{"web/core/lib/Drupal.php": ""},
+			"drupal project",
+			[]string{"drupal"},
+		},
+		{
+			map[string]string{"bin/magento": ""},
+			"magento project",
+			[]string{"magento"},
+		},
+		{
+			map[string]string{"requirements.txt": "django"},
+			"python project",
+			[]string{"python"},
+		},
+		{
+			map[string]string{"composer.json": `not valid json`},
+			"invalid composer file",
+			nil,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		dir, err := ioutil.TempDir("", "exclusion-presets")
+		assert.Nil(t, err)
+		for file, content := range scenario.files {
+			target := filepath.Join(dir, filepath.FromSlash(file))
+			assert.Nil(t, os.MkdirAll(filepath.Dir(target), 0755))
+			assert.Nil(t, ioutil.WriteFile(target, []byte(content), 0644))
+		}
+
+		var detected []string
+		for _, preset := range ExclusionPresets() {
+			if preset.Detect(dir) {
+				detected = append(detected, preset.Name)
+			}
+		}
+		assert.Equal(t, scenario.expected, detected, scenario.description)
+		os.RemoveAll(dir)
+	}
+}
@@ -1,7 +1,57 @@
 package options
 
+import "time"
+
+//strategies used to find the files that changed since the last sync
+const (
+	//ChangeDetectionChecksum compares the checksum of the local and remote files
+	ChangeDetectionChecksum = "checksum"
+	//ChangeDetectionSizeMtime compares the size and the modification time of the local and remote files
+	ChangeDetectionSizeMtime = "size-mtime"
+	//ChangeDetectionHashCache sends the files whose content changed since the last push according to a local hash cache
+	ChangeDetectionHashCache = "hash-cache"
+)
+
+//ChangeDetectionStrategies returns the supported change detection strategies
+func ChangeDetectionStrategies() []string {
+	return []string{ChangeDetectionChecksum, ChangeDetectionSizeMtime, ChangeDetectionHashCache}
+}
+
+//locations of the backup of the remote files deleted or overwritten by a sync with delete
+const (
+	//BackupPod keeps the backup in the pod
+	BackupPod = "pod"
+	//BackupLocal fetches the backup in the user home directory
+	BackupLocal = "local"
+	//BackupNone doesn't backup the remote files
+	BackupNone = "none"
+)
+
+//BackupModes returns the supported backup locations
+func BackupModes() []string {
+	return []string{BackupPod, BackupLocal, BackupNone}
+}
+
 type SyncOptions struct {
 	KubeConfigKey, Environment, Pod, RemoteProjectPath string
 	IndividualFileSyncThreshold                        int
-	Verbose, DryRun, Delete                            bool
+	Verbose, DryRun, Delete, UseGitIgnore              bool
+	ChangeDetection                                    string
+	//Container is the container of the pod that is synced, the default container of the pod when empty
+	Container string
+	//Service is the service of the pod, the backups stored locally are kept apart by environment and service
+	Service string
+	//Parallel is the number of concurrent transfers used by a full sync
+	Parallel int
+	Transfer TransferOptions
+	//KeepAlive keeps the transport open between the syncs until the syncer is closed
+	KeepAlive bool
+	//Backup is where the remote files that a sync with delete removes or overwrites are backed up
+	Backup string
+	//MaxBackups is the number of backups kept where they are stored, the older ones are removed, all are kept when 0
+	MaxBackups int
+	//Files restricts a full sync to these files, relative to the project, when it is not empty
+	Files []string
+	//ModifiedSince restricts a fetch to the remote files modified after this time when it is not zero
+	ModifiedSince time.Time
 }
@@ -0,0 +1,32 @@
+package options
+
+import (
+	"fmt"
+	"time"
+)
+
+//the layouts of the absolute times accepted by ParseSince, in the local time zone when it is not given
+var sinceLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+//ParseSince returns the time of a duration before now (e.g. 30m, 2h) or of a date with an optional time
+//(e.g. 2017-06-12, 2017-06-12 15:30)
+func ParseSince(since string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(since); err == nil {
+		if duration <= 0 {
+			return time.Time{}, fmt.Errorf("the duration %s is not positive", since)
+		}
+		return now.Add(-duration), nil
+	}
+	for _, layout := range sinceLayouts {
+		if t, err := time.ParseInLocation(layout, since, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s", since)
+}
@@ -0,0 +1,31 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2017, 6, 12, 15, 30, 0, 0, time.UTC)
+	scenarios := []struct {
+		since    string
+		expected time.Time
+		valid    bool
+	}{
+		{"30m", time.Date(2017, 6, 12, 15, 0, 0, 0, time.UTC), true},
+		{"2h", time.Date(2017, 6, 12, 13, 30, 0, 0, time.UTC), true},
+		{"2017-06-10", time.Date(2017, 6, 10, 0, 0, 0, 0, time.UTC), true},
+		{"2017-06-10 08:15", time.Date(2017, 6, 10, 8, 15, 0, 0, time.UTC), true},
+		{"2017-06-10T08:15:00+02:00", time.Date(2017, 6, 10, 6, 15, 0, 0, time.UTC), true},
+		{"-1h", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, scenario := range scenarios {
+		since, err := ParseSince(scenario.since, now)
+		assert.Equal(t, scenario.valid, err == nil, scenario.since)
+		assert.True(t, scenario.expected.Equal(since), scenario.since)
+	}
+}
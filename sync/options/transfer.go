@@ -0,0 +1,91 @@
+package options
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+//policies applied to the symbolic links
+const (
+	//SymlinksPreserve transfers the symbolic links as symbolic links
+	SymlinksPreserve = "preserve"
+	//SymlinksCopy transfers the files and directories the symbolic links point to
+	SymlinksCopy = "copy"
+	//SymlinksSkip doesn't transfer the symbolic links
+	SymlinksSkip = "skip"
+)
+
+//SymlinkPolicies returns the supported symbolic link policies
+func SymlinkPolicies() []string {
+	return []string{SymlinksPreserve, SymlinksCopy, SymlinksSkip}
+}
+
+//transports used by rsync to reach the pod
+const (
+	//TransportRsh runs rsync inside the pod through kubectl exec for each transfer
+	TransportRsh = "rsh"
+	//TransportDaemon connects to an rsync daemon started in the pod through a port forward
+	TransportDaemon = "daemon"
+)
+
+//Transports returns the supported transports
+func Transports() []string {
+	return []string{TransportRsh, TransportDaemon}
+}
+
+//DefaultTransport returns the transport used when none is selected, the daemon on windows and rsh otherwise
+func DefaultTransport() string {
+	if runtime.GOOS == "windows" {
+		return TransportDaemon
+	}
+	return TransportRsh
+}
+
+//TransferOptions controls how rsync transfers the files when pushing, fetching and watching
+type TransferOptions struct {
+	//BandwidthLimit is the maximum transfer rate in KiB per second, a suffix sets the unit (e.g. 1.5m), empty for no limit
+	BandwidthLimit string
+	Compress       bool
+	//MaxFileSize is the size of the largest file transferred (e.g. 500k, 10M), empty for no limit
+	MaxFileSize string
+	Symlinks    string
+	Transport   string
+}
+
+var bandwidthFormat = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[kKmMgG]?$`)
+
+var sizeFormat = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)(?:([kKmMgG])([iI]?[bB])?|[bB])?$`)
+
+//ValidBandwidthLimit returns true if rsync accepts the value as bandwidth limit
+func ValidBandwidthLimit(limit string) bool {
+	return limit == "" || bandwidthFormat.MatchString(limit)
+}
+
+//ParseSize returns the number of bytes of a size with an optional suffix, like rsync the K, M and G suffixes, alone or
+//followed by iB, are powers of 1024 and the KB, MB and GB suffixes are powers of 1000
+func ParseSize(size string) (int64, error) {
+	match := sizeFormat.FindStringSubmatch(size)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	unit := float64(1024)
+	if strings.ToLower(match[3]) == "b" {
+		unit = 1000
+	}
+	switch strings.ToLower(match[2]) {
+	case "k":
+		value *= unit
+	case "m":
+		value *= unit * unit
+	case "g":
+		value *= unit * unit * unit
+	}
+	return int64(value), nil
+}
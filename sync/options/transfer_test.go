@@ -0,0 +1,34 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	scenarios := []struct {
+		size     string
+		expected int64
+		valid    bool
+	}{
+		{"100", 100, true},
+		{"500k", 500 * 1024, true},
+		{"10M", 10 * 1024 * 1024, true},
+		{"1.5G", 1536 * 1024 * 1024, true},
+		{"2kb", 2000, true},
+		{"2KiB", 2048, true},
+		{"1MB", 1000 * 1000, true},
+		{"1GiB", 1024 * 1024 * 1024, true},
+		{"100b", 100, true},
+		{"1ib", 0, false},
+		{"-1", 0, false},
+		{"10T", 0, false},
+		{"", 0, false},
+	}
+	for _, scenario := range scenarios {
+		size, err := ParseSize(scenario.size)
+		assert.Equal(t, scenario.valid, err == nil, scenario.size)
+		assert.Equal(t, scenario.expected, size, scenario.size)
+	}
+}
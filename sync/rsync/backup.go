@@ -0,0 +1,271 @@
+package rsync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+//RemoteBackupDir is the directory of the pod where the backups are stored
+const RemoteBackupDir = "/tmp/cp-remote-backups"
+
+//BackupExtension is the extension of the backup tarballs
+const BackupExtension = ".tar.gz"
+
+//archives the files listed on the standard input that exist, relative to the directory $2, in the tarball $1/$3,
+//an existing tarball is not overwritten
+const remoteCreateBackup = `set -C; mkdir -p "$1" && cd "$2" && while IFS= read -r f; do if [ -e "$f" ]; then printf '%s\n' "$f"; fi; done | tar -czf - -T - > "$1/$3"`
+
+//removes the tarballs of the directory $1 but the $2 most recent ones
+const remotePruneBackups = `cd "$1" 2>/dev/null || exit 0; ls -1 | grep '\.tar\.gz$' | sort -r | tail -n +"$(($2 + 1))" | while IFS= read -r f; do rm -f "$f"; done`
+
+//backupIDLayout is the layout of the time identifying the backups, the milliseconds keep apart the backups of a watch
+const backupIDLayout = "20060102-150405.000"
+
+//LocalBackupDir returns the directory where the backups fetched from the pods of the service of the environment are
+//stored, the backups of the other environments are neither listed, restored nor pruned with them
+func LocalBackupDir(environment, service string) (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cp-remote", "backups", environment, service), nil
+}
+
+//remoteBackup saves the remote files that a sync with delete removes or overwrites before the sync runs
+type remoteBackup struct {
+	spawner           kexec.Spawner
+	kscmd             kexec.KSCommand
+	remoteProjectPath string
+	mode              string
+	writer            io.Writer
+	//service is the service of the pod, the local backups are stored in a directory of the environment and the service
+	service string
+	//keep is the number of backups kept where the backup is stored, the older ones are removed, all are kept when 0
+	keep int
+}
+
+//lastBackupID is the last identifier given to a backup by this process
+var lastBackupID = struct {
+	sync.Mutex
+	id    string
+	count int
+}{}
+
+//newBackupID returns an identifier of the time that no other backup of this process has, a counter is added to the
+//identifiers of the backups created in the same millisecond
+func newBackupID(now time.Time) string {
+	lastBackupID.Lock()
+	defer lastBackupID.Unlock()
+	id := now.Format(backupIDLayout)
+	if strings.HasPrefix(lastBackupID.id, id) {
+		lastBackupID.count++
+		id = fmt.Sprintf("%s-%d", id, lastBackupID.count)
+	} else {
+		lastBackupID.count = 1
+	}
+	lastBackupID.id = id
+	return id
+}
+
+func newRemoteBackup(kubeConfigKey, environment, pod, container, remoteProjectPath, mode string) *remoteBackup {
+	b := &remoteBackup{}
+	b.spawner = kexec.NewLocal()
+	b.kscmd.KubeConfigKey = kubeConfigKey
+	b.kscmd.Environment = environment
+	b.kscmd.Pod = pod
+	b.kscmd.Container = container
+	b.kscmd.Stderr = ioutil.Discard
+	b.remoteProjectPath = remoteProjectPath
+	b.mode = mode
+	b.writer = os.Stdout
+	return b
+}
+
+//backupNeeded returns true when the sync removes the remote files that don't exist locally
+func backupNeeded(mode string, delete bool, dryRun bool) bool {
+	return delete && !dryRun && mode != "" && mode != options.BackupNone
+}
+
+//changedRemoteFiles runs the sync in dry run mode and returns the remote files it would delete or overwrite,
+//dir is the directory of the sync relative to the remote project
+func changedRemoteFiles(args []string, dir string, dryRunSync func(args []string, stdOut io.Writer) error) ([]string, error) {
+	out := &bytes.Buffer{}
+	lArgs := append(append([]string{}, args...), "--dry-run", "--itemize-changes")
+	err := dryRunSync(lArgs, out)
+	if err != nil {
+		return nil, err
+	}
+	files := parseItemizedChanges(out.String())
+	if dir != "." && dir != "" {
+		for key, file := range files {
+			files[key] = path.Join(filepath.ToSlash(dir), file)
+		}
+	}
+	return files, nil
+}
+
+//parseItemizedChanges returns the files that are deleted or updated in the rsync --itemize-changes output,
+//the files created by the sync and the directories are left out
+func parseItemizedChanges(out string) []string {
+	var files []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		item, file := fields[0], strings.TrimLeft(fields[1], " ")
+		if file == "" || strings.HasSuffix(file, "/") {
+			continue
+		}
+		switch {
+		case item == "*deleting":
+			files = append(files, file)
+		case len(item) >= 9 && (item[0] == '<' || item[0] == '>') && item[1] == 'f' && !strings.Contains(item, "+"):
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+//save archives the files in a tarball of the pod or of the user home directory, depending on the backup mode
+func (b remoteBackup) save(files []string) error {
+	if len(files) == 0 {
+		cplogs.V(5).Infoln("no remote files to backup")
+		cplogs.Flush()
+		return nil
+	}
+	id := newBackupID(time.Now())
+	name := id + BackupExtension
+
+	kscmd := b.kscmd
+	kscmd.Stdin = strings.NewReader(strings.Join(files, "\n") + "\n")
+	_, err := b.spawner.CommandExec(kscmd, "sh", "-c", remoteCreateBackup, "sh", RemoteBackupDir, b.remoteProjectPath, name)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when creating the backup %s in the pod %s", name, b.kscmd.Pod)).String())
+	}
+	cplogs.V(5).Infof("backed up %d remote files in %s/%s", len(files), RemoteBackupDir, name)
+	cplogs.Flush()
+
+	location := fmt.Sprintf("%s/%s in the pod %s", RemoteBackupDir, name, b.kscmd.Pod)
+	if b.mode == options.BackupLocal {
+		location, err = b.fetch(name)
+		if err != nil {
+			return err
+		}
+	}
+	b.prune()
+	fmt.Fprintf(b.writer, msgs.BackupCreated, len(files), id, location)
+	fmt.Fprintf(b.writer, msgs.BackupRestoreHint, config.AppName, id)
+	return nil
+}
+
+//fetch moves the tarball of the pod in the local backup directory
+func (b remoteBackup) fetch(name string) (string, error) {
+	remotePath := RemoteBackupDir + "/" + name
+	out, err := b.spawner.CommandExec(b.kscmd, "base64", remotePath)
+	if err != nil {
+		return "", errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when fetching the backup %s", remotePath)).String())
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.NewReplacer("\r", "", "\n", "").Replace(out))
+	if err != nil {
+		return "", errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when decoding the backup %s", remotePath)).String())
+	}
+
+	dir, err := LocalBackupDir(b.kscmd.Environment, b.service)
+	if err != nil {
+		return "", errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when getting the local backup directory").String())
+	}
+	localPath := filepath.Join(dir, name)
+	err = os.MkdirAll(dir, 0700)
+	if err == nil {
+		err = writeNewFile(localPath, content)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when writing the backup %s", localPath)).String())
+	}
+
+	_, err = b.spawner.CommandExec(b.kscmd, "rm", "-f", remotePath)
+	if err != nil {
+		cplogs.V(4).Infof("the backup %s could not be removed from the pod: %s", remotePath, err)
+		cplogs.Flush()
+	}
+	return localPath, nil
+}
+
+//writeNewFile writes the content in a file that doesn't exist yet
+func writeNewFile(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+//prune removes the oldest backups of the pod or of the local backup directory so that only keep backups remain
+func (b remoteBackup) prune() {
+	if b.keep <= 0 {
+		return
+	}
+	var err error
+	if b.mode == options.BackupLocal {
+		var dir string
+		dir, err = LocalBackupDir(b.kscmd.Environment, b.service)
+		if err == nil {
+			err = pruneLocalBackups(dir, b.keep)
+		}
+	} else {
+		_, err = b.spawner.CommandExec(b.kscmd, "sh", "-c", remotePruneBackups, "sh", RemoteBackupDir, fmt.Sprint(b.keep))
+	}
+	if err != nil {
+		cplogs.V(4).Infof("the oldest backups could not be removed: %s", err)
+		cplogs.Flush()
+	}
+}
+
+//pruneLocalBackups removes the tarballs of the directory but the keep most recent ones
+func pruneLocalBackups(dir string, keep int) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), BackupExtension) {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for i := keep; i < len(names); i++ {
+		if err := os.Remove(filepath.Join(dir, names[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
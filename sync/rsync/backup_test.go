@@ -0,0 +1,105 @@
+package rsync
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseItemizedChanges(t *testing.T) {
+	scenarios := []struct {
+		output      string
+		description string
+		expected    []string
+	}{
+		{
+			"sending incremental file list\n*deleting   uploads/avatar.png\n<f.st...... config/app.yml\n<f+++++++++ src/new.go\n\nsent 1,024 bytes  received 32 bytes\n",
+			"the deleted and the updated files are listed, the new files are not",
+			[]string{"uploads/avatar.png", "config/app.yml"},
+		},
+		{
+			"*deleting   var/cache/\n*deleting   var/cache/a.php\ncd+++++++++ web/\n.d..t...... src/\n",
+			"the directories are left out",
+			[]string{"var/cache/a.php"},
+		},
+		{
+			"<f..t.... old format.txt\r\n",
+			"the shorter item codes of the older rsync versions and the file names with spaces are supported",
+			[]string{"old format.txt"},
+		},
+		{
+			"sending incremental file list\n",
+			"nothing is listed when no remote file changes",
+			nil,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		assert.Equal(t, scenario.expected, parseItemizedChanges(scenario.output), scenario.description)
+	}
+}
+
+func TestChangedRemoteFiles(t *testing.T) {
+	var receivedArgs []string
+	files, err := changedRemoteFiles([]string{"-rptDv", "--delete"}, "src/app", func(args []string, stdOut io.Writer) error {
+		receivedArgs = args
+		_, err := io.WriteString(stdOut, "*deleting   removed.go\n<f.st...... main.go\n")
+		return err
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-rptDv", "--delete", "--dry-run", "--itemize-changes"}, receivedArgs)
+	assert.Equal(t, []string{"src/app/removed.go", "src/app/main.go"}, files)
+}
+
+func TestBackupNeeded(t *testing.T) {
+	assert.True(t, backupNeeded(options.BackupPod, true, false))
+	assert.True(t, backupNeeded(options.BackupLocal, true, false))
+	assert.False(t, backupNeeded(options.BackupNone, true, false), "the backup is disabled")
+	assert.False(t, backupNeeded(options.BackupPod, false, false), "nothing is deleted without --delete")
+	assert.False(t, backupNeeded(options.BackupPod, true, true), "a dry run doesn't change the remote files")
+}
+
+func TestNewBackupID(t *testing.T) {
+	now := time.Date(2017, 6, 1, 10, 0, 0, 123456789, time.UTC)
+	assert.Equal(t, "20170601-100000.123", newBackupID(now))
+	assert.Equal(t, "20170601-100000.123-2", newBackupID(now), "the backups of the same millisecond have distinct identifiers")
+	assert.Equal(t, "20170601-100000.124", newBackupID(now.Add(time.Millisecond)))
+}
+
+func TestPruneLocalBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backups")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"20170601-100000.000.tar.gz", "20170601-100001.000.tar.gz", "20170601-100002.000.tar.gz", "notes.txt"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	assert.Nil(t, pruneLocalBackups(dir, 2))
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"20170601-100001.000.tar.gz", "20170601-100002.000.tar.gz", "notes.txt"}, names)
+
+	assert.NotNil(t, writeNewFile(filepath.Join(dir, "notes.txt"), nil), "an existing file is not overwritten")
+}
+
+func TestLocalBackupDir(t *testing.T) {
+	dir, err := LocalBackupDir("project-a-dev", "web")
+	assert.Nil(t, err)
+	other, err := LocalBackupDir("project-b-dev", "web")
+	assert.Nil(t, err)
+
+	assert.True(t, strings.HasSuffix(dir, filepath.Join("backups", "project-a-dev", "web")))
+	assert.NotEqual(t, dir, other, "the backups of the environments are stored apart")
+}
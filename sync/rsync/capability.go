@@ -0,0 +1,210 @@
+package rsync
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	msgs "github.com/continuouspipe/remote-environment-client/messages"
+	"github.com/continuouspipe/remote-environment-client/osapi"
+	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/pkg/errors"
+)
+
+//the options used to push and fetch (--omit-dir-times, --files-from) require rsync 2.6.4, the first release
+//using the protocol version 29
+const (
+	minRsyncProtocol = 29
+	minRsyncVersion  = "2.6.4"
+)
+
+//printed by the remote probe when rsync can't be found in the container
+const rsyncNotFoundMarker = "cp-remote-rsync-not-found"
+
+const remoteRsyncProbe = `rsync --version 2>/dev/null || echo ` + rsyncNotFoundMarker
+
+var (
+	rsyncVersionFormat  = regexp.MustCompile(`rsync\s+version\s+v?([0-9][0-9A-Za-z.]*)`)
+	rsyncProtocolFormat = regexp.MustCompile(`protocol version\s+([0-9]+)`)
+)
+
+//CapabilityError is returned when rsync is missing or too old locally or in the pod, the message explains
+//how to fix it
+type CapabilityError struct {
+	message *cperrors.StatefulErrorMessage
+}
+
+func newCapabilityError(msg string) CapabilityError {
+	return CapabilityError{cperrors.NewStatefulErrorMessage(http.StatusBadRequest, msg)}
+}
+
+func (e CapabilityError) Error() string {
+	return e.message.String()
+}
+
+//Suggestion returns the message without the error code
+func (e CapabilityError) Suggestion() string {
+	return e.message.Message
+}
+
+//rsyncVersion is the release and the protocol version reported by rsync --version
+type rsyncVersion struct {
+	Version  string
+	Protocol int
+}
+
+//parseRsyncVersion reads the output of rsync --version, the protocol is 0 when it is not reported
+func parseRsyncVersion(out string) rsyncVersion {
+	version := rsyncVersion{Version: "unknown"}
+	if match := rsyncVersionFormat.FindStringSubmatch(out); match != nil {
+		version.Version = match[1]
+	}
+	if match := rsyncProtocolFormat.FindStringSubmatch(out); match != nil {
+		version.Protocol, _ = strconv.Atoi(match[1])
+	}
+	return version
+}
+
+//supported returns false only when the protocol is known to be older than the required one
+func (v rsyncVersion) supported() bool {
+	return v.Protocol == 0 || v.Protocol >= minRsyncProtocol
+}
+
+//capabilityChecker verifies that rsync is available locally and in the pods before transferring files, the results
+//are kept for the life of the process only: a pod of a stateful set keeps its name when it is recreated with another
+//image
+type capabilityChecker struct {
+	spawner      kexec.Spawner
+	localVersion func() (string, error)
+	podImage     func(kscmd kexec.KSCommand) string
+	local        *rsyncVersion
+	pods         map[string]rsyncVersion
+}
+
+var capabilities = newCapabilityChecker()
+
+func newCapabilityChecker() *capabilityChecker {
+	c := &capabilityChecker{}
+	c.spawner = kexec.NewLocal()
+	c.localVersion = localRsyncVersion
+	c.podImage = podImage
+	c.pods = map[string]rsyncVersion{}
+	return c
+}
+
+//capabilityCommand returns the command used to probe the rsync of the pod of the options
+func capabilityCommand(syncOptions options.SyncOptions) kexec.KSCommand {
+	kscmd := kexec.KSCommand{}
+	kscmd.KubeConfigKey = syncOptions.KubeConfigKey
+	kscmd.Environment = syncOptions.Environment
+	kscmd.Pod = syncOptions.Pod
+	kscmd.Container = syncOptions.Container
+	return kscmd
+}
+
+//checkRsync returns a CapabilityError when rsync is missing or too old locally or in the pod of the command
+func checkRsync(kscmd kexec.KSCommand) error {
+	return capabilities.check(kscmd)
+}
+
+func (c *capabilityChecker) check(kscmd kexec.KSCommand) error {
+	if err := c.checkLocal(); err != nil {
+		return err
+	}
+	return c.checkPod(kscmd)
+}
+
+func (c *capabilityChecker) checkLocal() error {
+	if c.local == nil {
+		out, err := c.localVersion()
+		if _, ok := err.(*exec.Error); ok {
+			return newCapabilityError(fmt.Sprintf(msgs.RsyncNotFoundLocally, minRsyncVersion))
+		}
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when checking the local rsync version").String())
+		}
+		version := parseRsyncVersion(out)
+		cplogs.V(5).Infof("local rsync version %s, protocol %d", version.Version, version.Protocol)
+		cplogs.Flush()
+		c.local = &version
+	}
+	if !c.local.supported() {
+		return newCapabilityError(fmt.Sprintf(msgs.RsyncTooOldLocally, c.local.Version, c.local.Protocol, minRsyncProtocol, minRsyncVersion))
+	}
+	return nil
+}
+
+//checkPod probes the rsync of the pod, only the supported versions are cached so that a missing rsync is
+//detected again once the pod is replaced
+func (c *capabilityChecker) checkPod(kscmd kexec.KSCommand) error {
+	target := kscmd.KubeConfigKey + "/" + kscmd.Environment + "/" + kscmd.Pod
+	if kscmd.Container != "" {
+		target += "/" + kscmd.Container
+	}
+	if c.pods == nil {
+		c.pods = map[string]rsyncVersion{}
+	}
+	if _, ok := c.pods[target]; ok {
+		return nil
+	}
+
+	probe := kscmd
+	probe.Stdin = nil
+	probe.Stdout = nil
+	probe.Stderr = ioutil.Discard
+	out, err := c.spawner.CommandExec(probe, "sh", "-c", remoteRsyncProbe)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when checking the rsync version of the pod %s", kscmd.Pod)).String())
+	}
+	if strings.Contains(out, rsyncNotFoundMarker) {
+		return newCapabilityError(fmt.Sprintf(msgs.RsyncNotFoundInPod, kscmd.Pod, c.podImage(kscmd), minRsyncVersion))
+	}
+	version := parseRsyncVersion(out)
+	cplogs.V(5).Infof("rsync version %s, protocol %d in the pod %s", version.Version, version.Protocol, kscmd.Pod)
+	cplogs.Flush()
+	if !version.supported() {
+		return newCapabilityError(fmt.Sprintf(msgs.RsyncTooOldInPod, version.Version, kscmd.Pod, c.podImage(kscmd), version.Protocol, minRsyncProtocol, minRsyncVersion))
+	}
+
+	c.pods[target] = version
+	return nil
+}
+
+func localRsyncVersion() (string, error) {
+	scmd := osapi.SCommand{}
+	scmd.Name = "rsync"
+	return osapi.CommandExec(scmd, "--version")
+}
+
+//podImage returns the image of the container where the commands are executed, the first one of the pod when no
+//container is selected
+func podImage(kscmd kexec.KSCommand) string {
+	scmd := osapi.SCommand{}
+	scmd.Name = config.AppName
+	image, err := osapi.CommandExec(scmd,
+		config.KubeCtlName,
+		"--context="+kscmd.KubeConfigKey,
+		"--namespace="+kscmd.Environment,
+		"get", "pod", kscmd.Pod,
+		"-o", "jsonpath="+podImageJSONPath(kscmd.Container))
+	if err != nil || image == "" {
+		return "unknown"
+	}
+	return image
+}
+
+//podImageJSONPath returns the kubectl JSONPath of the image of the container, of the first container when it is empty
+func podImageJSONPath(container string) string {
+	if container == "" {
+		return "{.spec.containers[0].image}"
+	}
+	return fmt.Sprintf(`{.spec.containers[?(@.name=="%s")].image}`, container)
+}
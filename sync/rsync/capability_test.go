@@ -0,0 +1,99 @@
+package rsync
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSpawner struct {
+	out   string
+	calls int
+}
+
+func (f *fakeSpawner) CommandExec(kscmd kexec.KSCommand, execCmdArgs ...string) (string, error) {
+	f.calls++
+	return f.out, nil
+}
+
+func newFakeCapabilityChecker(localOut string, localErr error, remoteOut string) (*capabilityChecker, *fakeSpawner) {
+	spawner := &fakeSpawner{out: remoteOut}
+	c := &capabilityChecker{}
+	c.spawner = spawner
+	c.localVersion = func() (string, error) { return localOut, localErr }
+	c.podImage = func(kscmd kexec.KSCommand) string { return "php:7.1" }
+	return c, spawner
+}
+
+func TestParseRsyncVersion(t *testing.T) {
+	tests := []struct {
+		out      string
+		expected rsyncVersion
+	}{
+		{"rsync  version 3.1.2  protocol version 31\nCopyright (C) 1996-2015 by Andrew Tridgell", rsyncVersion{"3.1.2", 31}},
+		{"rsync  version 2.6.9  protocol version 29", rsyncVersion{"2.6.9", 29}},
+		{"rsync  version v3.2.7  protocol version 31", rsyncVersion{"3.2.7", 31}},
+		{"openrsync: protocol version 29\nrsync version 2.6.9 compatible", rsyncVersion{"2.6.9", 29}},
+		{"something else", rsyncVersion{"unknown", 0}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseRsyncVersion(test.out), test.out)
+	}
+}
+
+func TestCapabilityChecker_Check(t *testing.T) {
+	kscmd := kexec.KSCommand{KubeConfigKey: "env", Environment: "env", Pod: "web-1"}
+	tests := []struct {
+		scenario           string
+		localOut           string
+		localErr           error
+		remoteOut          string
+		expectedSuggestion string
+		expectedErr        bool
+	}{
+		{"supported on both sides", "rsync  version 3.1.2  protocol version 31", nil, "rsync  version 3.1.1  protocol version 31", "", false},
+		{"missing locally", "", &exec.Error{Name: "rsync", Err: exec.ErrNotFound}, "", "rsync is not installed on this machine or is not in the PATH.\nPlease install rsync 2.6.4 or newer and try again.", true},
+		{"local probe failure", "", errors.New("exit status 1"), "", "", true},
+		{"too old locally", "rsync  version 2.6.3  protocol version 28", nil, "", "The rsync 2.6.3 installed on this machine uses the protocol version 28, the protocol version 29 (rsync 2.6.4) or newer is required.\nPlease upgrade rsync on this machine and try again.", true},
+		{"missing in the pod", "rsync  version 3.1.2  protocol version 31", nil, rsyncNotFoundMarker, "rsync is not installed in the pod web-1, which runs the image php:7.1.\nPlease add rsync 2.6.4 or newer to the image and deploy the environment again.", true},
+		{"too old in the pod", "rsync  version 3.1.2  protocol version 31", nil, "rsync  version 2.6.3  protocol version 28", "The rsync 2.6.3 installed in the pod web-1, which runs the image php:7.1, uses the protocol version 28, the protocol version 29 (rsync 2.6.4) or newer is required.\nPlease upgrade rsync in the image and deploy the environment again.", true},
+	}
+	for _, test := range tests {
+		c, _ := newFakeCapabilityChecker(test.localOut, test.localErr, test.remoteOut)
+		err := c.check(kscmd)
+		if !test.expectedErr {
+			assert.Nil(t, err, test.scenario)
+			continue
+		}
+		assert.NotNil(t, err, test.scenario)
+		capabilityErr, ok := err.(CapabilityError)
+		if test.expectedSuggestion == "" {
+			assert.False(t, ok, test.scenario)
+			continue
+		}
+		assert.True(t, ok, test.scenario)
+		assert.Equal(t, test.expectedSuggestion, capabilityErr.Suggestion(), test.scenario)
+	}
+}
+
+func TestCapabilityChecker_CachesThePodsWithASupportedRsync(t *testing.T) {
+	c, spawner := newFakeCapabilityChecker("rsync  version 3.1.2  protocol version 31", nil, rsyncNotFoundMarker)
+	kscmd := kexec.KSCommand{KubeConfigKey: "env", Environment: "env", Pod: "web-1"}
+
+	assert.NotNil(t, c.check(kscmd))
+	assert.NotNil(t, c.check(kscmd))
+	assert.Equal(t, 2, spawner.calls, "a missing rsync is probed again")
+
+	spawner.out = "rsync  version 3.1.2  protocol version 31"
+	assert.Nil(t, c.check(kscmd))
+	assert.Nil(t, c.check(kscmd))
+	assert.Equal(t, 3, spawner.calls, "a supported rsync is probed once")
+}
+
+func TestPodImageJSONPath(t *testing.T) {
+	assert.Equal(t, "{.spec.containers[0].image}", podImageJSONPath(""))
+	assert.Equal(t, `{.spec.containers[?(@.name=="nginx")].image}`, podImageJSONPath("nginx"))
+}
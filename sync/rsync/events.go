@@ -0,0 +1,366 @@
+package rsync
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
+	"github.com/pkg/errors"
+)
+
+//moves the file or directory $1 to $2, replacing $2 if it exists
+const remoteMove = `mkdir -p "$(dirname "$2")" && rm -rf "$2" && mv -f "$1" "$2"`
+
+//removes the files and directories given as arguments
+const remoteRemove = `rm -rf -- "$@"`
+
+type pathMove struct {
+	from, to string
+}
+
+//eventPlan contains the remote operations required to apply a batch of events
+type eventPlan struct {
+	moves    []pathMove
+	removals []string
+	writes   []string
+}
+
+//planEvents coalesces the events in the operations to apply remotely: a path written and then moved is only written
+//to its new path, a path moved and then removed is removed from its old path, a removed path that exists again
+//is written and a moved path that doesn't exist anymore is removed
+func planEvents(events []monitor.PathEvent, exists func(path string) bool) eventPlan {
+	plan := eventPlan{}
+	for _, event := range events {
+		switch event.Op {
+		case monitor.EventWrite:
+			plan.writes = appendOnce(plan.writes, event.Path)
+		case monitor.EventRemove:
+			plan.writes = without(plan.writes, event.Path)
+			if i := plan.moveTo(event.Path); i >= 0 {
+				plan.removals = appendOnce(plan.removals, plan.moves[i].from)
+				plan.moves = append(plan.moves[:i], plan.moves[i+1:]...)
+				continue
+			}
+			plan.removals = appendOnce(plan.removals, event.Path)
+		case monitor.EventRename:
+			plan.removals = without(plan.removals, event.Path)
+			if contains(plan.writes, event.OldPath) {
+				plan.writes = appendOnce(without(plan.writes, event.OldPath), event.Path)
+				continue
+			}
+			if i := plan.moveTo(event.OldPath); i >= 0 {
+				plan.moves[i].to = event.Path
+				continue
+			}
+			plan.moves = append(plan.moves, pathMove{from: event.OldPath, to: event.Path})
+		}
+	}
+
+	var removals []string
+	for _, removed := range plan.removals {
+		if exists(removed) {
+			plan.writes = appendOnce(plan.writes, removed)
+			continue
+		}
+		removals = append(removals, removed)
+	}
+	plan.removals = removals
+
+	var moves []pathMove
+	for _, move := range plan.moves {
+		if !exists(move.to) {
+			plan.removals = appendOnce(plan.removals, move.from)
+			continue
+		}
+		moves = append(moves, move)
+	}
+	plan.moves = moves
+	return plan
+}
+
+func (p eventPlan) moveTo(to string) int {
+	for i, move := range p.moves {
+		if move.to == to {
+			return i
+		}
+	}
+	return -1
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func appendOnce(list []string, value string) []string {
+	if contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+func without(list []string, value string) []string {
+	res := list[:0]
+	for _, v := range list {
+		if v != value {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func localPathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+//remoteFiles moves and removes the project files inside the pod
+type remoteFiles struct {
+	executor          kexec.Executor
+	kscmd             kexec.KSCommand
+	remoteProjectPath string
+	verbose, dryRun   bool
+	//delete is true when the sync removes the remote files that don't exist locally, without it the renames and
+	//removals are only synced like the writes
+	delete bool
+	//protected returns true for the project paths, relative and slash separated, that the sync never removes
+	protected func(path string) bool
+	//backup saves the remote project paths before they are removed or replaced
+	backup func(paths []string) error
+}
+
+//applyEvents moves and removes the remote files following the local renames and removals, the written paths and the
+//new paths of the renames are then given to sync. A rename may be paired with a file created after a move out of the
+//project, the moved file is then updated by sync with the delta of the local one
+func (r remoteFiles) applyEvents(events []monitor.PathEvent, sync func(paths []string) error) error {
+	plan := planEvents(events, localPathExists)
+	cplogs.V(5).Infof("events plan, moves: %v, removals: %v, writes: %v", plan.moves, plan.removals, plan.writes)
+	cplogs.Flush()
+
+	plan, err := r.filterPlan(plan)
+	if err != nil {
+		return err
+	}
+
+	err = r.backupPlan(plan)
+	if err != nil {
+		return err
+	}
+
+	for _, move := range plan.moves {
+		err := r.move(move.from, move.to)
+		if err != nil {
+			cplogs.V(4).Infof("moving %s to %s remotely failed, syncing %s instead: %s", move.from, move.to, move.to, err.Error())
+			cplogs.Flush()
+			plan.removals = appendOnce(plan.removals, move.from)
+		}
+		plan.writes = appendOnce(plan.writes, move.to)
+	}
+
+	if len(plan.removals) > 0 {
+		err = r.remove(plan.removals)
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when removing the remote files").String())
+		}
+	}
+
+	if len(plan.writes) == 0 {
+		return nil
+	}
+	return sync(plan.writes)
+}
+
+//filterPlan leaves to sync the renames and the removals that a sync wouldn't apply: without delete nothing is removed
+//remotely and a renamed path is only written, the protected paths are neither removed nor replaced and a rename
+//from or to a protected path is a removal of the old path and a write of the new one
+func (r remoteFiles) filterPlan(plan eventPlan) (eventPlan, error) {
+	if !r.delete {
+		for _, move := range plan.moves {
+			plan.writes = appendOnce(plan.writes, move.to)
+		}
+		plan.moves = nil
+		plan.removals = nil
+		return plan, nil
+	}
+
+	isProtected := func(localPath string) (bool, error) {
+		if r.protected == nil {
+			return false, nil
+		}
+		rel, err := r.relativePath(localPath)
+		if err != nil {
+			return false, err
+		}
+		return r.protected(rel), nil
+	}
+
+	var moves []pathMove
+	for _, move := range plan.moves {
+		fromProtected, err := isProtected(move.from)
+		if err != nil {
+			return plan, err
+		}
+		toProtected, err := isProtected(move.to)
+		if err != nil {
+			return plan, err
+		}
+		if fromProtected || toProtected {
+			plan.removals = appendOnce(plan.removals, move.from)
+			plan.writes = appendOnce(plan.writes, move.to)
+			continue
+		}
+		moves = append(moves, move)
+	}
+	plan.moves = moves
+
+	var removals []string
+	for _, removal := range plan.removals {
+		protected, err := isProtected(removal)
+		if err != nil {
+			return plan, err
+		}
+		if protected {
+			cplogs.V(5).Infof("the path %s is protected and is not removed remotely", removal)
+			cplogs.Flush()
+			continue
+		}
+		removals = append(removals, removal)
+	}
+	plan.removals = removals
+	return plan, nil
+}
+
+//backupPlan backs up the remote paths that are removed and the ones that the renamed paths replace
+func (r remoteFiles) backupPlan(plan eventPlan) error {
+	if r.backup == nil || r.dryRun {
+		return nil
+	}
+	var paths []string
+	for _, localPath := range append(append([]string{}, plan.removals...), movesTo(plan.moves)...) {
+		rel, err := r.relativePath(localPath)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return r.backup(paths)
+}
+
+//protectedPaths returns the function telling if the push exclusions protect a project path from the deletion,
+//a path that can't be matched is protected
+func protectedPaths(useGitIgnore bool) (func(path string) bool, error) {
+	matcher, err := NewExclusionMatcher(false, useGitIgnore)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the push exclusions").String())
+	}
+	return func(path string) bool {
+		protected, _, err := matcher.HasMatchAndIsProtected(path)
+		if err != nil {
+			cplogs.V(4).Infof("error when matching the path %s to the push exclusions, details %s", path, err.Error())
+			cplogs.Flush()
+			return true
+		}
+		return protected
+	}, nil
+}
+
+func movesTo(moves []pathMove) []string {
+	var to []string
+	for _, move := range moves {
+		to = append(to, move.to)
+	}
+	return to
+}
+
+func (r remoteFiles) move(from string, to string) error {
+	remoteFrom, err := r.remotePath(from)
+	if err != nil {
+		return err
+	}
+	remoteTo, err := r.remotePath(to)
+	if err != nil {
+		return err
+	}
+	if r.verbose || r.dryRun {
+		fmt.Printf("moving %s to %s\n", remoteFrom, remoteTo)
+	}
+	if r.dryRun {
+		return nil
+	}
+	return r.execute(remoteMove, remoteFrom, remoteTo)
+}
+
+func (r remoteFiles) remove(paths []string) error {
+	var remotePaths []string
+	for _, p := range paths {
+		remotePath, err := r.remotePath(p)
+		if err != nil {
+			return err
+		}
+		if r.verbose || r.dryRun {
+			fmt.Printf("deleting %s\n", remotePath)
+		}
+		remotePaths = append(remotePaths, remotePath)
+	}
+	if r.dryRun {
+		return nil
+	}
+	return r.execute(remoteRemove, remotePaths...)
+}
+
+//execute runs the script inside the pod with the given positional arguments
+func (r remoteFiles) execute(script string, args ...string) error {
+	cplogs.V(5).Infof("Executing remotely script:\n%s\nwith arguments %s\n", script, args)
+	cplogs.Flush()
+	kscmd := r.kscmd
+	kscmd.Stdin = bytes.NewBufferString(script)
+	err := r.executor.StartProcess(kscmd, append([]string{"sh", "-s", "--"}, args...)...)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("error when executing remotely %s with arguments %s", script, args)).String())
+	}
+	return nil
+}
+
+//remotePath returns the path inside the pod of the local project path
+func (r remoteFiles) remotePath(localPath string) (string, error) {
+	rel, err := r.relativePath(localPath)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(r.remoteProjectPath, rel), nil
+}
+
+//relativePath returns the slash separated path of the local project path relative to the project directory
+func (r remoteFiles) relativePath(localPath string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "getting the current directory failed and is required for syncing").String())
+	}
+	if !filepath.IsAbs(localPath) {
+		localPath = string(filepath.Separator) + localPath
+	}
+	rel, err := filepath.Rel(cwd, localPath)
+	if err != nil {
+		return "", errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("getting the relative path using cwd %s and path %s failed", cwd, localPath)).String())
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", errors.New(cperrors.NewStatefulErrorMessage(http.StatusBadRequest, fmt.Sprintf("the path %s is not inside the project directory %s", localPath, cwd)).String())
+	}
+	return rel, nil
+}
@@ -0,0 +1,141 @@
+package rsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanEvents(t *testing.T) {
+	scenarios := []struct {
+		events      []monitor.PathEvent
+		existing    []string
+		description string
+		expected    eventPlan
+	}{
+		{
+			[]monitor.PathEvent{{Path: "b", Op: monitor.EventRename, OldPath: "a"}},
+			[]string{"b"},
+			"a rename is moved remotely",
+			eventPlan{moves: []pathMove{{from: "a", to: "b"}}},
+		},
+		{
+			[]monitor.PathEvent{
+				{Path: "b", Op: monitor.EventRename, OldPath: "a"},
+				{Path: "c", Op: monitor.EventRename, OldPath: "b"},
+			},
+			[]string{"c"},
+			"consecutive renames are a single move",
+			eventPlan{moves: []pathMove{{from: "a", to: "c"}}},
+		},
+		{
+			[]monitor.PathEvent{
+				{Path: "tmp", Op: monitor.EventWrite},
+				{Path: "a", Op: monitor.EventRename, OldPath: "tmp"},
+			},
+			[]string{"a"},
+			"a file written and renamed is only written to its new path",
+			eventPlan{writes: []string{"a"}},
+		},
+		{
+			[]monitor.PathEvent{
+				{Path: "b", Op: monitor.EventRename, OldPath: "a"},
+				{Path: "b", Op: monitor.EventRemove},
+			},
+			nil,
+			"a file renamed and removed is removed from its old path",
+			eventPlan{removals: []string{"a"}},
+		},
+		{
+			[]monitor.PathEvent{
+				{Path: "a", Op: monitor.EventRemove},
+				{Path: "b", Op: monitor.EventRemove},
+				{Path: "c", Op: monitor.EventWrite},
+			},
+			[]string{"b", "c"},
+			"a removed file that exists again is written",
+			eventPlan{removals: []string{"a"}, writes: []string{"c", "b"}},
+		},
+		{
+			[]monitor.PathEvent{{Path: "b", Op: monitor.EventRename, OldPath: "a"}},
+			nil,
+			"a rename whose new path doesn't exist anymore is a removal",
+			eventPlan{removals: []string{"a"}},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		exists := func(path string) bool {
+			return contains(scenario.existing, path)
+		}
+		plan := planEvents(scenario.events, exists)
+		assert.Equal(t, scenario.expected.moves, plan.moves, scenario.description)
+		assert.Equal(t, scenario.expected.removals, plan.removals, scenario.description)
+		assert.Equal(t, scenario.expected.writes, plan.writes, scenario.description)
+	}
+}
+
+func TestRemoteFiles_FilterPlan(t *testing.T) {
+	cwd, _ := os.Getwd()
+	abs := func(path string) string {
+		return filepath.Join(cwd, path)
+	}
+	plan := eventPlan{
+		moves:    []pathMove{{from: abs("a"), to: abs("b")}, {from: abs("c"), to: abs("vendor/c")}},
+		removals: []string{abs("d"), abs("vendor/e")},
+		writes:   []string{abs("f")},
+	}
+
+	filtered, err := remoteFiles{delete: false}.filterPlan(plan)
+	assert.Nil(t, err)
+	assert.Nil(t, filtered.moves, "without delete the renames are synced")
+	assert.Nil(t, filtered.removals, "without delete nothing is removed")
+	assert.Equal(t, []string{abs("f"), abs("b"), abs("vendor/c")}, filtered.writes)
+
+	protected := func(path string) bool {
+		return strings.HasPrefix(path, "vendor/")
+	}
+	plan.writes = []string{abs("f")}
+	filtered, err = remoteFiles{delete: true, protected: protected}.filterPlan(plan)
+	assert.Nil(t, err)
+	assert.Equal(t, []pathMove{{from: abs("a"), to: abs("b")}}, filtered.moves)
+	assert.Equal(t, []string{abs("d"), abs("c")}, filtered.removals, "the protected paths are not removed")
+	assert.Equal(t, []string{abs("f"), abs("vendor/c")}, filtered.writes, "a rename to a protected path is synced")
+}
+
+//recordingExecutor records the arguments of the remote scripts instead of executing them
+type recordingExecutor struct {
+	calls *[][]string
+}
+
+func (e recordingExecutor) StartProcess(kscmd kexec.KSCommand, execCmdArgs ...string) error {
+	*e.calls = append(*e.calls, execCmdArgs)
+	return nil
+}
+
+func TestRemoteFiles_ApplyEvents_PairedRename(t *testing.T) {
+	cwd, _ := os.Getwd()
+	created, err := ioutil.TempFile(cwd, "created")
+	assert.Nil(t, err)
+	created.Close()
+	defer os.Remove(created.Name())
+
+	//a moved out of the project followed by the creation of another file is reported as a rename
+	var calls [][]string
+	var synced []string
+	files := remoteFiles{executor: recordingExecutor{&calls}, remoteProjectPath: "/app", delete: true}
+	err = files.applyEvents([]monitor.PathEvent{{Path: created.Name(), Op: monitor.EventRename, OldPath: filepath.Join(cwd, "a")}}, func(paths []string) error {
+		synced = paths
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"sh", "-s", "--", "/app/a", "/app/" + filepath.Base(created.Name())}}, calls)
+	assert.Equal(t, []string{created.Name()}, synced, "the moved file is synced with the content of the new path")
+}
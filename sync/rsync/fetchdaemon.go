@@ -1,4 +1,3 @@
-// +build windows
 package rsync
 
 import (
@@ -65,23 +64,32 @@ ls ${PID}
 type RsyncDaemonFetch struct {
 	remoteRsync                                        *RemoteRsyncDeamon
 	kubeConfigKey, environment, pod, remoteProjectPath string
-	verbose, dryRun                                    bool
+	container                                          string
+	verbose, dryRun, delete, useGitIgnore              bool
+	transfer                                           options.TransferOptions
+	modifiedSince                                      time.Time
 }
 
 func (r *RsyncDaemonFetch) SetOptions(syncOptions options.SyncOptions) {
 	r.kubeConfigKey = syncOptions.KubeConfigKey
 	r.environment = syncOptions.Environment
 	r.pod = syncOptions.Pod
+	r.container = syncOptions.Container
 	r.remoteProjectPath = syncOptions.RemoteProjectPath
 	r.verbose = syncOptions.Verbose
 	r.dryRun = syncOptions.DryRun
+	r.delete = syncOptions.Delete
+	r.useGitIgnore = syncOptions.UseGitIgnore
+	r.transfer = syncOptions.Transfer
+	r.modifiedSince = syncOptions.ModifiedSince
 }
 
-func (r RsyncDaemonFetch) Fetch(filePath string) error {
+func (r RsyncDaemonFetch) Fetch(paths []string) error {
 	kscmd := kexec.KSCommand{}
 	kscmd.KubeConfigKey = r.kubeConfigKey
 	kscmd.Environment = r.environment
 	kscmd.Pod = r.pod
+	kscmd.Container = r.container
 	kscmd.Stderr = ioutil.Discard
 	kscmd.Stdout = ioutil.Discard
 	r.remoteRsync.SetKSCommand(kscmd)
@@ -99,12 +107,13 @@ func (r RsyncDaemonFetch) Fetch(filePath string) error {
 	defer r.remoteRsync.StopPortForward(stopChan)
 
 	args := []string{
-		"-zrlDv",
+		"-rDv",
 		"--omit-dir-times",
 		"--blocking-io",
 		"--force",
-		`--exclude=.git`,
 	}
+	args = append(args, builtInExclusionArgs()...)
+	args = append(args, transferArgs(r.transfer)...)
 
 	if r.verbose {
 		args = append(args, "--verbose")
@@ -117,23 +126,35 @@ func (r RsyncDaemonFetch) Fetch(filePath string) error {
 	if err != nil {
 		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "getting the current directory failed and is required for fetching").String())
 	}
-	//check if fetch excluded file exists, if it doesn't, don't return an error
-	if _, err := os.Stat(FetchExcluded); err == nil {
-		args = append(args, fmt.Sprintf(`--exclude-from=%s`, cwd+"/"+FetchExcluded))
-	}
-	//check if sync fetch excluded file exists, if it doesn't, don't return an error
-	if _, err := os.Stat(SyncFetchExcluded); err == nil {
-		args = append(args, fmt.Sprintf(`--exclude-from=%s`, cwd+"/"+SyncFetchExcluded))
+	for _, excluded := range []string{FetchExcluded, SyncFetchExcluded} {
+		filterArgs, err := ignoreFileFilterArgs(excluded)
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("reading the exclusions of %s failed", excluded)).String())
+		}
+		args = append(args, filterArgs...)
 	}
+	args = append(args, perDirectoryFilterArgs(r.useGitIgnore)...)
 
+	warnRemoteOversizedFiles(r.transfer, kscmd, r.remoteProjectPath, paths, r.useGitIgnore)
+
+	plan, err := newFetchPlan(kscmd, r.remoteProjectPath, paths, r.modifiedSince, r.delete, r.useGitIgnore)
+	if err != nil {
+		return err
+	}
+	defer plan.cleanup()
+	args = append(args, plan.args...)
+	if plan.filesFrom != "" {
+		args, err = nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+	}
+	args = append(args, plan.filesFromArg(convertWindowsPath)...)
 	args = append(args, "--")
 
-	if filePath == "" {
-		cplogs.V(5).Infoln("fetching all files")
-		args = append(args, r.remoteRsync.GetRsyncURL(rsyncConfigSection, r.remoteProjectPath))
-	} else {
-		cplogs.V(5).Infof("fetching specified file %s", filePath)
-		args = append(args, r.remoteRsync.GetRsyncURL(rsyncConfigSection, r.remoteProjectPath+filePath))
+	cplogs.V(5).Infof("fetching %s", plan.sources)
+	for _, source := range plan.sources {
+		args = append(args, r.remoteRsync.GetRsyncURL(rsyncConfigSection, source))
 	}
 
 	if runtime.GOOS == "windows" {
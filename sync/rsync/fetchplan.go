@@ -0,0 +1,135 @@
+package rsync
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/continuouspipe/remote-environment-client/util/shell"
+	"github.com/pkg/errors"
+)
+
+//lists the files of the remote project directory $1 modified less than $2 minutes ago in the given paths
+const remoteModifiedFiles = `cd "$1" || exit 1
+find %s -type f -mmin -"$2" 2>/dev/null
+exit 0`
+
+//ErrNothingModified is returned by a fetch of the files modified since a time when no remote file has been modified
+var ErrNothingModified = errors.New("no remote file has been modified")
+
+//fetchPlan is what a fetch transfers: the remote sources, relative to the root of the pod, and the rsync
+//arguments that select the files
+type fetchPlan struct {
+	args    []string
+	sources []string
+	//filesFrom is the temporary file listing the files to fetch, empty when the sources are fetched entirely
+	filesFrom string
+}
+
+//newFetchPlan returns the plan fetching the paths, which are relative to the remote project and can contain
+//wildcards, the whole project is fetched when paths is empty. When since is not zero only the files modified
+//after it are fetched, ErrNothingModified is returned when there are none
+func newFetchPlan(kscmd kexec.KSCommand, remoteProjectPath string, paths []string, since time.Time, delete bool, useGitIgnore bool) (*fetchPlan, error) {
+	plan := &fetchPlan{}
+	if delete {
+		plan.args = append(plan.args, "--delete")
+	}
+
+	if !since.IsZero() {
+		files, err := remoteModifiedSince(kscmd, remoteProjectPath, paths, since, useGitIgnore)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, ErrNothingModified
+		}
+		plan.filesFrom, err = writeFilesFrom(files)
+		if err != nil {
+			return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when writing the list of the modified remote files").String())
+		}
+		plan.sources = []string{remoteProjectPath}
+		return plan, nil
+	}
+
+	if len(paths) == 0 {
+		plan.sources = []string{remoteProjectPath}
+		return plan, nil
+	}
+
+	//the /./ marker makes rsync recreate the directories of the paths below the project directory
+	plan.args = append(plan.args, "--relative")
+	root := strings.TrimSuffix(remoteProjectPath, "/")
+	for _, p := range paths {
+		plan.sources = append(plan.sources, root+"/./"+strings.TrimPrefix(path.Clean(p), "/"))
+	}
+	return plan, nil
+}
+
+//filesFromArg returns the --files-from argument with the path converted by convert, empty when there is no files list
+func (p fetchPlan) filesFromArg(convert func(path string) string) []string {
+	if p.filesFrom == "" {
+		return nil
+	}
+	return []string{"--files-from=" + convert(p.filesFrom)}
+}
+
+//cleanup removes the temporary files list
+func (p fetchPlan) cleanup() {
+	if p.filesFrom != "" {
+		os.Remove(p.filesFrom)
+	}
+}
+
+//remoteModifiedSince lists the files of the paths modified remotely after since that are not excluded from the fetch,
+//relative to the remote project
+func remoteModifiedSince(kscmd kexec.KSCommand, remoteProjectPath string, paths []string, since time.Time, useGitIgnore bool) ([]string, error) {
+	matcher, err := NewExclusionMatcher(true, useGitIgnore)
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the fetch exclusions").String())
+	}
+	minutes := int(math.Ceil(time.Since(since).Minutes()))
+	if minutes < 1 {
+		minutes = 1
+	}
+
+	script := fmt.Sprintf(remoteModifiedFiles, remoteGlobs(paths))
+	out, err := kexec.NewLocal().CommandExec(kscmd, "sh", "-c", script, "sh", remoteProjectPath, strconv.Itoa(minutes))
+	if err != nil {
+		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the remote files modified recently").String())
+	}
+
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		file := path.Clean(strings.TrimSpace(line))
+		if file == "." || file == ".git" || strings.HasPrefix(file, ".git/") {
+			continue
+		}
+		if include, _, err := matcher.HasMatchAndIsIncluded(file); err == nil && include {
+			files = append(files, file)
+		}
+	}
+	cplogs.V(5).Infof("%d remote files modified in the last %d minutes", len(files), minutes)
+	cplogs.Flush()
+	return files, nil
+}
+
+//remoteGlobs returns the paths relative to the current directory quoted for the remote shell, the current directory
+//when paths is empty
+func remoteGlobs(paths []string) string {
+	if len(paths) == 0 {
+		return "."
+	}
+	words := make([]string, len(paths))
+	for i, p := range paths {
+		words[i] = shell.QuoteGlob("./" + strings.TrimPrefix(path.Clean(p), "/"))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,54 @@
+package rsync
+
+import (
+	"testing"
+	"time"
+
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFetchPlan(t *testing.T) {
+	scenarios := []struct {
+		paths           []string
+		delete          bool
+		description     string
+		expectedArgs    []string
+		expectedSources []string
+	}{
+		{
+			nil,
+			false,
+			"the whole project is fetched when no path is given",
+			nil,
+			[]string{"/app/"},
+		},
+		{
+			nil,
+			true,
+			"the local files are deleted in delete mode",
+			[]string{"--delete"},
+			[]string{"/app/"},
+		},
+		{
+			[]string{"vendor", "src/*.go", "./composer.lock"},
+			false,
+			"the paths keep their directories below the project",
+			[]string{"--relative"},
+			[]string{"/app/./vendor", "/app/./src/*.go", "/app/./composer.lock"},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		plan, err := newFetchPlan(kexec.KSCommand{}, "/app/", scenario.paths, time.Time{}, scenario.delete, false)
+		assert.Nil(t, err, scenario.description)
+		assert.Equal(t, scenario.expectedArgs, plan.args, scenario.description)
+		assert.Equal(t, scenario.expectedSources, plan.sources, scenario.description)
+		assert.Empty(t, plan.filesFromArg(convertWindowsPath), scenario.description)
+	}
+}
+
+func TestRemoteGlobs(t *testing.T) {
+	assert.Equal(t, ".", remoteGlobs(nil))
+	assert.Equal(t, `'./src/'*'.go' './it'\''s dir'`, remoteGlobs([]string{"src/*.go", "it's dir"}))
+}
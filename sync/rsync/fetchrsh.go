@@ -1,17 +1,17 @@
-// +build !windows
-
 package rsync
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
-	"path/filepath"
+	"runtime"
+	"time"
 
 	"net/http"
 
-	"github.com/continuouspipe/remote-environment-client/config"
 	"github.com/continuouspipe/remote-environment-client/cplogs"
 	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
 	"github.com/continuouspipe/remote-environment-client/osapi"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
 	"github.com/pkg/errors"
@@ -23,7 +23,10 @@ func init() {
 
 type RsyncRshFetch struct {
 	kubeConfigKey, environment, pod, remoteProjectPath string
-	verbose, dryRun                                    bool
+	container                                          string
+	verbose, dryRun, delete, useGitIgnore              bool
+	transfer                                           options.TransferOptions
+	modifiedSince                                      time.Time
 }
 
 func NewRsyncRshFetch() *RsyncRshFetch {
@@ -34,23 +37,29 @@ func (r *RsyncRshFetch) SetOptions(syncOptions options.SyncOptions) {
 	r.kubeConfigKey = syncOptions.KubeConfigKey
 	r.environment = syncOptions.Environment
 	r.pod = syncOptions.Pod
+	r.container = syncOptions.Container
 	r.remoteProjectPath = syncOptions.RemoteProjectPath
 	r.verbose = syncOptions.Verbose
 	r.dryRun = syncOptions.DryRun
+	r.delete = syncOptions.Delete
+	r.useGitIgnore = syncOptions.UseGitIgnore
+	r.transfer = syncOptions.Transfer
+	r.modifiedSince = syncOptions.ModifiedSince
 }
 
-func (r RsyncRshFetch) Fetch(filePath string) error {
-	rsh := fmt.Sprintf(`%s %s --context=%s --namespace=%s exec -i %s`, config.AppName, config.KubeCtlName, r.kubeConfigKey, r.environment, r.pod)
+func (r RsyncRshFetch) Fetch(paths []string) error {
+	rsh := rshCommand(r.kubeConfigKey, r.environment, r.pod, r.container)
 	os.Setenv("RSYNC_RSH", rsh)
 	defer os.Unsetenv("RSYNC_RSH")
 	cplogs.V(5).Infof("setting RSYNC_RSH to %s\n", rsh)
 
 	args := []string{
-		"-zrlptDv",
+		"-rptDv",
 		"--blocking-io",
 		"--force",
-		`--exclude=.git`,
 	}
+	args = append(args, builtInExclusionArgs()...)
+	args = append(args, transferArgs(r.transfer)...)
 
 	if r.verbose {
 		args = append(args, "--verbose")
@@ -63,23 +72,46 @@ func (r RsyncRshFetch) Fetch(filePath string) error {
 	if err != nil {
 		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "cannot fetch without knowing the cwd").String())
 	}
-	if _, err := os.Stat(FetchExcluded); err == nil {
-		args = append(args, fmt.Sprintf(`--exclude-from=%s`, cwd+string(filepath.Separator)+FetchExcluded))
-	}
-	if _, err := os.Stat(SyncFetchExcluded); err == nil {
-		args = append(args, fmt.Sprintf(`--exclude-from=%s`, cwd+string(filepath.Separator)+SyncFetchExcluded))
+	for _, excluded := range []string{FetchExcluded, SyncFetchExcluded} {
+		filterArgs, err := ignoreFileFilterArgs(excluded)
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("reading the exclusions of %s failed", excluded)).String())
+		}
+		args = append(args, filterArgs...)
 	}
+	args = append(args, perDirectoryFilterArgs(r.useGitIgnore)...)
 
+	kscmd := kexec.KSCommand{}
+	kscmd.KubeConfigKey = r.kubeConfigKey
+	kscmd.Environment = r.environment
+	kscmd.Pod = r.pod
+	kscmd.Container = r.container
+	kscmd.Stderr = ioutil.Discard
+	warnRemoteOversizedFiles(r.transfer, kscmd, r.remoteProjectPath, paths, r.useGitIgnore)
+
+	plan, err := newFetchPlan(kscmd, r.remoteProjectPath, paths, r.modifiedSince, r.delete, r.useGitIgnore)
+	if err != nil {
+		return err
+	}
+	defer plan.cleanup()
+	args = append(args, plan.args...)
+	if plan.filesFrom != "" {
+		args, err = nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+	}
+	args = append(args, plan.filesFromArg(func(path string) string { return path })...)
 	args = append(args, "--")
 
-	if filePath == "" {
-		cplogs.V(5).Infoln("fetching all files")
-		args = append(args, "--:"+r.remoteProjectPath)
-	} else {
-		cplogs.V(5).Infof("fetching specified file %s", filePath)
-		args = append(args, "--:"+r.remoteProjectPath+filePath)
+	cplogs.V(5).Infof("fetching %s", plan.sources)
+	for _, source := range plan.sources {
+		args = append(args, "--:"+source)
 	}
 
+	if runtime.GOOS == "windows" {
+		cwd = convertWindowsPath(cwd)
+	}
 	args = append(args, cwd)
 
 	cplogs.V(5).Infof("rsync arguments: %s", args)
@@ -0,0 +1,245 @@
+package rsync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+//changeDetectionArgs returns the rsync arguments that make rsync compare the files using the given strategy
+//
+//with the hash cache rsync receives the list of the changed files and compares their size and modification time,
+//the modification times need to be preserved for the comparison to skip the unchanged files
+func changeDetectionArgs(strategy string) []string {
+	switch strategy {
+	case options.ChangeDetectionSizeMtime, options.ChangeDetectionHashCache:
+		return []string{"--times"}
+	}
+	return []string{"--checksum"}
+}
+
+//hashCacheEntry is the state of a local file, the content hash is computed again only when the inode,
+//the size or the modification time of the file change
+type hashCacheEntry struct {
+	Inode   uint64 `json:"inode"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Hash    string `json:"hash"`
+	//Pushed is the hash of the content last pushed to the target
+	Pushed string `json:"pushed"`
+}
+
+//hashCache contains the content hash of the project files and of the ones pushed to a target (a pod and remote path)
+type hashCache struct {
+	file    string
+	Entries map[string]hashCacheEntry `json:"entries"`
+}
+
+//loadHashCache reads the hash cache of the current project for the target, the cache is stored in the user home
+//directory so that it is not synced with the project files
+func loadHashCache(target string) (*hashCache, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	key := sha1.Sum([]byte(cwd + "\n" + target))
+	cache := &hashCache{
+		file:    filepath.Join(home, ".cp-remote", "hash-cache", hex.EncodeToString(key[:])+".json"),
+		Entries: map[string]hashCacheEntry{},
+	}
+
+	content, err := ioutil.ReadFile(cache.file)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, cache); err != nil {
+		cplogs.V(4).Infof("discarding the invalid hash cache %s: %s", cache.file, err.Error())
+		cplogs.Flush()
+		cache.Entries = map[string]hashCacheEntry{}
+	}
+	return cache, nil
+}
+
+//changedFiles walks the project files that are not excluded and returns the ones whose content differs from
+//the content last pushed, the entries of the files that don't exist anymore are removed
+func (c *hashCache) changedFiles(matcher *pattern.RsyncMatcherPath) (changed []string, err error) {
+	entries := map[string]hashCacheEntry{}
+	err = WalkIncluded(".", matcher, func(path string, relPath string, info os.FileInfo) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		entry, cached := c.Entries[relPath]
+		inode := fileInode(info)
+		if !cached || entry.Inode != inode || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+			hash, err := fileHash(path)
+			if err != nil {
+				return err
+			}
+			entry = hashCacheEntry{Inode: inode, Size: info.Size(), ModTime: info.ModTime().UnixNano(), Hash: hash, Pushed: entry.Pushed}
+		}
+		entries[relPath] = entry
+		if entry.Hash != entry.Pushed {
+			changed = append(changed, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.Entries = entries
+	return changed, nil
+}
+
+//WalkIncluded walks the files and directories in root that are not excluded by the matcher,
+//fn receives the path, the project relative path in the slash separated form and the file info
+func WalkIncluded(root string, matcher *pattern.RsyncMatcherPath, fn func(path string, relPath string, info os.FileInfo) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == "." {
+			return nil
+		}
+		relPath := filepath.ToSlash(path)
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		include, _, err := matcher.HasMatchAndIsIncluded(relPath)
+		if err != nil {
+			return err
+		}
+		if !include {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(path, relPath, info)
+	})
+}
+
+//markPushed records that the current content of the files has been pushed to the target
+func (c *hashCache) markPushed(paths []string) {
+	for _, path := range paths {
+		if entry, ok := c.Entries[path]; ok {
+			entry.Pushed = entry.Hash
+			c.Entries[path] = entry
+		}
+	}
+}
+
+func (c *hashCache) save() error {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.file, content, 0600)
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//syncKnownChanges pushes only the files whose content changed since the last push to the target according to the
+//hash cache, run executes rsync with the file that lists the changed files given to --files-from
+func syncKnownChanges(target string, useGitIgnore bool, dryRun bool, run func(filesFrom string) error) error {
+	cache, err := loadHashCache(target)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the hash cache").String())
+	}
+	matcher, err := NewExclusionMatcher(false, useGitIgnore)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the push exclusions").String())
+	}
+	changed, err := cache.changedFiles(matcher)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when looking for the changed files").String())
+	}
+	cplogs.V(5).Infof("hash cache %s, %d changed files", cache.file, len(changed))
+	cplogs.Flush()
+
+	if len(changed) == 0 {
+		fmt.Println("No changes since the last push.")
+		return cache.save()
+	}
+
+	filesFrom, err := writeFilesFrom(changed)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when writing the list of the changed files").String())
+	}
+	defer os.Remove(filesFrom)
+
+	if err := run(filesFrom); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	cache.markPushed(changed)
+	return cache.save()
+}
+
+//syncListedFiles runs the sync with the files written in a temporary file given to the rsync --files-from option
+func syncListedFiles(files []string, run func(filesFrom string) error) error {
+	filesFrom, err := writeFilesFrom(files)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when writing the list of the files to sync").String())
+	}
+	defer os.Remove(filesFrom)
+	return run(filesFrom)
+}
+
+//writeFilesFrom writes the paths in a temporary file given to the rsync --files-from option, they are separated by NUL
+//characters so that any file name can be listed and rsync reads them with --from0
+func writeFilesFrom(paths []string) (string, error) {
+	f, err := ioutil.TempFile("", "cp-remote-files-from")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(strings.Join(paths, "\x00") + "\x00")
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+//hashCacheTarget identifies the remote files the hash cache refers to
+func hashCacheTarget(kubeConfigKey, environment, pod, remoteProjectPath string) string {
+	return strings.Join([]string{kubeConfigKey, environment, pod, remoteProjectPath}, "\n")
+}
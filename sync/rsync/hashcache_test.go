@@ -0,0 +1,45 @@
+package rsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashCache_ChangedFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "hash-cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(root))
+	defer os.Chdir(cwd)
+
+	for path, content := range map[string]string{"a.txt": "a", "src/b.txt": "b", "tmp/c.txt": "c", ".git/HEAD": "ref"} {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	matcher := pattern.NewRsyncMatcherPath()
+	matcher.AddPattern("- /tmp/")
+	cache := &hashCache{Entries: map[string]hashCacheEntry{}}
+
+	changed, err := cache.changedFiles(matcher)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.txt", "src/b.txt"}, changed, "the files never pushed are changed")
+
+	cache.markPushed(changed)
+	changed, err = cache.changedFiles(matcher)
+	assert.Nil(t, err)
+	assert.Empty(t, changed, "the pushed files are not changed")
+
+	assert.Nil(t, ioutil.WriteFile("src/b.txt", []byte("b2"), 0644))
+	assert.Nil(t, os.Remove("a.txt"))
+	changed, err = cache.changedFiles(matcher)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"src/b.txt"}, changed, "a modified file is changed")
+	assert.NotContains(t, cache.Entries, "a.txt", "a removed file is not in the cache anymore")
+}
@@ -0,0 +1,16 @@
+// +build !windows
+
+package rsync
+
+import (
+	"os"
+	"syscall"
+)
+
+//fileInode returns the inode number of the file
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
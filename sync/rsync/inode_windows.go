@@ -0,0 +1,9 @@
+package rsync
+
+import "os"
+
+//fileInode returns 0 as the file info doesn't contain the file index on windows,
+//the changes are detected using the size and the modification time
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,197 @@
+package rsync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/pkg/errors"
+)
+
+//each parallel transfer receives this many partitions on average, smaller partitions balance the transfers
+//and make the progress more accurate
+const partitionsPerTransfer = 4
+
+//partition is a set of top level directories transferred by a single rsync process
+type partition struct {
+	dirs []string
+	size int64
+}
+
+//partitionProject groups the top level directories of the project that are not excluded in at most count partitions
+//of similar size, each directory, starting from the largest, is added to the smallest partition
+func partitionProject(matcher *pattern.RsyncMatcherPath, count int) ([]partition, error) {
+	sizes := map[string]int64{}
+	err := WalkIncluded(".", matcher, func(path string, relPath string, info os.FileInfo) error {
+		topLevel := strings.SplitN(relPath, "/", 2)
+		if len(topLevel) == 1 {
+			//the directories are walked before their content
+			if info.IsDir() {
+				sizes[relPath] = 0
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			sizes[topLevel[0]] += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for dir := range sizes {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	sort.Stable(bySizeDesc{dirs, sizes})
+
+	if count > len(dirs) {
+		count = len(dirs)
+	}
+	partitions := make([]partition, count)
+	for _, dir := range dirs {
+		smallest := 0
+		for i := range partitions {
+			if partitions[i].size < partitions[smallest].size {
+				smallest = i
+			}
+		}
+		partitions[smallest].dirs = append(partitions[smallest].dirs, dir)
+		partitions[smallest].size += sizes[dir]
+	}
+	return partitions, nil
+}
+
+type bySizeDesc struct {
+	dirs  []string
+	sizes map[string]int64
+}
+
+func (s bySizeDesc) Len() int           { return len(s.dirs) }
+func (s bySizeDesc) Swap(i, j int)      { s.dirs[i], s.dirs[j] = s.dirs[j], s.dirs[i] }
+func (s bySizeDesc) Less(i, j int) bool { return s.sizes[s.dirs[i]] > s.sizes[s.dirs[j]] }
+
+//syncInParallel pushes the whole project using the given number of concurrent rsync processes
+//
+//a first rsync transfers the files in the project root and creates the top level directories without recursing in
+//them, with --delete it removes the remote top level files and directories that don't exist locally. The top level
+//directories are then split in partitions of similar size transferred concurrently, with --delete each rsync removes
+//the extraneous files inside the directories of its partition.
+//
+//filesFromArg returns the --files-from argument for the local list file, run executes rsync with the extra arguments
+func syncInParallel(parallel int, useGitIgnore bool, filesFromArg func(file string) string, run func(args []string, stdOut io.Writer) error) error {
+	matcher, err := NewExclusionMatcher(false, useGitIgnore)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when loading the push exclusions").String())
+	}
+	partitions, err := partitionProject(matcher, parallel*partitionsPerTransfer)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when splitting the project directories in partitions").String())
+	}
+
+	err = run([]string{"--no-recursive", "--dirs"}, os.Stdout)
+	if err != nil {
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing the project root directory").String())
+	}
+	if len(partitions) == 0 {
+		return nil
+	}
+
+	progress := newProgressBar(os.Stdout, partitions)
+	progress.render()
+
+	queue := make(chan partition, len(partitions))
+	for _, p := range partitions {
+		queue <- p
+	}
+	close(queue)
+
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		failed []string
+	)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				err := syncPartition(p, filesFromArg, run)
+				lock.Lock()
+				if err != nil {
+					failed = append(failed, fmt.Sprintf("%s (%s)", strings.Join(p.dirs, ", "), err.Error()))
+				}
+				progress.done(p)
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	progress.finish()
+
+	if len(failed) > 0 {
+		return errors.New(cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("rsync failed to sync the directories %s", strings.Join(failed, "; "))).String())
+	}
+	return nil
+}
+
+//syncPartition transfers the directories of the partition, the output of rsync is written in the logs
+//to not interleave the output of the concurrent transfers
+func syncPartition(p partition, filesFromArg func(file string) string, run func(args []string, stdOut io.Writer) error) error {
+	filesFrom, err := writeFilesFrom(p.dirs)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filesFrom)
+
+	out := &bytes.Buffer{}
+	err = run([]string{filesFromArg(filesFrom)}, out)
+	cplogs.V(5).Infof("rsync output for the directories %s:\n%s", p.dirs, out.String())
+	cplogs.Flush()
+	return err
+}
+
+//progressBar shows the share of the project size transferred by the completed partitions
+type progressBar struct {
+	writer                          io.Writer
+	total, transferred              int64
+	partitions, completedPartitions int
+}
+
+func newProgressBar(writer io.Writer, partitions []partition) *progressBar {
+	bar := &progressBar{writer: writer, partitions: len(partitions)}
+	for _, p := range partitions {
+		bar.total += p.size
+	}
+	return bar
+}
+
+func (b *progressBar) done(p partition) {
+	b.transferred += p.size
+	b.completedPartitions++
+	b.render()
+}
+
+func (b *progressBar) render() {
+	const width = 40
+	ratio := float64(b.completedPartitions) / float64(b.partitions)
+	if b.total > 0 {
+		ratio = float64(b.transferred) / float64(b.total)
+	}
+	filled := int(ratio * width)
+	fmt.Fprintf(b.writer, "\r[%s%s] %3d%% %d/%d partitions", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), int(ratio*100), b.completedPartitions, b.partitions)
+}
+
+func (b *progressBar) finish() {
+	fmt.Fprintln(b.writer)
+}
@@ -0,0 +1,88 @@
+package rsync
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionProject(t *testing.T) {
+	root, err := ioutil.TempDir("", "partition")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(root))
+	defer os.Chdir(cwd)
+
+	files := map[string]int{"vendor/a": 60, "vendor/b/c": 40, "src/a": 50, "web/a": 30, "docs/a": 10, "empty/": 0, "var/cache/a": 500, "README": 5}
+	for path, size := range files {
+		if strings.HasSuffix(path, "/") {
+			assert.Nil(t, os.MkdirAll(path, 0755))
+			continue
+		}
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, make([]byte, size), 0644))
+	}
+	matcher := pattern.NewRsyncMatcherPath()
+	matcher.AddPattern("- /var/")
+
+	partitions, err := partitionProject(matcher, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []partition{
+		{dirs: []string{"vendor"}, size: 100},
+		{dirs: []string{"src", "web", "docs", "empty"}, size: 90},
+	}, partitions)
+
+	partitions, err = partitionProject(matcher, 10)
+	assert.Nil(t, err)
+	assert.Len(t, partitions, 5, "there are not more partitions than top level directories")
+}
+
+func TestSyncInParallel_FailedPartition(t *testing.T) {
+	root, err := ioutil.TempDir("", "parallel")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(root))
+	defer os.Chdir(cwd)
+
+	for _, path := range []string{"vendor/a", "src/a", "web/a"} {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte("content"), 0644))
+	}
+
+	var lock sync.Mutex
+	var synced []string
+	filesFromArg := func(file string) string {
+		return file
+	}
+	err = syncInParallel(2, false, filesFromArg, func(args []string, stdOut io.Writer) error {
+		if args[0] == "--no-recursive" {
+			return nil
+		}
+		content, err := ioutil.ReadFile(args[0])
+		assert.Nil(t, err)
+		lock.Lock()
+		defer lock.Unlock()
+		synced = append(synced, strings.Split(strings.TrimSuffix(string(content), "\x00"), "\x00")...)
+		if strings.Contains(string(content), "vendor") {
+			return errors.New("exit status 23")
+		}
+		return nil
+	})
+
+	assert.NotNil(t, err, "the sync fails when one of the partitions fails")
+	assert.Contains(t, err.Error(), "vendor (exit status 23)")
+	assert.NotContains(t, err.Error(), "src")
+	assert.Len(t, synced, 3, "the other partitions are still synced")
+}
@@ -1,23 +1,51 @@
 package rsync
 
 import (
-	"runtime"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
 )
 
-//use rsync to fetch all the project files from the pod, or if the filePath is not empty it
-//fetch a specific file
+//use rsync to fetch all the project files from the pod, or if paths is not empty it
+//fetch the given files, directories and patterns
 type RsyncFetcher interface {
-	Fetch(string) error
+	Fetch(paths []string) error
 	SetOptions(syncOptions options.SyncOptions)
 }
 
 var RfetchRsh RsyncFetcher
 var RfetchDaemon RsyncFetcher
 
+//GetRfetch returns a fetcher that uses the transport selected in the options
 func GetRfetch() RsyncFetcher {
-	if runtime.GOOS == "windows" {
+	return &transportFetcher{selected: rfetchFor("")}
+}
+
+func rfetchFor(transport string) RsyncFetcher {
+	if transport == "" {
+		transport = options.DefaultTransport()
+	}
+	if transport == options.TransportDaemon {
 		return RfetchDaemon
 	}
 	return RfetchRsh
 }
+
+//transportFetcher delegates to the fetcher of the transport selected when setting the options, it checks that rsync
+//can be used locally and in the pod before fetching
+type transportFetcher struct {
+	selected RsyncFetcher
+	kscmd    kexec.KSCommand
+}
+
+func (t *transportFetcher) SetOptions(syncOptions options.SyncOptions) {
+	t.selected = rfetchFor(syncOptions.Transfer.Transport)
+	t.selected.SetOptions(syncOptions)
+	t.kscmd = capabilityCommand(syncOptions)
+}
+
+func (t *transportFetcher) Fetch(paths []string) error {
+	if err := checkRsync(t.kscmd); err != nil {
+		return err
+	}
+	return t.selected.Fetch(paths)
+}
@@ -1,8 +1,19 @@
 package rsync
 
 import (
-	"runtime"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/config"
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/continuouspipe/remote-environment-client/pattern"
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/pkg/errors"
 )
 
 //rsync exclusion file used when fetching and syncing
@@ -10,19 +21,188 @@ const SyncFetchExcluded = ".cp-remote-ignore"
 //rsync exclusion file used only when fetching
 const FetchExcluded = ".cp-remote-ignore-fetch"
 
+//BuiltInExclusionsSource is the source of the exclusions that every transfer gives to rsync before the rules of the
+//ignore files
+const BuiltInExclusionsSource = "the built-in exclusions"
+
+//builtInExclusions are the patterns excluded by every transfer
+var builtInExclusions = []string{".git"}
+
+func builtInExclusionArgs() (args []string) {
+	for _, exclusion := range builtInExclusions {
+		args = append(args, "--exclude="+exclusion)
+	}
+	return args
+}
+
+//rshCommand returns the remote shell that rsync uses to reach the pod, the command is executed in the container when it
+//is not empty
+func rshCommand(kubeConfigKey, environment, pod, container string) string {
+	rsh := fmt.Sprintf(`%s %s --context=%s --namespace=%s exec -i %s`, config.AppName, config.KubeCtlName, kubeConfigKey, environment, pod)
+	if container != "" {
+		rsh += " -c " + container
+	}
+	return rsh
+}
+
+//perDirectoryFilterArgs returns the filter rules that make rsync merge the exclusion files found in each transferred
+//directory, a nested file only contains exclude patterns that apply to its directory and sub-directories. rsync can't
+//read the negated patterns of the .gitignore files, their rules are translated from the files of the project instead
+func perDirectoryFilterArgs(useGitIgnore bool) (args []string) {
+	args = append(args, "--filter=:- "+SyncFetchExcluded)
+	if !useGitIgnore {
+		return args
+	}
+	rules, err := pattern.GitIgnoreFilterRules(".")
+	if err != nil {
+		cplogs.V(4).Infof("error when reading the .gitignore files, details %s", err.Error())
+		cplogs.Flush()
+		return append(args, "--filter=:- "+config.GitIgnore)
+	}
+	for _, rule := range rules {
+		args = append(args, "--filter="+rule)
+	}
+	return args
+}
+
+//nulSeparatedListArgs returns the arguments making rsync read the NUL separated list of paths written by
+//writeFilesFrom. --from0 also applies to the per-directory merge files, their rules are read from the project and
+//given inline instead
+func nulSeparatedListArgs(args []string) ([]string, error) {
+	var res []string
+	for _, arg := range args {
+		var rules []string
+		var err error
+		switch arg {
+		case "--filter=:- " + SyncFetchExcluded:
+			rules, err = pattern.DirMergeFilterRules(".", SyncFetchExcluded, pattern.ExcludeRules)
+		case "--filter=:- " + config.GitIgnore:
+			rules, err = pattern.GitIgnoreFilterRules(".")
+		default:
+			res = append(res, arg)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when reading the per-directory exclusion files").String())
+		}
+		for _, rule := range rules {
+			res = append(res, "--filter="+rule)
+		}
+	}
+	return append(res, "--from0"), nil
+}
+
+//ignoreFileFilterArgs returns the lines of the ignore file as filter rules, so that rsync and the watcher parse them
+//with the same grammar: a line that is not a valid filter rule is an exclude pattern. A missing file has no rules
+func ignoreFileFilterArgs(file string) ([]string, error) {
+	lines, err := ignoreFileLines(file)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, rule := range pattern.FilterRules(lines...) {
+		args = append(args, "--filter="+rule)
+	}
+	return args, nil
+}
+
+//ignoreFileLines returns the lines of the ignore file, a missing file doesn't have any line
+func ignoreFileLines(file string) ([]string, error) {
+	if _, err := os.Stat(file); err != nil {
+		return nil, nil
+	}
+	ignore := config.NewIgnore()
+	ignore.File = file
+	if err := ignore.LoadFromIgnoreFile(); err != nil {
+		return nil, err
+	}
+	lines := make([]string, len(ignore.List))
+	for i, line := range ignore.List {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, nil
+}
+
+//NewExclusionMatcher returns a matcher that uses the same exclusions given to rsync when pushing the files or, when
+//fetch is true, when fetching them. The decisions of the matcher report the ignore file that contains the rule
+func NewExclusionMatcher(fetch bool, useGitIgnore bool) (*pattern.RsyncMatcherPath, error) {
+	files := []string{SyncFetchExcluded}
+	if fetch {
+		files = []string{FetchExcluded, SyncFetchExcluded}
+	}
+	matcher := pattern.NewRsyncMatcherPath()
+	for _, exclusion := range builtInExclusions {
+		matcher.AddPatternFrom(BuiltInExclusionsSource, "- "+exclusion)
+	}
+	for _, file := range files {
+		lines, err := ignoreFileLines(file)
+		if err != nil {
+			return nil, err
+		}
+		matcher.AddPatternFrom(file, lines...)
+	}
+	matcher.AddPatternFrom("", monitor.PerDirectoryRules(useGitIgnore)...)
+	return matcher, nil
+}
 
 //use rsync to sync the files specified in filePaths. When filePaths is an empty slice, it syncs all project files
+//Close releases the transport kept open between the syncs
 type RsyncSyncer interface {
 	Sync(paths []string) error
+	SyncEvents(events []monitor.PathEvent) error
 	SetOptions(syncOptions options.SyncOptions)
+	Close() error
 }
 
 var RsyncRsh RsyncSyncer
 var RsyncDaemon RsyncSyncer
 
+//GetRsync returns a syncer that uses the transport selected in the options
 func GetRsync() RsyncSyncer {
-	if runtime.GOOS == "windows" {
+	return &transportSyncer{selected: rsyncSyncerFor("")}
+}
+
+func rsyncSyncerFor(transport string) RsyncSyncer {
+	if transport == "" {
+		transport = options.DefaultTransport()
+	}
+	if transport == options.TransportDaemon {
 		return RsyncDaemon
 	}
 	return RsyncRsh
 }
+
+//transportSyncer delegates to the syncer of the transport selected when setting the options, it checks that rsync
+//can be used locally and in the pod before syncing
+type transportSyncer struct {
+	selected RsyncSyncer
+	kscmd    kexec.KSCommand
+}
+
+func (t *transportSyncer) SetOptions(syncOptions options.SyncOptions) {
+	syncer := rsyncSyncerFor(syncOptions.Transfer.Transport)
+	if syncer != t.selected {
+		t.selected.Close()
+	}
+	t.selected = syncer
+	t.selected.SetOptions(syncOptions)
+	t.kscmd = capabilityCommand(syncOptions)
+}
+
+func (t *transportSyncer) Sync(paths []string) error {
+	if err := checkRsync(t.kscmd); err != nil {
+		return err
+	}
+	return t.selected.Sync(paths)
+}
+
+func (t *transportSyncer) SyncEvents(events []monitor.PathEvent) error {
+	if err := checkRsync(t.kscmd); err != nil {
+		return err
+	}
+	return t.selected.SyncEvents(events)
+}
+
+func (t *transportSyncer) Close() error {
+	return t.selected.Close()
+}
@@ -0,0 +1,77 @@
+package rsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
+	"github.com/continuouspipe/remote-environment-client/sync/options"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSyncer struct {
+	synced, closed int
+}
+
+func (f *fakeSyncer) Sync(paths []string) error                   { f.synced++; return nil }
+func (f *fakeSyncer) SyncEvents(events []monitor.PathEvent) error { return nil }
+func (f *fakeSyncer) SetOptions(syncOptions options.SyncOptions)  {}
+func (f *fakeSyncer) Close() error                                { f.closed++; return nil }
+
+func TestTransportSyncer_SetOptions(t *testing.T) {
+	rsh, daemon := &fakeSyncer{}, &fakeSyncer{}
+	defer func(rsh, daemon RsyncSyncer) { RsyncRsh, RsyncDaemon = rsh, daemon }(RsyncRsh, RsyncDaemon)
+	RsyncRsh, RsyncDaemon = rsh, daemon
+	defer func(c *capabilityChecker) { capabilities = c }(capabilities)
+	capabilities, _ = newFakeCapabilityChecker("rsync  version 3.1.2  protocol version 31", nil, "rsync  version 3.1.2  protocol version 31")
+
+	syncer := &transportSyncer{selected: rsh}
+	syncer.SetOptions(options.SyncOptions{Transfer: options.TransferOptions{Transport: options.TransportDaemon}})
+	assert.Nil(t, syncer.Sync(nil))
+	assert.Equal(t, 1, daemon.synced, "the daemon transport is used")
+	assert.Equal(t, 1, rsh.closed, "the previous transport is closed")
+
+	syncer.SetOptions(options.SyncOptions{Transfer: options.TransferOptions{Transport: options.TransportRsh}})
+	assert.Nil(t, syncer.Sync(nil))
+	assert.Nil(t, syncer.Close())
+	assert.Equal(t, 1, rsh.synced, "the rsh transport is used")
+	assert.Equal(t, 1, daemon.closed)
+	assert.Equal(t, 2, rsh.closed)
+}
+
+func TestNewExclusionMatcher_BuiltInExclusions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exclusions")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(dir)
+
+	matcher, err := NewExclusionMatcher(false, false)
+	assert.Nil(t, err)
+	decision, err := matcher.Explain(".git/config")
+	assert.Nil(t, err)
+	assert.False(t, decision.Included, "the .git directory is never synced")
+	assert.Equal(t, BuiltInExclusionsSource, decision.Source)
+
+	decision, err = matcher.Explain("src/app.php")
+	assert.Nil(t, err)
+	assert.True(t, decision.Included)
+}
+
+func TestNulSeparatedListArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesfrom")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(dir)
+	os.MkdirAll("web", 0755)
+	ioutil.WriteFile(filepath.Join("web", SyncFetchExcluded), []byte("/uploads\n"), 0644)
+
+	args, err := nulSeparatedListArgs([]string{"-rptDv", "--filter=- *.log", "--filter=:- " + SyncFetchExcluded})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-rptDv", "--filter=- *.log", "--filter=- /web/uploads", "--from0"}, args, "the per-directory files are not read by rsync with --from0")
+}
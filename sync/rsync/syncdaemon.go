@@ -1,4 +1,3 @@
-// +build windows
 package rsync
 
 import (
@@ -9,11 +8,13 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 
 	"github.com/continuouspipe/remote-environment-client/cplogs"
 	cperrors "github.com/continuouspipe/remote-environment-client/errors"
 	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
 	"github.com/continuouspipe/remote-environment-client/osapi"
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
 	"github.com/continuouspipe/remote-environment-client/util/slice"
 	"github.com/pkg/errors"
@@ -25,13 +26,28 @@ func init() {
 
 type RSyncDaemon struct {
 	kubeConfigKey, environment, pod, remoteProjectPath string
+	container                                          string
 	individualFileSyncThreshold                        int
 	remoteRsync                                        *RemoteRsyncDeamon
-	verbose, dryRun, delete                            bool
+	verbose, dryRun, delete, useGitIgnore              bool
+	transfer                                           options.TransferOptions
+	changeDetection                                    string
+	parallel                                           int
+	backup                                             string
+	maxBackups                                         int
+	service                                            string
+	files                                              []string
+	keepAlive                                          bool
+	//the daemon and the port forward started for the target (context, namespace, pod and container), empty when they are stopped
+	daemonTarget string
+	stopChan     *chan bool
+	//lock serialises the syncs and Close, which may be called from another goroutine when the watch is stopped
+	lock *sync.Mutex
 }
 
 func NewRSyncDaemon() *RSyncDaemon {
 	d := &RSyncDaemon{}
+	d.lock = &sync.Mutex{}
 	d.remoteRsync = NewRemoteRsyncDeamon()
 	return d
 }
@@ -40,40 +56,105 @@ func (r *RSyncDaemon) SetOptions(syncOptions options.SyncOptions) {
 	r.kubeConfigKey = syncOptions.KubeConfigKey
 	r.environment = syncOptions.Environment
 	r.pod = syncOptions.Pod
+	r.container = syncOptions.Container
 	r.individualFileSyncThreshold = syncOptions.IndividualFileSyncThreshold
 	r.remoteProjectPath = syncOptions.RemoteProjectPath
 	r.verbose = syncOptions.Verbose
 	r.dryRun = syncOptions.DryRun
+	r.useGitIgnore = syncOptions.UseGitIgnore
+	r.transfer = syncOptions.Transfer
 	r.delete = syncOptions.Delete
+	r.changeDetection = syncOptions.ChangeDetection
+	r.parallel = syncOptions.Parallel
+	r.backup = syncOptions.Backup
+	r.maxBackups = syncOptions.MaxBackups
+	r.service = syncOptions.Service
+	r.files = syncOptions.Files
+	r.keepAlive = syncOptions.KeepAlive
 }
 
 func (r *RSyncDaemon) Sync(paths []string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.syncWithDaemon(paths)
+}
+
+//syncWithDaemon starts the daemon if needed and syncs the paths, the lock must be held
+func (r *RSyncDaemon) syncWithDaemon(paths []string) error {
+	err := r.startDaemon()
+	if err != nil {
+		return err
+	}
+	if !r.keepAlive {
+		defer r.close()
+	}
+
+	err = r.sync(paths)
+	if err != nil && r.keepAlive {
+		//the pod may have been restarted, the next sync starts a new daemon
+		r.close()
+	}
+	return err
+}
+
+//startDaemon starts the rsync daemon in the pod and forwards a local port to it, when the daemon is kept alive
+//between the syncs it is started only if it is not already running for the pod
+func (r *RSyncDaemon) startDaemon() error {
+	target := r.kubeConfigKey + "/" + r.environment + "/" + r.pod + "/" + r.container
+	if r.daemonTarget == target {
+		return nil
+	}
+	r.close()
+
 	kscmd := kexec.KSCommand{}
 	kscmd.KubeConfigKey = r.kubeConfigKey
 	kscmd.Environment = r.environment
 	kscmd.Pod = r.pod
+	kscmd.Container = r.container
 	kscmd.Stderr = ioutil.Discard
 	kscmd.Stdout = ioutil.Discard
 	r.remoteRsync.SetKSCommand(kscmd)
 
 	err := r.remoteRsync.StartDaemonOnRandomPort()
 	if err != nil {
-		errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "start daemon on random port failed").String())
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "start daemon on random port failed").String())
 	}
-	defer r.remoteRsync.KillDaemon(pidFile)
 
 	stopChan, err := r.remoteRsync.StartPortForwardOnRandomPort()
 	if err != nil {
+		r.remoteRsync.KillDaemon(pidFile)
 		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "start port forward on random port failed").String())
 	}
-	defer r.remoteRsync.StopPortForward(stopChan)
+	r.stopChan = stopChan
+	r.daemonTarget = target
+	return nil
+}
+
+//Close stops the port forward and the rsync daemon once the sync in progress is done
+func (r *RSyncDaemon) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.close()
+}
+
+func (r *RSyncDaemon) close() error {
+	if r.daemonTarget == "" {
+		return nil
+	}
+	r.daemonTarget = ""
+	r.remoteRsync.StopPortForward(r.stopChan)
+	r.stopChan = nil
+	return r.remoteRsync.KillDaemon(pidFile)
+}
 
+func (r *RSyncDaemon) sync(paths []string) error {
 	args := []string{
-		"-zrlDv",
+		"-rDv",
 		"--omit-dir-times",
-		"--blocking-io",
-		"--checksum",
-		`--exclude=.git`}
+		"--blocking-io"}
+	args = append(args, builtInExclusionArgs()...)
+	args = append(args, transferArgs(r.transfer)...)
+	args = append(args, changeDetectionArgs(r.changeDetection)...)
 
 	if r.delete {
 		args = append(args, "--delete")
@@ -85,13 +166,67 @@ func (r *RSyncDaemon) Sync(paths []string) error {
 		args = append(args, "--dry-run")
 	}
 
-	cwd, err := os.Getwd()
+	filterArgs, err := ignoreFileFilterArgs(SyncFetchExcluded)
 	if err != nil {
-		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "getting the current directory failed and is required for syncing").String())
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("reading the exclusions of %s failed", SyncFetchExcluded)).String())
+	}
+	args = append(args, filterArgs...)
+	args = append(args, perDirectoryFilterArgs(r.useGitIgnore)...)
+
+	warnLocalOversizedFiles(r.transfer, paths, r.useGitIgnore)
+
+	//a full push restricted to a list of files only sends them
+	if len(paths) == 0 && len(r.files) > 0 {
+		listArgs, err := nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+		err = syncListedFiles(r.files, func(filesFrom string) error {
+			return r.syncAllFiles(append(listArgs, "--files-from="+convertWindowsPath(filesFrom)), os.Stdout)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing the listed files").String())
+		}
+		return nil
 	}
-	//check if sync fetch excluded file exists, if it doesn't, don't return an error
-	if _, err := os.Stat(SyncFetchExcluded); err == nil {
-		args = append(args, fmt.Sprintf(`--exclude-from=%s`, cwd+"/"+SyncFetchExcluded))
+
+	//a full push that doesn't delete the remote files only sends the files known to be changed
+	if len(paths) == 0 && r.changeDetection == options.ChangeDetectionHashCache && !r.delete {
+		listArgs, err := nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+		target := hashCacheTarget(r.kubeConfigKey, r.environment, r.pod, r.remoteProjectPath)
+		err = syncKnownChanges(target, r.useGitIgnore, r.dryRun, func(filesFrom string) error {
+			return r.syncAllFiles(append(listArgs, "--files-from="+convertWindowsPath(filesFrom)), os.Stdout)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing the changed files").String())
+		}
+		return nil
+	}
+
+	//a full push splits the project directories between concurrent transfers
+	if len(paths) == 0 && r.parallel > 1 {
+		err = r.backupChangedFiles(args, nil)
+		if err != nil {
+			return err
+		}
+		filesFromArg := func(file string) string {
+			return "--files-from=" + convertWindowsPath(file)
+		}
+		listArgs, err := nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+		err = syncInParallel(r.parallel, r.useGitIgnore, filesFromArg, func(extraArgs []string, stdOut io.Writer) error {
+			lArgs := append(append([]string{}, listArgs...), extraArgs...)
+			return r.syncAllFiles(lArgs, stdOut)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing all files in parallel").String())
+		}
+		return nil
 	}
 
 	paths = slice.RemoveDuplicateString(paths)
@@ -110,12 +245,23 @@ func (r *RSyncDaemon) Sync(paths []string) error {
 	if len(paths) > 0 && len(paths) <= r.individualFileSyncThreshold && allPathsExists {
 		cplogs.V(5).Infof("individual file sync, files to sync %d, threshold: %d", len(paths), r.individualFileSyncThreshold)
 		cplogs.Flush()
-		err = r.syncIndividualFiles(paths, args)
+		err = r.backupChangedFiles(args, paths)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		err = r.syncIndividualFiles(paths, args, ioutil.Discard)
 		if err != nil {
 			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing individual files").String())
 		}
 	} else {
-		err = r.syncAllFiles(paths, args)
+		err = r.backupChangedFiles(args, nil)
+		if err != nil {
+			return err
+		}
+		err = r.syncAllFiles(args, os.Stdout)
 		if err != nil {
 			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing all files").String())
 		}
@@ -124,6 +270,44 @@ func (r *RSyncDaemon) Sync(paths []string) error {
 	return nil
 }
 
+//SyncEvents moves and removes the remote files following the local renames and removals rather than
+//syncing the whole project, the written paths are then synced with Sync
+func (r *RSyncDaemon) SyncEvents(events []monitor.PathEvent) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	cplogs.V(5).Infof("sync triggered for events %v", events)
+	kscmd := kexec.KSCommand{}
+	kscmd.KubeConfigKey = r.kubeConfigKey
+	kscmd.Environment = r.environment
+	kscmd.Pod = r.pod
+	kscmd.Container = r.container
+	kscmd.Stderr = ioutil.Discard
+	kscmd.Stdout = ioutil.Discard
+
+	remote := remoteFiles{
+		executor:          kexec.NewLocal(),
+		kscmd:             kscmd,
+		remoteProjectPath: r.remoteProjectPath,
+		verbose:           r.verbose,
+		dryRun:            r.dryRun,
+		delete:            r.delete,
+		backup: func(paths []string) error {
+			if !backupNeeded(r.backup, r.delete, r.dryRun) {
+				return nil
+			}
+			return r.newBackup().save(paths)
+		},
+	}
+	if r.delete {
+		protected, err := protectedPaths(r.useGitIgnore)
+		if err != nil {
+			return err
+		}
+		remote.protected = protected
+	}
+	return remote.applyEvents(events, r.syncWithDaemon)
+}
+
 func (o RSyncDaemon) allPathsExists(paths []string) (res bool, notExisting []string) {
 	for _, path := range paths {
 		_, err := os.Stat(path)
@@ -136,7 +320,7 @@ func (o RSyncDaemon) allPathsExists(paths []string) (res bool, notExisting []str
 	return len(notExisting) == 0, notExisting
 }
 
-func (o RSyncDaemon) syncIndividualFiles(paths []string, args []string) error {
+func (o RSyncDaemon) syncIndividualFiles(paths []string, args []string, stdOut io.Writer) error {
 	remoteRsyncUrl := o.remoteRsync.GetRsyncURL(rsyncConfigSection, o.remoteProjectPath)
 
 	cwd, err := os.Getwd()
@@ -163,8 +347,7 @@ func (o RSyncDaemon) syncIndividualFiles(paths []string, args []string) error {
 			convertWindowsPath(baseDir),
 			remoteRsyncUrl+filepath.Dir(path)+"/")
 
-		fmt.Println(path)
-		err := o.executeRsync(lArgs, ioutil.Discard)
+		err := o.executeRsync(lArgs, stdOut)
 		if err != nil {
 			errMsg := fmt.Sprintf("rsync failed to execute using arguments %s", lArgs)
 			cplogs.V(4).Infof(errMsg)
@@ -174,7 +357,41 @@ func (o RSyncDaemon) syncIndividualFiles(paths []string, args []string) error {
 	return nil
 }
 
-func (o RSyncDaemon) syncAllFiles(paths []string, args []string) error {
+//newBackup returns the backup of the remote files of the pod
+func (o RSyncDaemon) newBackup() *remoteBackup {
+	backup := newRemoteBackup(o.kubeConfigKey, o.environment, o.pod, o.container, o.remoteProjectPath, o.backup)
+	backup.keep = o.maxBackups
+	backup.service = o.service
+	return backup
+}
+
+//backupChangedFiles backs up the remote files that the sync with the arguments would delete or overwrite,
+//the individual paths are the files synced one by one, the whole project is synced when empty
+func (o RSyncDaemon) backupChangedFiles(args []string, individualPaths []string) error {
+	if !backupNeeded(o.backup, o.delete, o.dryRun) {
+		return nil
+	}
+	var files []string
+	if len(individualPaths) == 0 {
+		changed, err := changedRemoteFiles(args, ".", o.syncAllFiles)
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the remote files to backup").String())
+		}
+		files = changed
+	}
+	for _, path := range individualPaths {
+		changed, err := changedRemoteFiles(args, filepath.Dir(path), func(args []string, stdOut io.Writer) error {
+			return o.syncIndividualFiles([]string{path}, args, stdOut)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the remote files to backup").String())
+		}
+		files = append(files, changed...)
+	}
+	return o.newBackup().save(files)
+}
+
+func (o RSyncDaemon) syncAllFiles(args []string, stdOut io.Writer) error {
 	remoteRsyncUrl := o.remoteRsync.GetRsyncURL(rsyncConfigSection, o.remoteProjectPath)
 	args = append(args,
 		"--relative",
@@ -182,7 +399,7 @@ func (o RSyncDaemon) syncAllFiles(paths []string, args []string) error {
 		".",
 		remoteRsyncUrl,
 	)
-	return o.executeRsync(args, os.Stdout)
+	return o.executeRsync(args, stdOut)
 }
 
 func (o RSyncDaemon) getRelativePathList(paths []string) ([]string, error) {
@@ -1,18 +1,19 @@
-// +build !windows
-
 package rsync
 
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 
-	"github.com/continuouspipe/remote-environment-client/config"
 	"github.com/continuouspipe/remote-environment-client/cplogs"
 	cperrors "github.com/continuouspipe/remote-environment-client/errors"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
 	"github.com/continuouspipe/remote-environment-client/osapi"
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
 	"github.com/continuouspipe/remote-environment-client/util/slice"
 	"github.com/pkg/errors"
@@ -24,8 +25,16 @@ func init() {
 
 type RSyncRsh struct {
 	kubeConfigKey, environment, pod, remoteProjectPath string
+	container                                          string
 	individualFileSyncThreshold                        int
-	verbose, dryRun, delete                            bool
+	verbose, dryRun, delete, useGitIgnore              bool
+	transfer                                           options.TransferOptions
+	changeDetection                                    string
+	parallel                                           int
+	backup                                             string
+	maxBackups                                         int
+	service                                            string
+	files                                              []string
 }
 
 func NewRSyncRsh() *RSyncRsh {
@@ -36,26 +45,36 @@ func (o *RSyncRsh) SetOptions(syncOptions options.SyncOptions) {
 	o.kubeConfigKey = syncOptions.KubeConfigKey
 	o.environment = syncOptions.Environment
 	o.pod = syncOptions.Pod
+	o.container = syncOptions.Container
 	o.individualFileSyncThreshold = syncOptions.IndividualFileSyncThreshold
 	o.remoteProjectPath = syncOptions.RemoteProjectPath
 	o.verbose = syncOptions.Verbose
 	o.dryRun = syncOptions.DryRun
+	o.useGitIgnore = syncOptions.UseGitIgnore
+	o.transfer = syncOptions.Transfer
 	o.delete = syncOptions.Delete
+	o.changeDetection = syncOptions.ChangeDetection
+	o.parallel = syncOptions.Parallel
+	o.backup = syncOptions.Backup
+	o.maxBackups = syncOptions.MaxBackups
+	o.service = syncOptions.Service
+	o.files = syncOptions.Files
 }
 
 func (o RSyncRsh) Sync(paths []string) error {
 	cplogs.V(5).Infof("sync triggered for paths %s", paths)
-	rsh := fmt.Sprintf(`%s %s --context=%s --namespace=%s exec -i %s`, config.AppName, config.KubeCtlName, o.kubeConfigKey, o.environment, o.pod)
+	rsh := rshCommand(o.kubeConfigKey, o.environment, o.pod, o.container)
 	cplogs.V(5).Infof("setting RSYNC_RSH to %s\n", rsh)
 	cplogs.Flush()
 	os.Setenv("RSYNC_RSH", rsh)
 	defer os.Unsetenv("RSYNC_RSH")
 
 	args := []string{
-		"-rlptDv",
-		"--blocking-io",
-		"--checksum",
-		`--exclude=.git`}
+		"-rptDv",
+		"--blocking-io"}
+	args = append(args, builtInExclusionArgs()...)
+	args = append(args, transferArgs(o.transfer)...)
+	args = append(args, changeDetectionArgs(o.changeDetection)...)
 
 	if o.delete {
 		args = append(args, "--delete")
@@ -67,12 +86,67 @@ func (o RSyncRsh) Sync(paths []string) error {
 		args = append(args, "--dry-run")
 	}
 
-	cwd, err := os.Getwd()
+	filterArgs, err := ignoreFileFilterArgs(SyncFetchExcluded)
 	if err != nil {
-		return err
+		return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("reading the exclusions of %s failed", SyncFetchExcluded)).String())
+	}
+	args = append(args, filterArgs...)
+	args = append(args, perDirectoryFilterArgs(o.useGitIgnore)...)
+
+	warnLocalOversizedFiles(o.transfer, paths, o.useGitIgnore)
+
+	//a full push restricted to a list of files only sends them
+	if len(paths) == 0 && len(o.files) > 0 {
+		listArgs, err := nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+		err = syncListedFiles(o.files, func(filesFrom string) error {
+			return o.syncAllFiles(append(listArgs, "--files-from="+filesFrom), os.Stdout)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing the listed files").String())
+		}
+		return nil
+	}
+
+	//a full push that doesn't delete the remote files only sends the files known to be changed
+	if len(paths) == 0 && o.changeDetection == options.ChangeDetectionHashCache && !o.delete {
+		listArgs, err := nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+		target := hashCacheTarget(o.kubeConfigKey, o.environment, o.pod, o.remoteProjectPath)
+		err = syncKnownChanges(target, o.useGitIgnore, o.dryRun, func(filesFrom string) error {
+			return o.syncAllFiles(append(listArgs, "--files-from="+filesFrom), os.Stdout)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing the changed files").String())
+		}
+		return nil
 	}
-	if _, err := os.Stat(SyncFetchExcluded); err == nil {
-		args = append(args, fmt.Sprintf(`--exclude-from=%s`, cwd+string(filepath.Separator)+SyncFetchExcluded))
+
+	//a full push splits the project directories between concurrent transfers
+	if len(paths) == 0 && o.parallel > 1 {
+		err = o.backupChangedFiles(args, nil)
+		if err != nil {
+			return err
+		}
+		filesFromArg := func(file string) string {
+			return "--files-from=" + file
+		}
+		listArgs, err := nulSeparatedListArgs(args)
+		if err != nil {
+			return err
+		}
+		err = syncInParallel(o.parallel, o.useGitIgnore, filesFromArg, func(extraArgs []string, stdOut io.Writer) error {
+			lArgs := append(append([]string{}, listArgs...), extraArgs...)
+			return o.syncAllFiles(lArgs, stdOut)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing all files in parallel").String())
+		}
+		return nil
 	}
 
 	paths = slice.RemoveDuplicateString(paths)
@@ -91,12 +165,20 @@ func (o RSyncRsh) Sync(paths []string) error {
 	if len(paths) > 0 && len(paths) <= o.individualFileSyncThreshold && allPathsExists {
 		cplogs.V(5).Infof("individual file sync, files to sync %d, threshold: %d", len(paths), o.individualFileSyncThreshold)
 		cplogs.Flush()
-		err = o.syncIndividualFiles(paths, args)
+		err = o.backupChangedFiles(args, paths)
+		if err != nil {
+			return err
+		}
+		err = o.syncIndividualFiles(paths, args, os.Stdout)
 		if err != nil {
 			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing individual files").String())
 		}
 	} else {
-		err = o.syncAllFiles(paths, args)
+		err = o.backupChangedFiles(args, nil)
+		if err != nil {
+			return err
+		}
+		err = o.syncAllFiles(args, os.Stdout)
 		if err != nil {
 			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when syncing all files").String())
 		}
@@ -104,6 +186,47 @@ func (o RSyncRsh) Sync(paths []string) error {
 	return err
 }
 
+//SyncEvents moves and removes the remote files following the local renames and removals rather than
+//syncing the whole project, the written paths are then synced with Sync
+func (o RSyncRsh) SyncEvents(events []monitor.PathEvent) error {
+	cplogs.V(5).Infof("sync triggered for events %v", events)
+	kscmd := kexec.KSCommand{}
+	kscmd.KubeConfigKey = o.kubeConfigKey
+	kscmd.Environment = o.environment
+	kscmd.Pod = o.pod
+	kscmd.Container = o.container
+	kscmd.Stderr = ioutil.Discard
+	kscmd.Stdout = ioutil.Discard
+
+	remote := remoteFiles{
+		executor:          kexec.NewLocal(),
+		kscmd:             kscmd,
+		remoteProjectPath: o.remoteProjectPath,
+		verbose:           o.verbose,
+		dryRun:            o.dryRun,
+		delete:            o.delete,
+		backup: func(paths []string) error {
+			if !backupNeeded(o.backup, o.delete, o.dryRun) {
+				return nil
+			}
+			return o.newBackup().save(paths)
+		},
+	}
+	if o.delete {
+		protected, err := protectedPaths(o.useGitIgnore)
+		if err != nil {
+			return err
+		}
+		remote.protected = protected
+	}
+	return remote.applyEvents(events, o.Sync)
+}
+
+//Close does nothing as each sync runs its own rsync process inside the pod
+func (o RSyncRsh) Close() error {
+	return nil
+}
+
 func (o RSyncRsh) allPathsExists(paths []string) (res bool, notExisting []string) {
 	for _, path := range paths {
 		_, err := os.Stat(path)
@@ -116,7 +239,7 @@ func (o RSyncRsh) allPathsExists(paths []string) (res bool, notExisting []string
 	return len(notExisting) == 0, notExisting
 }
 
-func (o RSyncRsh) syncIndividualFiles(paths []string, args []string) error {
+func (o RSyncRsh) syncIndividualFiles(paths []string, args []string, stdOut io.Writer) error {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return err
@@ -132,15 +255,19 @@ func (o RSyncRsh) syncIndividualFiles(paths []string, args []string) error {
 	//and prevents the "rsync: link_stat" error above
 
 	for _, path := range paths {
+		localDir := cwd + string(filepath.Separator) + filepath.Dir(path) + string(filepath.Separator)
+		if runtime.GOOS == "windows" {
+			localDir = convertWindowsPath(localDir)
+		}
 		lArgs := args
 		lArgs = append(lArgs,
 			"--include="+filepath.Base(path),
 			"--exclude=*",
 			"--",
-			cwd+string(filepath.Separator)+filepath.Dir(path)+string(filepath.Separator),
-			"--:"+o.remoteProjectPath+filepath.Dir(path)+string(filepath.Separator))
+			localDir,
+			"--:"+o.remoteProjectPath+filepath.ToSlash(filepath.Dir(path))+"/")
 
-		err := o.executeRsync(lArgs, os.Stdout)
+		err := o.executeRsync(lArgs, stdOut)
 		if err != nil {
 			errMsg := fmt.Sprintf("rsync failed to execute using arguments %s", lArgs)
 			cplogs.V(4).Infof(errMsg)
@@ -151,14 +278,48 @@ func (o RSyncRsh) syncIndividualFiles(paths []string, args []string) error {
 	return nil
 }
 
-func (o RSyncRsh) syncAllFiles(paths []string, args []string) error {
+//newBackup returns the backup of the remote files of the pod
+func (o RSyncRsh) newBackup() *remoteBackup {
+	backup := newRemoteBackup(o.kubeConfigKey, o.environment, o.pod, o.container, o.remoteProjectPath, o.backup)
+	backup.keep = o.maxBackups
+	backup.service = o.service
+	return backup
+}
+
+//backupChangedFiles backs up the remote files that the sync with the arguments would delete or overwrite,
+//the individual paths are the files synced one by one, the whole project is synced when empty
+func (o RSyncRsh) backupChangedFiles(args []string, individualPaths []string) error {
+	if !backupNeeded(o.backup, o.delete, o.dryRun) {
+		return nil
+	}
+	var files []string
+	if len(individualPaths) == 0 {
+		changed, err := changedRemoteFiles(args, ".", o.syncAllFiles)
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the remote files to backup").String())
+		}
+		files = changed
+	}
+	for _, path := range individualPaths {
+		changed, err := changedRemoteFiles(args, filepath.Dir(path), func(args []string, stdOut io.Writer) error {
+			return o.syncIndividualFiles([]string{path}, args, stdOut)
+		})
+		if err != nil {
+			return errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "error when listing the remote files to backup").String())
+		}
+		files = append(files, changed...)
+	}
+	return o.newBackup().save(files)
+}
+
+func (o RSyncRsh) syncAllFiles(args []string, stdOut io.Writer) error {
 	args = append(args,
 		"--relative",
 		"--",
 		"./",
 		"--:"+o.remoteProjectPath,
 	)
-	err := o.executeRsync(args, os.Stdout)
+	err := o.executeRsync(args, stdOut)
 	if err != nil {
 		errMsg := fmt.Sprintf("rsync failed to execute using arguments %s", args)
 		cplogs.V(4).Infof(errMsg)
@@ -183,7 +344,10 @@ func (o RSyncRsh) getRelativePathList(paths []string) ([]string, error) {
 		return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, "getting the current directory failed and is required for syncing").String())
 	}
 	for key, path := range paths {
-		relPath, err := filepath.Rel(cwd, string(filepath.Separator)+path)
+		if !filepath.IsAbs(path) {
+			path = string(filepath.Separator) + path
+		}
+		relPath, err := filepath.Rel(cwd, path)
 		if err != nil {
 			return nil, errors.Wrap(err, cperrors.NewStatefulErrorMessage(http.StatusInternalServerError, fmt.Sprintf("getting the relative path using cwd %s and path %s failed", cwd, path)).String())
 		}
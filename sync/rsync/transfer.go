@@ -0,0 +1,131 @@
+package rsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/continuouspipe/remote-environment-client/cplogs"
+	kexec "github.com/continuouspipe/remote-environment-client/kubectlapi/exec"
+	"github.com/continuouspipe/remote-environment-client/sync/options"
+)
+
+//transferArgs returns the rsync arguments for the bandwidth, compression, file size and symbolic link options
+//without a symbolic link argument rsync skips the symbolic links
+func transferArgs(transfer options.TransferOptions) []string {
+	var args []string
+	if transfer.Compress {
+		args = append(args, "--compress")
+	}
+	if transfer.BandwidthLimit != "" {
+		args = append(args, "--bwlimit="+transfer.BandwidthLimit)
+	}
+	if transfer.MaxFileSize != "" {
+		args = append(args, "--max-size="+transfer.MaxFileSize)
+	}
+	switch transfer.Symlinks {
+	case options.SymlinksCopy:
+		args = append(args, "--copy-links")
+	case options.SymlinksSkip:
+	default:
+		args = append(args, "--links")
+	}
+	return args
+}
+
+//warnLocalOversizedFiles prints the local files that are not pushed as they are larger than the maximum file size
+//when paths is empty all the project files that are not excluded are checked
+func warnLocalOversizedFiles(transfer options.TransferOptions, paths []string, useGitIgnore bool) {
+	if transfer.MaxFileSize == "" {
+		return
+	}
+	files, err := localOversizedFiles(transfer.MaxFileSize, paths, useGitIgnore)
+	if err != nil {
+		cplogs.V(4).Infof("error when looking for the files larger than %s: %s", transfer.MaxFileSize, err.Error())
+		cplogs.Flush()
+		return
+	}
+	printOversizedFiles(transfer.MaxFileSize, files)
+}
+
+func localOversizedFiles(maxFileSize string, paths []string, useGitIgnore bool) (files []string, err error) {
+	maxSize, err := options.ParseSize(maxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > 0 {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() || info.Size() <= maxSize {
+				continue
+			}
+			if relPath, err := filepath.Rel(cwd, path); err == nil {
+				path = filepath.ToSlash(relPath)
+			}
+			files = append(files, path)
+		}
+		return files, nil
+	}
+
+	matcher, err := NewExclusionMatcher(false, useGitIgnore)
+	if err != nil {
+		return nil, err
+	}
+	err = WalkIncluded(".", matcher, func(path string, relPath string, info os.FileInfo) error {
+		if info.Mode().IsRegular() && info.Size() > maxSize {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	return files, err
+}
+
+//warnRemoteOversizedFiles prints the files of the pod that are not fetched as they are larger than the maximum file size
+//paths are the fetched paths relative to the remote project path, empty when the whole project is fetched
+func warnRemoteOversizedFiles(transfer options.TransferOptions, kscmd kexec.KSCommand, remoteProjectPath string, paths []string, useGitIgnore bool) {
+	if transfer.MaxFileSize == "" {
+		return
+	}
+	maxSize, err := options.ParseSize(transfer.MaxFileSize)
+	if err != nil {
+		return
+	}
+	matcher, err := NewExclusionMatcher(true, useGitIgnore)
+	if err != nil {
+		return
+	}
+	script := fmt.Sprintf(`cd "$1" && find %s -type f -size +"$2"c`, remoteGlobs(paths))
+	out, err := kexec.NewLocal().CommandExec(kscmd, "sh", "-c", script, "sh", remoteProjectPath, strconv.FormatInt(maxSize, 10))
+	if err != nil {
+		cplogs.V(4).Infof("error when looking for the remote files larger than %s: %s", transfer.MaxFileSize, err.Error())
+		cplogs.Flush()
+		return
+	}
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		relPath := strings.TrimPrefix(strings.TrimSpace(line), "./")
+		if relPath == "" || strings.HasPrefix(relPath, ".git/") {
+			continue
+		}
+		if include, _, err := matcher.HasMatchAndIsIncluded(relPath); err == nil && include {
+			files = append(files, relPath)
+		}
+	}
+	printOversizedFiles(transfer.MaxFileSize, files)
+}
+
+func printOversizedFiles(maxFileSize string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Printf("Warning: the following files are larger than the maximum file size %s and are skipped:\n", maxFileSize)
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+	}
+}
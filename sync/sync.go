@@ -7,9 +7,13 @@ import (
 )
 
 //syncs the files specified in filePaths. When filePaths is an empty slice, it syncs all project files
+//SyncEvents applies the renames and removals of the events on the remote files and syncs the written paths
+//Close releases the transport kept open between the syncs
 type Syncer interface {
 	Sync(filePaths []string) error
+	SyncEvents(events []monitor.PathEvent) error
 	SetOptions(syncOptions options.SyncOptions)
+	Close() error
 }
 
 func GetSyncer() Syncer {
@@ -18,7 +22,7 @@ func GetSyncer() Syncer {
 
 //this wraps a Syncer struct in order to implement the EventsObserver
 //when a file changes OnLastChange() is called which will then trigger
-//the sync via syncer.Sync
+//the sync via syncer.SyncEvents
 type SyncOnEvent struct {
 	syncer Syncer
 }
@@ -27,8 +31,8 @@ func NewSyncOnEvent() *SyncOnEvent {
 	return &SyncOnEvent{}
 }
 
-func (observer SyncOnEvent) OnLastChange(filePaths []string) error {
-	return observer.syncer.Sync(filePaths)
+func (observer SyncOnEvent) OnLastChange(events []monitor.PathEvent) error {
+	return observer.syncer.SyncEvents(events)
 }
 
 func GetSyncOnEventObserver(syncer Syncer) monitor.EventsObserver {
@@ -4,6 +4,7 @@ import (
 	"github.com/continuouspipe/remote-environment-client/kubectlapi/pods"
 	"github.com/stretchr/testify/mock"
 	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/labels"
 )
 
 //Mock for PodsFilter
@@ -20,11 +21,21 @@ func (m *MockPodsFilter) List(pods api.PodList) pods.Filter {
 	return args.Get(0).(*MockPodsFilter)
 }
 
+func (m *MockPodsFilter) Services(services api.ServiceList) pods.Filter {
+	args := m.Called(services)
+	return args.Get(0).(*MockPodsFilter)
+}
+
 func (m *MockPodsFilter) ByService(service string) pods.Filter {
 	args := m.Called(service)
 	return args.Get(0).(*MockPodsFilter)
 }
 
+func (m *MockPodsFilter) BySelector(selector labels.Selector) pods.Filter {
+	args := m.Called(selector)
+	return args.Get(0).(*MockPodsFilter)
+}
+
 func (m *MockPodsFilter) ByStatus(status string) pods.Filter {
 	args := m.Called(status)
 	return args.Get(0).(*MockPodsFilter)
@@ -39,3 +50,8 @@ func (m *MockPodsFilter) First() *api.Pod {
 	args := m.Called()
 	return args.Get(0).(*api.Pod)
 }
+
+func (m *MockPodsFilter) Items() []api.Pod {
+	args := m.Called()
+	return args.Get(0).([]api.Pod)
+}
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"github.com/continuouspipe/remote-environment-client/sync/monitor"
 	"github.com/continuouspipe/remote-environment-client/sync/options"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,6 +20,16 @@ func (s SpySyncer) Sync(filePaths []string) error {
 	return args.Error(0)
 }
 
+func (s SpySyncer) SyncEvents(events []monitor.PathEvent) error {
+	args := s.Called(events)
+	return args.Error(0)
+}
+
 func (s SpySyncer) SetOptions(syncOptions options.SyncOptions) {
 	s.Called(syncOptions)
 }
+
+func (s SpySyncer) Close() error {
+	args := s.Called()
+	return args.Error(0)
+}
@@ -0,0 +1,193 @@
+//Package asciicast records terminal sessions in the asciicast v2 format and plays them back,
+//see https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//Version is the version of the asciicast format written and read
+const Version = 2
+
+//Header is the first line of a recording
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+//Event is a line of the recording following the header: the time in seconds since the beginning of the recording, the
+//type of the event ("o" for the output) and the data
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+//MarshalJSON writes the event as an array
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+//UnmarshalJSON reads the event from an array
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var fields []interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("the event %s has %d fields instead of 3", data, len(fields))
+	}
+	var ok1, ok2, ok3 bool
+	e.Time, ok1 = fields[0].(float64)
+	e.Type, ok2 = fields[1].(string)
+	e.Data, ok3 = fields[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("the event %s is not valid", data)
+	}
+	return nil
+}
+
+//Recorder writes the output it receives as the events of a recording, it is safe to write to it from several
+//goroutines like the standard output and error of a command
+type Recorder struct {
+	encoder *json.Encoder
+	start   time.Time
+	now     func() time.Time
+	mutex   sync.Mutex
+	//pending is the end of the last output when it stops in the middle of a UTF-8 character
+	pending []byte
+}
+
+//NewRecorder writes the header and returns the recorder writing the events that follow it
+func NewRecorder(writer io.Writer, header Header) (*Recorder, error) {
+	return newRecorder(writer, header, time.Now)
+}
+
+func newRecorder(writer io.Writer, header Header, now func() time.Time) (*Recorder, error) {
+	header.Version = Version
+	start := now()
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(header)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{encoder: encoder, start: start, now: now}, nil
+}
+
+//Write records the output, the bytes of an incomplete UTF-8 character are kept for the next output
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data := append(r.pending, p...)
+	complete := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte{}, data[complete:]...)
+	if complete == 0 {
+		return len(p), nil
+	}
+	return len(p), r.writeEvent(string(data[:complete]))
+}
+
+//Close records the bytes left of an incomplete character
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.pending) == 0 {
+		return nil
+	}
+	data := string(r.pending)
+	r.pending = nil
+	return r.writeEvent(data)
+}
+
+func (r *Recorder) writeEvent(data string) error {
+	return r.encoder.Encode(Event{r.now().Sub(r.start).Seconds(), "o", data})
+}
+
+//Player plays a recording back
+type Player struct {
+	//Speed divides the time between the events
+	Speed float64
+	//IdleTimeLimit is the longest time between two events, no limit when it is zero
+	IdleTimeLimit time.Duration
+	sleep         func(time.Duration)
+}
+
+//NewPlayer returns the player playing the recordings at their original speed
+func NewPlayer() *Player {
+	return &Player{Speed: 1, sleep: time.Sleep}
+}
+
+//Play writes the output events of the recording to the writer at the time they happened and returns the header of the
+//recording
+func (p Player) Play(recording io.Reader, writer io.Writer) (*Header, error) {
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if scanner.Err() != nil {
+			return nil, scanner.Err()
+		}
+		return nil, fmt.Errorf("the recording is empty")
+	}
+	header := &Header{}
+	if err := json.Unmarshal(scanner.Bytes(), header); err != nil {
+		return nil, fmt.Errorf("the header of the recording is not valid: %s", err.Error())
+	}
+	if header.Version != Version {
+		return nil, fmt.Errorf("the version %d of the recording is not supported, only the version %d is", header.Version, Version)
+	}
+
+	last := 0.0
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		event := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return header, err
+		}
+		if event.Type != "o" {
+			continue
+		}
+		p.wait(time.Duration((event.Time - last) * float64(time.Second)))
+		last = event.Time
+		if _, err := io.WriteString(writer, event.Data); err != nil {
+			return header, err
+		}
+	}
+	return header, scanner.Err()
+}
+
+func (p Player) wait(delay time.Duration) {
+	if p.IdleTimeLimit > 0 && delay > p.IdleTimeLimit {
+		delay = p.IdleTimeLimit
+	}
+	if p.Speed > 0 {
+		delay = time.Duration(float64(delay) / p.Speed)
+	}
+	if delay > 0 && p.sleep != nil {
+		p.sleep(delay)
+	}
+}
@@ -0,0 +1,55 @@
+package asciicast
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	now := start
+	recording := &bytes.Buffer{}
+	recorder, err := newRecorder(recording, Header{Width: 120, Height: 40, Title: "dev/web"}, func() time.Time { return now })
+	assert.Nil(t, err)
+
+	now = start.Add(500 * time.Millisecond)
+	recorder.Write([]byte("$ ls\r\n"))
+	now = start.Add(1500 * time.Millisecond)
+	//the euro sign is split between two writes
+	recorder.Write([]byte("price: \xe2\x82"))
+	recorder.Write([]byte("\xac\r\n"))
+	recorder.Close()
+
+	assert.Equal(t, `{"version":2,"width":120,"height":40,"timestamp":1500000000,"title":"dev/web"}
+[0.5,"o","$ ls\r\n"]
+[1.5,"o","price: "]
+[1.5,"o","€\r\n"]
+`, recording.String())
+}
+
+func TestPlayer_Play(t *testing.T) {
+	recording := `{"version": 2, "width": 80, "height": 24}
+[0.5, "o", "$ ls\r\n"]
+[0.7, "i", "l"]
+[10.5, "o", "app\r\n"]
+`
+	var delays []time.Duration
+	player := NewPlayer()
+	player.Speed = 2
+	player.IdleTimeLimit = 2 * time.Second
+	player.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	out := &bytes.Buffer{}
+	header, err := player.Play(strings.NewReader(recording), out)
+	assert.Nil(t, err)
+	assert.Equal(t, 80, header.Width)
+	assert.Equal(t, "$ ls\r\napp\r\n", out.String(), "only the output is played")
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, time.Second}, delays, "the pauses are limited and played faster")
+
+	_, err = NewPlayer().Play(strings.NewReader(`{"version": 1, "width": 80, "height": 24, "stdout": []}`), out)
+	assert.NotNil(t, err, "the version 1 is not supported")
+}
@@ -0,0 +1,91 @@
+//Package rotatefile writes to a file that is rotated when it reaches its maximum size, the previous files are kept
+//as file.1, file.2... up to the number of backups
+package rotatefile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+//Writer appends to the file, each write is kept whole in one of the files
+type Writer struct {
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+	mutex   sync.Mutex
+}
+
+//NewWriter opens the file to append to it, it is rotated when writing to it would make it bigger than maxSize bytes
+//and only the last backups previous files are kept
+func NewWriter(path string, maxSize int64, backups int) (*Writer, error) {
+	w := &Writer{path: path, maxSize: maxSize, backups: backups}
+	err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+//Write appends to the file after rotating it when the data doesn't fit in it
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+//rotate renames the file to file.1 after shifting the previous files, the oldest one is removed
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(w.backup(w.backups))
+	for i := w.backups - 1; i > 0; i-- {
+		if err := os.Rename(w.backup(i), w.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if w.backups > 0 {
+		if err := os.Rename(w.path, w.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+func (w *Writer) backup(index int) string {
+	return fmt.Sprintf("%s.%d", w.path, index)
+}
+
+//Close closes the file
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.file.Close()
+}
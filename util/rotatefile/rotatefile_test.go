@@ -0,0 +1,43 @@
+package rotatefile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotatefile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "web.log")
+
+	w, err := NewWriter(path, 10, 2)
+	assert.Nil(t, err)
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		_, err = w.Write([]byte(line))
+		assert.Nil(t, err)
+	}
+	w.Close()
+
+	read := func(path string) string {
+		content, _ := ioutil.ReadFile(path)
+		return string(content)
+	}
+	assert.Equal(t, "line 4\n", read(path))
+	assert.Equal(t, "line 3\n", read(path+".1"))
+	assert.Equal(t, "line 2\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "only the last 2 files are kept")
+
+	//the size of the existing file is taken into account when it is opened again
+	w, err = NewWriter(path, 10, 2)
+	assert.Nil(t, err)
+	w.Write([]byte("line 5\n"))
+	w.Close()
+	assert.Equal(t, "line 5\n", read(path))
+	assert.Equal(t, "line 4\n", read(path+".1"))
+}
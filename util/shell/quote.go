@@ -0,0 +1,27 @@
+package shell
+
+import (
+	"bytes"
+	"strings"
+)
+
+//QuoteGlob quotes the path for a POSIX shell leaving the * and ? wildcards unquoted so that the shell expands them
+func QuoteGlob(p string) string {
+	var word, literal bytes.Buffer
+	flush := func() {
+		if literal.Len() > 0 {
+			word.WriteString("'" + strings.Replace(literal.String(), "'", `'\''`, -1) + "'")
+			literal.Reset()
+		}
+	}
+	for _, c := range p {
+		if c == '*' || c == '?' {
+			flush()
+			word.WriteRune(c)
+			continue
+		}
+		literal.WriteRune(c)
+	}
+	flush()
+	return word.String()
+}